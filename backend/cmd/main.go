@@ -5,9 +5,13 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	_ "time/tzdata" // TIMEZONE works on hosts without a zoneinfo database
 
 	"esther-whatsapp/internal/api"
+	"esther-whatsapp/internal/broadcast"
 	"esther-whatsapp/internal/config"
+	"esther-whatsapp/internal/flow"
+	"esther-whatsapp/internal/media"
 	"esther-whatsapp/internal/queue"
 	"esther-whatsapp/internal/scheduler"
 	"esther-whatsapp/internal/store"
@@ -23,11 +27,35 @@ func main() {
 	}
 	log.Println("✅ Config loaded")
 
-	// Initialize Supabase
-	if err := store.InitSupabase(); err != nil {
-		log.Fatalf("Failed to initialize Supabase: %v", err)
+	// Initialize storage
+	if err := store.Init(); err != nil {
+		log.Fatalf("Failed to initialize %s store: %v", config.AppConfig.StoreDriver, err)
 	}
-	log.Println("✅ Supabase connected")
+	log.Printf("✅ Store ready (%s)", config.AppConfig.StoreDriver)
+
+	// Apply the settings saved from the dashboard over the defaults
+	if err := store.LoadBotSettings(); err != nil {
+		log.Fatalf("Failed to load settings: %v", err)
+	}
+	log.Println("✅ Settings loaded")
+
+	// Initialize media storage for received attachments
+	if err := media.Init(); err != nil {
+		log.Fatalf("Failed to initialize %s media storage: %v", config.AppConfig.MediaStorage, err)
+	}
+	log.Printf("✅ Media storage ready (%s)", config.AppConfig.MediaStorage)
+
+	// Load keyword auto-replies
+	if err := whatsapp.LoadKeywords(); err != nil {
+		log.Fatalf("Failed to load keywords: %v", err)
+	}
+	log.Println("✅ Keywords loaded")
+
+	// Load conversational flows
+	if err := flow.Default.Load(); err != nil {
+		log.Fatalf("Failed to load flows: %v", err)
+	}
+	log.Println("✅ Flows loaded")
 
 	// Initialize WhatsApp client
 	_, err := whatsapp.NewClient()
@@ -40,6 +68,12 @@ func main() {
 	whatsapp.RegisterHandler()
 	log.Println("✅ Message handler registered")
 
+	// Restore previously paired accounts
+	if err := whatsapp.Manager.LoadAccounts(); err != nil {
+		log.Fatalf("Failed to load WhatsApp accounts: %v", err)
+	}
+	log.Printf("✅ %d account(s) restored", len(whatsapp.Manager.ListAccounts()))
+
 	// Connect to WhatsApp
 	if err := whatsapp.Connect(); err != nil {
 		log.Fatalf("Failed to connect to WhatsApp: %v", err)
@@ -54,6 +88,12 @@ func main() {
 	scheduler.Start()
 	log.Println("✅ Scheduler started")
 
+	// Broadcasts cut off by the last shutdown wait to be resumed
+	if err := broadcast.Recover(); err != nil {
+		log.Fatalf("Failed to recover broadcasts: %v", err)
+	}
+	log.Println("✅ Broadcasts recovered")
+
 	// Setup HTTP router
 	router := api.SetupRouter()
 
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"esther-whatsapp/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mediaURLTTL is how long a signed media URL can be used
+const mediaURLTTL = 5 * time.Minute
+
+// RequireToken only lets requests through that carry API_TOKEN as
+// "Authorization: Bearer <token>". The token is never read from the URL,
+// where access logs, proxies and browser history would keep it.
+// When API_TOKEN is not set the protected endpoints are disabled.
+func RequireToken() gin.HandlerFunc {
+	return requireAuth(false)
+}
+
+// RequireMediaAccess is RequireToken that also accepts an unexpired URL
+// signed by signMediaURL, so <img> and <video> tags can load a file
+func RequireMediaAccess() gin.HandlerFunc {
+	return requireAuth(true)
+}
+
+func requireAuth(allowSigned bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		expected := config.AppConfig.APIToken
+		if expected == "" {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{
+				"error": "API_TOKEN is not configured",
+			})
+			return
+		}
+
+		if hasBearer(c, expected) || (allowSigned && hasMediaSignature(c, expected)) {
+			c.Next()
+			return
+		}
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+	}
+}
+
+// hasBearer reports whether the request carries the token in its Authorization header
+func hasBearer(c *gin.Context, expected string) bool {
+	token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// hasMediaSignature reports whether the URL was signed for this media file and has not expired
+func hasMediaSignature(c *gin.Context, key string) bool {
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	signature, err := hex.DecodeString(c.Query("signature"))
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(signature, mediaSignature(key, c.Param("id"), expires))
+}
+
+// signMediaURL returns a URL of GET /api/media/:id that works without the token until expiresAt
+func signMediaURL(id string, expiresAt time.Time) string {
+	expires := expiresAt.Unix()
+	signature := mediaSignature(config.AppConfig.APIToken, id, expires)
+	return fmt.Sprintf("/api/media/%s?expires=%d&signature=%s", url.PathEscape(id), expires, hex.EncodeToString(signature))
+}
+
+func mediaSignature(key, id string, expires int64) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	fmt.Fprintf(mac, "%s\n%d", id, expires)
+	return mac.Sum(nil)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"esther-whatsapp/internal/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// mediaRouter serves GET /api/media/:id behind RequireMediaAccess
+func mediaRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	previous := config.AppConfig
+	config.AppConfig = &config.Config{APIToken: "secret"}
+	t.Cleanup(func() { config.AppConfig = previous })
+
+	r := gin.New()
+	r.GET("/api/media/:id", RequireMediaAccess(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	return r
+}
+
+func TestRequireMediaAccess(t *testing.T) {
+	r := mediaRouter(t)
+	tests := []struct {
+		name   string
+		url    string
+		header string
+		want   int
+	}{
+		{"bearer token", "/api/media/m1", "Bearer secret", http.StatusOK},
+		{"wrong bearer token", "/api/media/m1", "Bearer nope", http.StatusUnauthorized},
+		{"token without scheme", "/api/media/m1", "secret", http.StatusUnauthorized},
+		{"no credentials", "/api/media/m1", "", http.StatusUnauthorized},
+		{"token in the query", "/api/media/m1?token=secret", "", http.StatusUnauthorized},
+		{"signed URL", signMediaURL("m1", time.Now().Add(time.Minute)), "", http.StatusOK},
+		{"expired signed URL", signMediaURL("m1", time.Now().Add(-time.Second)), "", http.StatusUnauthorized},
+		{"signature of another file", "/api/media/m1" + signMediaURL("m2", time.Now().Add(time.Minute))[len("/api/media/m2"):], "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Fatalf("GET %s = %d, want %d", tt.url, w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestSignedURLNeedsTheCurrentToken(t *testing.T) {
+	r := mediaRouter(t)
+	url := signMediaURL("m1", time.Now().Add(time.Minute))
+
+	// Rotating API_TOKEN invalidates the URLs signed with the old one
+	config.AppConfig.APIToken = "rotated"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("GET %s after rotation = %d, want %d", url, w.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"esther-whatsapp/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDeleteBroadcast(t *testing.T) {
+	b, err := store.DB.CreateBroadcast(&store.Broadcast{Name: t.Name(), Message: "Halo", Recipients: []string{testPhone()}, DelayMs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if code := serve(t, http.MethodDelete, "/api/broadcasts/"+b.ID, nil, nil); code != http.StatusOK {
+		t.Fatalf("DELETE = %d, want %d", code, http.StatusOK)
+	}
+	if got, err := store.DB.GetBroadcast(b.ID); err != nil || got != nil {
+		t.Fatalf("deleted broadcast = %+v, %v, want it gone", got, err)
+	}
+}
+
+func TestCreateBroadcastRejectsUnknownAccounts(t *testing.T) {
+	body := gin.H{"name": "Promo", "message": "Halo", "recipients": []string{testPhone()}, "account_id": "acc-missing"}
+	var resp struct {
+		Error string `json:"error"`
+	}
+	if code := serve(t, http.MethodPost, "/api/broadcasts", body, &resp); code != http.StatusBadRequest || resp.Error != "account acc-missing not found" {
+		t.Fatalf("POST = %d (%q), want %d", code, resp.Error, http.StatusBadRequest)
+	}
+}
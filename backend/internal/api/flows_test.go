@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"esther-whatsapp/internal/flow"
+	"esther-whatsapp/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// flowBody is a valid flow definition started by the given trigger
+func flowBody(trigger string) gin.H {
+	return gin.H{
+		"name":       "Menu",
+		"trigger":    trigger,
+		"start_node": "root",
+		"nodes":      []gin.H{{"id": "root", "message": "Pilih layanan:"}},
+	}
+}
+
+// saveFlow sends a flow to the API and returns the status and saved flow
+func saveFlow(t *testing.T, method, url string, body gin.H) (int, store.Flow) {
+	t.Helper()
+	var resp struct {
+		Flow store.Flow `json:"flow"`
+	}
+	code := serve(t, method, url, body, &resp)
+	if resp.Flow.ID != "" {
+		t.Cleanup(func() { flow.Default.Delete(resp.Flow.ID) })
+	}
+	return code, resp.Flow
+}
+
+func TestCreateFlow(t *testing.T) {
+	code, created := saveFlow(t, http.MethodPost, "/api/flows", flowBody("menu-create"))
+	if code != http.StatusOK || created.ID == "" {
+		t.Fatalf("POST = %d, %+v, want the created flow", code, created)
+	}
+
+	// A create naming an ID would overwrite that flow
+	body := flowBody("menu-other")
+	body["id"] = created.ID
+	if code, _ := saveFlow(t, http.MethodPost, "/api/flows", body); code != http.StatusBadRequest {
+		t.Fatalf("POST with an id = %d, want %d", code, http.StatusBadRequest)
+	}
+	if f, _ := flow.Default.Get(created.ID); f.Trigger != "menu-create" {
+		t.Fatalf("flow trigger = %q, want it untouched", f.Trigger)
+	}
+
+	if code, _ := saveFlow(t, http.MethodPost, "/api/flows", flowBody("MENU-create")); code != http.StatusConflict {
+		t.Fatalf("POST with a used trigger = %d, want %d", code, http.StatusConflict)
+	}
+}
+
+func TestUpdateFlowKeepsItsTrigger(t *testing.T) {
+	_, created := saveFlow(t, http.MethodPost, "/api/flows", flowBody("menu-update"))
+	_, other := saveFlow(t, http.MethodPost, "/api/flows", flowBody("menu-update-other"))
+
+	body := flowBody("menu-update")
+	body["name"] = "Renamed"
+	if code, f := saveFlow(t, http.MethodPut, "/api/flows/"+created.ID, body); code != http.StatusOK || f.Name != "Renamed" {
+		t.Fatalf("PUT = %d, %+v, want the flow renamed", code, f)
+	}
+	if code, _ := saveFlow(t, http.MethodPut, "/api/flows/"+other.ID, flowBody("menu-update")); code != http.StatusConflict {
+		t.Fatalf("PUT taking another flow's trigger = %d, want %d", code, http.StatusConflict)
+	}
+}
@@ -1,13 +1,22 @@
 package api
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
 
+	"esther-whatsapp/internal/broadcast"
 	"esther-whatsapp/internal/config"
+	"esther-whatsapp/internal/flow"
+	"esther-whatsapp/internal/media"
+	"esther-whatsapp/internal/queue"
 	"esther-whatsapp/internal/rules"
 	"esther-whatsapp/internal/store"
+	"esther-whatsapp/internal/template"
 	"esther-whatsapp/internal/whatsapp"
 
 	"github.com/gin-gonic/gin"
@@ -34,7 +43,7 @@ func GetMessages(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 
-	messages, err := store.GetMessages(limit, offset)
+	messages, err := store.DB.GetMessages(limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
@@ -51,7 +60,7 @@ func GetMessages(c *gin.Context) {
 
 // GetUsers returns all users
 func GetUsers(c *gin.Context) {
-	users, err := store.GetUsers()
+	users, err := store.DB.GetUsers()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
@@ -74,7 +83,7 @@ func GetUser(c *gin.Context) {
 		return
 	}
 
-	user, err := store.GetUserByID(id)
+	user, err := store.DB.GetUserByID(id)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
@@ -100,6 +109,11 @@ type UpdateUserRequest struct {
 	Notes   *string `json:"notes"`
 	Blocked *bool   `json:"blocked"`
 	OptIn   *bool   `json:"opt_in"`
+
+	// Fields replaces the custom placeholder values when not null
+	Fields map[string]string `json:"fields"`
+	// Tags replaces the user's audience tags when not null
+	Tags []string `json:"tags"`
 }
 
 // UpdateUserAPI updates a user
@@ -133,6 +147,12 @@ func UpdateUserAPI(c *gin.Context) {
 	if req.OptIn != nil {
 		updates["opt_in"] = *req.OptIn
 	}
+	if req.Fields != nil {
+		updates["fields"] = req.Fields
+	}
+	if req.Tags != nil {
+		updates["tags"] = req.Tags
+	}
 
 	if len(updates) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -141,7 +161,7 @@ func UpdateUserAPI(c *gin.Context) {
 		return
 	}
 
-	err := store.UpdateUser(id, updates)
+	err := store.DB.UpdateUser(id, updates)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
@@ -150,7 +170,7 @@ func UpdateUserAPI(c *gin.Context) {
 	}
 
 	// Get updated user
-	user, _ := store.GetUserByID(id)
+	user, _ := store.DB.GetUserByID(id)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -171,7 +191,7 @@ func GetUserMessages(c *gin.Context) {
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 
-	messages, err := store.GetMessagesByUser(id, limit, offset)
+	messages, err := store.DB.GetMessagesByUser(id, limit, offset)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
@@ -186,67 +206,115 @@ func GetUserMessages(c *gin.Context) {
 	})
 }
 
-// SendMessageRequest is the request body for sending a message
+// maxUploadSize is the largest file accepted by POST /api/send
+const maxUploadSize = 100 << 20
+
+// SendMessageRequest is the request body for sending a message.
+// It is accepted as JSON or, to upload a file, as multipart/form-data.
 type SendMessageRequest struct {
-	Phone     string `json:"phone" binding:"required"`
-	Message   string `json:"message" binding:"required"`
-	Type      string `json:"type"`       // reply | system | manual
-	AccountID string `json:"account_id"` // Which account to send from
+	Phone     string `json:"phone" form:"phone" binding:"required"`
+	Message   string `json:"message" form:"message"`       // Text, or caption when sending a file
+	Type      string `json:"type" form:"type"`             // reply | system | manual
+	AccountID string `json:"account_id" form:"account_id"` // Which account to send from
+	MediaFile string `json:"media_file" form:"media_file"` // Stored file in MEDIA_DIR
+
+	// Data fills the message placeholders on top of the user's own values (JSON requests only)
+	Data map[string]string `json:"data" form:"-"`
+
+	// Async queues the message and returns a job ID instead of waiting for the send
+	Async    bool   `json:"async" form:"async"`
+	Strategy string `json:"strategy" form:"strategy"` // Async without account_id: last_conversation | any_connected
 }
 
 // SendMessage sends a message to a phone number
 func SendMessage(c *gin.Context) {
 	var req SendMessageRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBind(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": err.Error(),
 		})
 		return
 	}
 
+	if err := personalise(&req); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, template.ErrUnknownPlaceholder) || errors.Is(err, template.ErrMissingValue) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	media, err := mediaFromRequest(c, req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, whatsapp.ErrInvalidMedia) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if req.Message == "" && media == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "message or file is required",
+		})
+		return
+	}
+
 	msgType := req.Type
 	if msgType == "" {
 		msgType = "manual"
 	}
 
+	// Async sends are validated by the queue worker, the job records any rejection
+	if req.Async {
+		enqueueSend(c, req, msgType, media)
+		return
+	}
+
 	// Validate with anti-ban rules
-	result := rules.IsAntiBanSafe(msgType, req.Phone)
-	if !result.CanSend {
+	decision := rules.Evaluate(rules.Request{
+		MsgType:   msgType,
+		Phone:     req.Phone,
+		AccountID: req.AccountID,
+		Text:      req.Message,
+	})
+	if !decision.CanSend {
 		c.JSON(http.StatusForbidden, gin.H{
-			"error":    result.Reason,
+			"error":    decision.Reason,
 			"can_send": false,
+			"trace":    decision.Trace,
 		})
 		return
 	}
 
 	// If account_id is provided, use multi-account manager
 	if req.AccountID != "" {
-		err := whatsapp.Manager.SendMessage(req.AccountID, req.Phone, req.Message)
+		waID, err := whatsapp.Manager.Deliver(req.AccountID, req.Phone, msgType, req.Message, media)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error": err.Error(),
 			})
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{
-			"status":  "sent",
-			"message": "Message sent successfully",
-		})
+		c.JSON(http.StatusOK, sentResponse(waID, media))
 		return
 	}
 
 	// Fallback: try to send via first connected account
 	accounts := whatsapp.Manager.ListAccounts()
 	for _, acc := range accounts {
-		if acc.IsConnected {
-			err := whatsapp.Manager.SendMessage(acc.ID, req.Phone, req.Message)
+		if connected, _ := whatsapp.Manager.IsAccountConnected(acc.ID); connected {
+			waID, err := whatsapp.Manager.Deliver(acc.ID, req.Phone, msgType, req.Message, media)
 			if err != nil {
 				continue
 			}
-			c.JSON(http.StatusOK, gin.H{
-				"status":  "sent",
-				"message": "Message sent successfully",
-			})
+			c.JSON(http.StatusOK, sentResponse(waID, media))
 			return
 		}
 	}
@@ -256,10 +324,129 @@ func SendMessage(c *gin.Context) {
 	})
 }
 
+// personalise renders the placeholders of a send request's message for its recipient
+func personalise(req *SendMessageRequest) error {
+	if len(template.Placeholders(req.Message)) == 0 {
+		return nil
+	}
+	user, err := template.RecipientUser(req.Phone, req.AccountID)
+	if err != nil {
+		return err
+	}
+	known := template.FieldKeys([]*store.User{user}, map[string]map[string]string{req.Phone: req.Data})
+	if err := template.Validate(req.Message, known); err != nil {
+		return err
+	}
+	req.Message, err = template.Render(req.Message, template.Values(req.Phone, user, req.Data))
+	return err
+}
+
+// enqueueSend queues a send request and answers with its job ID
+func enqueueSend(c *gin.Context, req SendMessageRequest, msgType string, attachment *whatsapp.Media) {
+	strategy := req.Strategy
+	if strategy == "" {
+		strategy = whatsapp.SelectAnyConnected
+	}
+	if strategy != whatsapp.SelectAnyConnected && strategy != whatsapp.SelectLastConversation {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "strategy must be last_conversation or any_connected",
+		})
+		return
+	}
+
+	if req.AccountID != "" {
+		if _, exists := whatsapp.Manager.GetAccount(req.AccountID); !exists {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "account not found",
+			})
+			return
+		}
+	}
+
+	job := queue.Job{
+		Phone:     req.Phone,
+		Message:   req.Message,
+		MsgType:   msgType,
+		MediaFile: req.MediaFile,
+		AccountID: req.AccountID,
+		Strategy:  strategy,
+	}
+
+	// Uploads only live for this request, keep them in media storage for the worker
+	if attachment != nil && req.MediaFile == "" {
+		file, err := whatsapp.SaveMedia(req.AccountID, attachment)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		job.MediaID = file.ID
+	}
+
+	queued, err := queue.Enqueue(job)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status": queued.Status,
+		"job_id": queued.ID,
+		"job":    queued,
+	})
+}
+
+// mediaFromRequest returns the uploaded or referenced file of a send request,
+// or nil for a text message
+func mediaFromRequest(c *gin.Context, req SendMessageRequest) (*whatsapp.Media, error) {
+	if c.ContentType() == "multipart/form-data" {
+		header, err := c.FormFile("file")
+		if err == nil {
+			if header.Size > maxUploadSize {
+				return nil, fmt.Errorf("%w: file is larger than %d MB", whatsapp.ErrInvalidMedia, maxUploadSize>>20)
+			}
+			f, err := header.Open()
+			if err != nil {
+				return nil, err
+			}
+			defer f.Close()
+			data, err := io.ReadAll(f)
+			if err != nil {
+				return nil, err
+			}
+			return whatsapp.NewMedia(data, header.Filename, req.Message)
+		}
+		if !errors.Is(err, http.ErrMissingFile) {
+			return nil, err
+		}
+	}
+
+	if req.MediaFile != "" {
+		return whatsapp.LoadMediaFile(req.MediaFile, req.Message)
+	}
+	return nil, nil
+}
+
+func sentResponse(waID string, media *whatsapp.Media) gin.H {
+	resp := gin.H{
+		"status":        "sent",
+		"message":       "Message sent successfully",
+		"wa_message_id": waID,
+	}
+	if media != nil {
+		resp["media_type"] = media.Kind()
+		resp["mime_type"] = media.MimeType
+	}
+	return resp
+}
+
 // GetStats returns dashboard statistics
 func GetStats(c *gin.Context) {
-	users, _ := store.GetUsers()
-	messages, _ := store.GetMessages(1000, 0)
+	users, _ := store.DB.GetUsers()
+	messages, _ := store.DB.GetMessages(1000, 0)
 
 	// Count incoming and outgoing
 	incoming := 0
@@ -293,10 +480,26 @@ func ValidateSend(c *gin.Context) {
 		return
 	}
 
-	result := rules.IsAntiBanSafe(msgType, phone)
+	accountID := c.Query("account_id")
+	quota, err := rules.GetDailyQuota(phone, accountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	decision := rules.Evaluate(rules.Request{
+		MsgType:   msgType,
+		Phone:     phone,
+		AccountID: accountID,
+		Text:      c.Query("message"),
+	})
 	c.JSON(http.StatusOK, gin.H{
-		"can_send": result.CanSend,
-		"reason":   result.Reason,
+		"can_send": decision.CanSend,
+		"reason":   decision.Reason,
+		"trace":    decision.Trace,
+		"quota":    quota,
 	})
 }
 
@@ -304,7 +507,7 @@ func ValidateSend(c *gin.Context) {
 func GetSettings(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
 		"auto_reply_enabled": config.Settings.IsAutoReplyEnabled(),
-		"keywords":           whatsapp.GetKeywords(),
+		"keywords":           whatsapp.GetKeywords(""),
 	})
 }
 
@@ -326,6 +529,12 @@ func UpdateSettings(c *gin.Context) {
 	if req.AutoReplyEnabled != nil {
 		config.Settings.SetAutoReplyEnabled(*req.AutoReplyEnabled)
 	}
+	if err := store.SaveBotSettings(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":            true,
@@ -333,10 +542,20 @@ func UpdateSettings(c *gin.Context) {
 	})
 }
 
+// GetKeywords returns keyword rules, optionally only those visible to one account
+func GetKeywords(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"keywords": whatsapp.GetKeywords(c.Query("account_id")),
+	})
+}
+
 // AddKeywordRequest is the request body for adding a keyword
 type AddKeywordRequest struct {
-	Keyword  string `json:"keyword" binding:"required"`
-	Response string `json:"response" binding:"required"`
+	Keyword   string `json:"keyword" binding:"required"`
+	Response  string `json:"response" binding:"required"`
+	AccountID string `json:"account_id"` // Empty for a global keyword
+	MatchMode string `json:"match_mode"` // exact (default) | contains | prefix | regex | fuzzy
+	Priority  int    `json:"priority"`   // Higher is evaluated first
 }
 
 // AddKeyword adds a new keyword response
@@ -349,15 +568,43 @@ func AddKeyword(c *gin.Context) {
 		return
 	}
 
-	whatsapp.AddKeyword(req.Keyword, req.Response)
+	if req.AccountID != "" {
+		if _, exists := whatsapp.Manager.GetAccount(req.AccountID); !exists {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "account not found",
+			})
+			return
+		}
+	}
+
+	keyword, err := whatsapp.AddKeyword(store.Keyword{
+		AccountID: req.AccountID,
+		Keyword:   req.Keyword,
+		Response:  req.Response,
+		MatchMode: req.MatchMode,
+		Priority:  req.Priority,
+	})
+	if errors.Is(err, whatsapp.ErrInvalidKeyword) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":  true,
-		"keywords": whatsapp.GetKeywords(),
+		"keyword":  keyword,
+		"keywords": whatsapp.GetKeywords(req.AccountID),
 	})
 }
 
-// DeleteKeyword removes a keyword
+// DeleteKeyword removes a keyword from an account (?account_id=) or the global set
 func DeleteKeyword(c *gin.Context) {
 	keyword := c.Param("keyword")
 	if keyword == "" {
@@ -367,18 +614,140 @@ func DeleteKeyword(c *gin.Context) {
 		return
 	}
 
-	whatsapp.RemoveKeyword(keyword)
+	accountID := c.Query("account_id")
+	if err := whatsapp.RemoveKeyword(accountID, keyword); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":  true,
-		"keywords": whatsapp.GetKeywords(),
+		"keywords": whatsapp.GetKeywords(accountID),
+	})
+}
+
+// ============= CONVERSATIONAL FLOWS =============
+
+// GetFlows returns all conversational flows
+func GetFlows(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"flows": flow.Default.List(),
+	})
+}
+
+// GetFlow returns a single flow
+func GetFlow(c *gin.Context) {
+	f, ok := flow.Default.Get(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "flow not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"flow": f,
+	})
+}
+
+// SaveFlow creates a flow (POST /flows) or replaces one (PUT /flows/:id)
+func SaveFlow(c *gin.Context) {
+	var req store.Flow
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if id := c.Param("id"); id != "" {
+		if _, ok := flow.Default.Get(id); !ok {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "flow not found",
+			})
+			return
+		}
+		req.ID = id
+	} else if req.ID != "" {
+		// Creating must not overwrite an existing flow
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "id is assigned on create, use PUT /api/flows/:id to update a flow",
+		})
+		return
+	}
+
+	if req.AccountID != "" {
+		if _, exists := whatsapp.Manager.GetAccount(req.AccountID); !exists {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "account not found",
+			})
+			return
+		}
+	}
+
+	saved, err := flow.Default.Save(req)
+	if errors.Is(err, flow.ErrInvalidFlow) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, flow.ErrDuplicateTrigger) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"flow":    saved,
+	})
+}
+
+// DeleteFlow deletes a flow
+func DeleteFlow(c *gin.Context) {
+	id := c.Param("id")
+	if _, ok := flow.Default.Get(id); !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "flow not found",
+		})
+		return
+	}
+
+	if err := flow.Default.Delete(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"flows":   flow.Default.List(),
 	})
 }
 
 // GetTemplates returns all templates
 func GetTemplates(c *gin.Context) {
+	templates, err := store.DB.GetTemplates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"templates": store.GetTemplates(),
+		"templates": templates,
 	})
 }
 
@@ -398,11 +767,17 @@ func AddTemplate(c *gin.Context) {
 		return
 	}
 
-	store.AddTemplate(req.Name, req.Content)
+	if _, err := store.DB.AddTemplate(req.Name, req.Content); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
+	templates, _ := store.DB.GetTemplates()
 	c.JSON(http.StatusOK, gin.H{
 		"success":   true,
-		"templates": store.GetTemplates(),
+		"templates": templates,
 	})
 }
 
@@ -416,18 +791,32 @@ func DeleteTemplate(c *gin.Context) {
 		return
 	}
 
-	store.DeleteTemplate(id)
+	if err := store.DB.DeleteTemplate(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
+	templates, _ := store.DB.GetTemplates()
 	c.JSON(http.StatusOK, gin.H{
 		"success":   true,
-		"templates": store.GetTemplates(),
+		"templates": templates,
 	})
 }
 
 // GetScheduled returns all scheduled messages
 func GetScheduled(c *gin.Context) {
+	scheduled, err := store.DB.GetScheduled()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"scheduled": store.GetScheduled(),
+		"scheduled": scheduled,
 	})
 }
 
@@ -436,6 +825,7 @@ type AddScheduledRequest struct {
 	Phone       string `json:"phone" binding:"required"`
 	Message     string `json:"message" binding:"required"`
 	ScheduledAt string `json:"scheduled_at" binding:"required"`
+	AccountID   string `json:"account_id"` // Optional, defaults to the user's last conversation
 }
 
 // AddScheduled schedules a new message
@@ -448,11 +838,33 @@ func AddScheduled(c *gin.Context) {
 		return
 	}
 
-	store.AddScheduled(req.Phone, req.Message, req.ScheduledAt)
+	if _, err := time.Parse(time.RFC3339, req.ScheduledAt); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "scheduled_at must be an RFC3339 timestamp",
+		})
+		return
+	}
+
+	if req.AccountID != "" {
+		if _, exists := whatsapp.Manager.GetAccount(req.AccountID); !exists {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "account not found",
+			})
+			return
+		}
+	}
+
+	if _, err := store.DB.AddScheduled(req.Phone, req.Message, req.ScheduledAt, req.AccountID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
+	scheduled, _ := store.DB.GetScheduled()
 	c.JSON(http.StatusOK, gin.H{
 		"success":   true,
-		"scheduled": store.GetScheduled(),
+		"scheduled": scheduled,
 	})
 }
 
@@ -466,11 +878,17 @@ func DeleteScheduled(c *gin.Context) {
 		return
 	}
 
-	store.DeleteScheduled(id)
+	if err := store.DB.DeleteScheduled(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
+	scheduled, _ := store.DB.GetScheduled()
 	c.JSON(http.StatusOK, gin.H{
 		"success":   true,
-		"scheduled": store.GetScheduled(),
+		"scheduled": scheduled,
 	})
 }
 
@@ -486,7 +904,8 @@ func GetAccounts(c *gin.Context) {
 
 // AddAccountRequest is the request body for adding an account
 type AddAccountRequest struct {
-	Name string `json:"name" binding:"required"`
+	Name   string   `json:"name" binding:"required"`
+	Labels []string `json:"labels"`
 }
 
 // AddAccount adds a new WhatsApp account
@@ -499,7 +918,7 @@ func AddAccount(c *gin.Context) {
 		return
 	}
 
-	account, err := whatsapp.Manager.AddAccount(req.Name)
+	account, err := whatsapp.Manager.AddAccount(req.Name, req.Labels)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": err.Error(),
@@ -531,6 +950,11 @@ func DeleteAccount(c *gin.Context) {
 		return
 	}
 
+	config.DeleteAccountOverride(id)
+	if err := store.SaveAccountSettings(); err != nil {
+		log.Printf("❌ Failed to save account settings: %v", err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success":  true,
 		"accounts": whatsapp.Manager.ListAccounts(),
@@ -556,25 +980,81 @@ func GetAccountStatus(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"account": account,
+		"account":     account,
+		"rate_limits": whatsapp.Limiter.Status(account.ID),
 	})
 }
 
-// ConnectAccount connects a specific account
-func ConnectAccount(c *gin.Context) {
+// GetAccountSettings returns an account's overrides and the settings in effect for it
+func GetAccountSettings(c *gin.Context) {
 	id := c.Param("id")
-	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "id is required",
+	if _, exists := whatsapp.Manager.GetAccount(id); !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "account not found",
 		})
 		return
 	}
 
-	err := whatsapp.Manager.ConnectAccount(id)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": err.Error(),
-		})
+	c.JSON(http.StatusOK, gin.H{
+		"account_id": id,
+		"overrides":  config.GetAccountOverride(id),
+		"settings":   config.SettingsFor(id).All(),
+	})
+}
+
+// UpdateAccountSettings replaces an account's overrides; null fields inherit the global settings
+func UpdateAccountSettings(c *gin.Context) {
+	id := c.Param("id")
+	if _, exists := whatsapp.Manager.GetAccount(id); !exists {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "account not found",
+		})
+		return
+	}
+
+	var req config.SettingsOverride
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err := config.SetAccountOverride(id, req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err := store.SaveAccountSettings(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"account_id": id,
+		"overrides":  config.GetAccountOverride(id),
+		"settings":   config.SettingsFor(id).All(),
+	})
+}
+
+// ConnectAccount connects a specific account
+func ConnectAccount(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "id is required",
+		})
+		return
+	}
+
+	err := whatsapp.Manager.ConnectAccount(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
 		return
 	}
 
@@ -610,18 +1090,36 @@ func DisconnectAccount(c *gin.Context) {
 
 // GetBroadcasts returns all broadcasts
 func GetBroadcasts(c *gin.Context) {
+	broadcasts, err := store.DB.GetBroadcasts()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"broadcasts": store.GetBroadcasts(),
+		"broadcasts": broadcasts,
 	})
 }
 
 // CreateBroadcastRequest is the request body for creating a broadcast
 type CreateBroadcastRequest struct {
 	Name       string   `json:"name" binding:"required"`
-	Message    string   `json:"message" binding:"required"`
+	Message    string   `json:"message"` // Text, or caption when media_file is set
 	AccountID  string   `json:"account_id" binding:"required"`
-	Recipients []string `json:"recipients" binding:"required"`
+	Recipients []string `json:"recipients"` // Phone numbers, or use audience
 	DelayMs    int      `json:"delay_ms"`
+	MediaFile  string   `json:"media_file"` // Stored file in MEDIA_DIR
+
+	// ScheduledAt starts the broadcast automatically, RFC3339
+	ScheduledAt string `json:"scheduled_at"`
+
+	// Audience selects the recipients from the users table when the broadcast starts
+	Audience *store.Audience `json:"audience"`
+
+	// Data holds placeholder values per recipient phone, on top of the users' own values
+	Data map[string]map[string]string `json:"data"`
 }
 
 // CreateBroadcast creates a new broadcast
@@ -634,20 +1132,179 @@ func CreateBroadcast(c *gin.Context) {
 		return
 	}
 
+	if req.Message == "" && req.MediaFile == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "message or media_file is required",
+		})
+		return
+	}
+	if (len(req.Recipients) == 0) == (req.Audience == nil) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "either recipients or audience is required",
+		})
+		return
+	}
+
+	// A broadcast from an unknown account would only fail at start
+	for _, accountID := range []string{req.AccountID, audienceAccount(req.Audience)} {
+		if accountID == "" {
+			continue
+		}
+		if _, exists := whatsapp.Manager.GetAccount(accountID); !exists {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("account %s not found", accountID),
+			})
+			return
+		}
+	}
+
+	// Fail early if the attachment cannot be read
+	if req.MediaFile != "" {
+		if _, err := whatsapp.LoadMediaFile(req.MediaFile, req.Message); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, whatsapp.ErrInvalidMedia) {
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	var scheduledAt *string
+	if req.ScheduledAt != "" {
+		t, err := time.Parse(time.RFC3339, req.ScheduledAt)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "scheduled_at must be an RFC3339 timestamp",
+			})
+			return
+		}
+		utc := t.UTC().Format(time.RFC3339) // Compared as text by the scheduler
+		scheduledAt = &utc
+	}
+
 	if req.DelayMs == 0 {
 		req.DelayMs = 5000 // Default 5 second delay
 	}
 
-	broadcast := store.CreateBroadcast(req.Name, req.Message, req.AccountID, req.Recipients, req.DelayMs)
+	b := &store.Broadcast{
+		Name:       req.Name,
+		Message:    req.Message,
+		AccountID:  req.AccountID,
+		Recipients: req.Recipients,
+		DelayMs:    req.DelayMs,
+		MediaFile:  req.MediaFile,
+		Data:       req.Data,
+		Audience:   req.Audience,
+
+		ScheduledAt: scheduledAt,
+	}
+
+	// Placeholders are checked against the users the audience matches today
+	check := *b
+	if req.Audience != nil {
+		users, err := broadcast.Resolve(req.Audience)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, broadcast.ErrInvalidAudience) {
+				status = http.StatusBadRequest
+			}
+			c.JSON(status, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		check.Recipients = broadcast.Phones(users)
+	}
+	if err := broadcast.Validate(&check); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, template.ErrUnknownPlaceholder) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	created, err := store.DB.CreateBroadcast(b)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":   true,
-		"broadcast": broadcast,
+		"broadcast": created,
+	})
+}
+
+// audienceAccount returns the account an audience is limited to, if any
+func audienceAccount(audience *store.Audience) string {
+	if audience == nil {
+		return ""
+	}
+	return audience.AccountID
+}
+
+// PreviewAudience returns how many users an audience matches now, with a sample
+func PreviewAudience(c *gin.Context) {
+	var audience store.Audience
+	if err := c.ShouldBindJSON(&audience); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	users, err := broadcast.Resolve(&audience)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, broadcast.ErrInvalidAudience) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	sample := users
+	if len(sample) > 10 {
+		sample = sample[:10]
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"count":  len(users),
+		"sample": sample,
 	})
 }
 
 // StartBroadcast starts a broadcast
 func StartBroadcast(c *gin.Context) {
+	controlBroadcast(c, broadcast.Start)
+}
+
+// PauseBroadcast pauses a running broadcast
+func PauseBroadcast(c *gin.Context) {
+	controlBroadcast(c, broadcast.Pause)
+}
+
+// ResumeBroadcast resumes a paused broadcast
+func ResumeBroadcast(c *gin.Context) {
+	controlBroadcast(c, broadcast.Resume)
+}
+
+// CancelBroadcast cancels a broadcast, keeping its counters
+func CancelBroadcast(c *gin.Context) {
+	controlBroadcast(c, broadcast.Stop)
+}
+
+// controlBroadcast applies a broadcast engine action and returns the updated broadcast
+func controlBroadcast(c *gin.Context, action func(id string) error) {
 	id := c.Param("id")
 	if id == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -656,43 +1313,76 @@ func StartBroadcast(c *gin.Context) {
 		return
 	}
 
-	// Import and start broadcast
-	go func() {
-		broadcast, exists := store.GetBroadcast(id)
-		if !exists {
-			return
+	if err := action(id); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, broadcast.ErrNotFound):
+			status = http.StatusNotFound
+		case errors.Is(err, broadcast.ErrAlreadyRunning), errors.Is(err, broadcast.ErrInvalidState):
+			status = http.StatusConflict
+		case errors.Is(err, whatsapp.ErrInvalidMedia), errors.Is(err, template.ErrUnknownPlaceholder),
+			errors.Is(err, broadcast.ErrInvalidAudience):
+			status = http.StatusBadRequest
 		}
-		store.UpdateBroadcast(id, 0, 0, "running")
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
-		// Simple inline runner
-		sent := 0
-		failed := 0
-		for _, phone := range broadcast.Recipients {
-			err := whatsapp.Manager.SendMessage(broadcast.AccountID, phone, broadcast.Message)
-			if err != nil {
-				failed++
-			} else {
-				sent++
-			}
-			store.UpdateBroadcast(id, sent, failed, "running")
-			// Rate limit
-			select {
-			case <-c.Request.Context().Done():
-				return
-			default:
-				// Delay between messages (minimum 3 seconds)
-				delay := broadcast.DelayMs
-				if delay < 3000 {
-					delay = 3000
-				}
-				<-time.After(time.Duration(delay) * time.Millisecond)
-			}
-		}
-		store.UpdateBroadcast(id, sent, failed, "completed")
-	}()
+	b, _ := store.DB.GetBroadcast(id)
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"broadcast": b,
+	})
+}
+
+// RetryFailedBroadcast sends a broadcast again to its failed recipients
+func RetryFailedBroadcast(c *gin.Context) {
+	controlBroadcast(c, func(id string) error {
+		_, err := broadcast.RetryFailed(id)
+		return err
+	})
+}
+
+// GetBroadcastRecipients returns the delivery log of a broadcast. Query
+// parameters status, receipt and phone filter it; limit and offset page it.
+func GetBroadcastRecipients(c *gin.Context) {
+	id := c.Param("id")
+	b, err := store.DB.GetBroadcast(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if b == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "broadcast not found",
+		})
+		return
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	recipients, err := store.DB.GetBroadcastRecipients(id, store.RecipientFilter{
+		Status:  c.Query("status"),
+		Receipt: c.Query("receipt"),
+		Phone:   c.Query("phone"),
+		Limit:   limit,
+		Offset:  offset,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
+		"recipients": recipients,
+		"limit":      limit,
+		"offset":     offset,
 	})
 }
 
@@ -706,8 +1396,14 @@ func GetBroadcastStatus(c *gin.Context) {
 		return
 	}
 
-	broadcast, exists := store.GetBroadcast(id)
-	if !exists {
+	broadcast, err := store.DB.GetBroadcast(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if broadcast == nil {
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": "broadcast not found",
 		})
@@ -719,7 +1415,10 @@ func GetBroadcastStatus(c *gin.Context) {
 	})
 }
 
-// DeleteBroadcast deletes a broadcast
+// stopWait is how long deleting a running broadcast waits for its run to exit
+const stopWait = 15 * time.Second
+
+// DeleteBroadcast deletes a broadcast, once its run, if any, has exited
 func DeleteBroadcast(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
@@ -729,14 +1428,185 @@ func DeleteBroadcast(c *gin.Context) {
 		return
 	}
 
-	store.DeleteBroadcast(id)
+	// Halt a run and let it exit before its row disappears
+	if err := broadcast.StopAndWait(id, stopWait); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, broadcast.ErrStillRunning) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := store.DB.DeleteBroadcast(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	broadcasts, _ := store.DB.GetBroadcasts()
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"broadcasts": broadcasts,
+	})
+}
+
+// ============= MEDIA =============
+
+// GetMedia streams a stored media file
+func GetMedia(c *gin.Context) {
+	id := c.Param("id")
+
+	file, err := store.DB.GetMediaFile(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if file == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "media not found",
+		})
+		return
+	}
+
+	reader, err := media.Default.Open(file.StorageKey)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "media content not available",
+		})
+		return
+	}
+	defer reader.Close()
+
+	c.DataFromReader(http.StatusOK, file.Size, file.MimeType, reader, map[string]string{
+		"Content-Disposition": fmt.Sprintf("inline; filename=%q", file.FileName),
+	})
+}
+
+// GetMediaURL returns a short-lived URL of a stored media file that needs no
+// Authorization header, for <img> and <video> tags
+func GetMediaURL(c *gin.Context) {
+	id := c.Param("id")
+
+	file, err := store.DB.GetMediaFile(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if file == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "media not found",
+		})
+		return
+	}
 
+	expiresAt := time.Now().Add(mediaURLTTL)
 	c.JSON(http.StatusOK, gin.H{
 		"success":    true,
-		"broadcasts": store.GetBroadcasts(),
+		"url":        signMediaURL(id, expiresAt),
+		"expires_at": expiresAt.UTC().Format(time.RFC3339),
+	})
+}
+
+// ============= JOBS =============
+
+// GetJobs lists queued jobs; ?status=dead returns failed and rejected jobs
+func GetJobs(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	var statuses []string
+	switch status := c.Query("status"); status {
+	case "":
+	case "dead":
+		statuses = queue.DeadStatuses
+	default:
+		statuses = []string{status}
+	}
+
+	jobs, err := queue.List(statuses, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":  jobs,
+		"limit": limit,
+	})
+}
+
+// GetJob returns a job with its status, rejection reason or error (last_error)
+// and the WhatsApp message ID once sent
+func GetJob(c *gin.Context) {
+	job, err := store.DB.GetJob(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if job == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "job not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"job": job,
+	})
+}
+
+// RetryJob puts a dead job back in the queue
+func RetryJob(c *gin.Context) {
+	job, err := queue.Retry(c.Param("id"))
+	if err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"job":     job,
+	})
+}
+
+// DiscardJob deletes a dead job
+func DiscardJob(c *gin.Context) {
+	if err := queue.Discard(c.Param("id")); err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
 	})
 }
 
+func jobErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, queue.ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, queue.ErrJobNotDead):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
 // ============= EXTENDED SETTINGS =============
 
 // GetAllSettings returns all settings including away message
@@ -746,14 +1616,21 @@ func GetAllSettings(c *gin.Context) {
 
 // UpdateAllSettingsRequest is the request for updating all settings
 type UpdateAllSettingsRequest struct {
-	AutoReplyEnabled  *bool   `json:"auto_reply_enabled"`
-	AwayEnabled       *bool   `json:"away_enabled"`
-	AwayMessage       *string `json:"away_message"`
-	OperatingStart    *int    `json:"operating_start"`
-	OperatingEnd      *int    `json:"operating_end"`
-	MinDelayMs        *int    `json:"min_delay_ms"`
-	MaxDelayMs        *int    `json:"max_delay_ms"`
-	DailyLimitPerUser *int    `json:"daily_limit_per_user"`
+	AutoReplyEnabled  *bool                 `json:"auto_reply_enabled"`
+	AwayEnabled       *bool                 `json:"away_enabled"`
+	AwayMessage       *string               `json:"away_message"`
+	OperatingStart    *int                  `json:"operating_start"`
+	OperatingEnd      *int                  `json:"operating_end"`
+	BusinessHours     *config.BusinessHours `json:"business_hours"`
+	MinDelayMs        *int                  `json:"min_delay_ms"`
+	MaxDelayMs        *int                  `json:"max_delay_ms"`
+	DailyLimitPerUser *int                  `json:"daily_limit_per_user"`
+	AccountPerMinute  *int                  `json:"account_per_minute"`
+	AccountPerHour    *int                  `json:"account_per_hour"`
+	AccountPerDay     *int                  `json:"account_per_day"`
+	FrequencyCapMin   *int                  `json:"frequency_cap_minutes"`
+	BlockedWords      []string              `json:"blocked_words"`
+	Policies          map[string][]string   `json:"policies"` // Replaces the checks of the listed message types
 }
 
 // UpdateAllSettings updates all settings
@@ -766,6 +1643,31 @@ func UpdateAllSettings(c *gin.Context) {
 		return
 	}
 
+	if err := rules.ValidatePolicies(req.Policies); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// Business hours are merged and validated first so a bad calendar changes nothing
+	operating := req.OperatingStart != nil && req.OperatingEnd != nil
+	if req.BusinessHours != nil || operating {
+		hours := config.Settings.GetBusinessHours()
+		if req.BusinessHours != nil {
+			hours = *req.BusinessHours
+		}
+		if operating {
+			hours = hours.WithDailyHours(*req.OperatingStart, *req.OperatingEnd)
+		}
+		if err := config.Settings.SetBusinessHours(hours); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
 	if req.AutoReplyEnabled != nil {
 		config.Settings.SetAutoReplyEnabled(*req.AutoReplyEnabled)
 	}
@@ -775,9 +1677,6 @@ func UpdateAllSettings(c *gin.Context) {
 	if req.AwayMessage != nil {
 		config.Settings.SetAwayMessage(*req.AwayMessage)
 	}
-	if req.OperatingStart != nil && req.OperatingEnd != nil {
-		config.Settings.SetOperatingHours(*req.OperatingStart, *req.OperatingEnd)
-	}
 	if req.MinDelayMs != nil || req.MaxDelayMs != nil || req.DailyLimitPerUser != nil {
 		minDelay, maxDelay, dailyLimit := config.Settings.GetRateLimits()
 		if req.MinDelayMs != nil {
@@ -791,6 +1690,38 @@ func UpdateAllSettings(c *gin.Context) {
 		}
 		config.Settings.SetRateLimits(minDelay, maxDelay, dailyLimit)
 	}
+	if req.AccountPerMinute != nil || req.AccountPerHour != nil || req.AccountPerDay != nil {
+		perMinute, perHour, perDay := config.Settings.GetAccountLimits()
+		if req.AccountPerMinute != nil {
+			perMinute = *req.AccountPerMinute
+		}
+		if req.AccountPerHour != nil {
+			perHour = *req.AccountPerHour
+		}
+		if req.AccountPerDay != nil {
+			perDay = *req.AccountPerDay
+		}
+		config.Settings.SetAccountLimits(perMinute, perHour, perDay)
+	}
+	if req.FrequencyCapMin != nil || req.BlockedWords != nil {
+		frequencyCap, blockedWords := config.Settings.GetContentRules()
+		if req.FrequencyCapMin != nil {
+			frequencyCap = *req.FrequencyCapMin
+		}
+		if req.BlockedWords != nil {
+			blockedWords = req.BlockedWords
+		}
+		config.Settings.SetContentRules(frequencyCap, blockedWords)
+	}
+	if len(req.Policies) > 0 {
+		config.Settings.SetPolicies(req.Policies)
+	}
+	if err := store.SaveBotSettings(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":  true,
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"esther-whatsapp/internal/config"
+	"esther-whatsapp/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TestMain serves the API from a throwaway SQLite store
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	dir, err := os.MkdirTemp("", "api-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.AppConfig = &config.Config{StoreDriver: "sqlite", SQLitePath: filepath.Join(dir, "test.db"), MaxSystemMsgPerDay: 1}
+	store.DB, err = store.NewSQLite(config.AppConfig.SQLitePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+var phoneSeq atomic.Int64
+
+func init() {
+	phoneSeq.Store(time.Now().UnixNano() % 1e9)
+}
+
+// testPhone returns a phone no other test or run has used
+func testPhone() string {
+	return fmt.Sprintf("628%d", phoneSeq.Add(1))
+}
+
+// serve sends a request with an optional JSON body through the router and
+// decodes the JSON response into out when it is not nil
+func serve(t *testing.T, method, url string, body interface{}, out interface{}) int {
+	t.Helper()
+	var reader *bytes.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(b)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, url, reader)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	SetupRouter().ServeHTTP(w, req)
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: invalid JSON %q: %v", method, url, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+// logSent records a message sent to a phone from an account now
+func logSent(t *testing.T, phone, accountID, msgType string) {
+	t.Helper()
+	user, err := store.DB.GetUserByPhoneAndAccount(phone, accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user == nil {
+		if user, err = store.DB.CreateUserWithAccount(phone, nil, accountID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.DB.LogMessageWithAccount(user.ID, accountID, "outgoing", msgType, "Halo", nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestValidateReturnsTheRemainingQuota(t *testing.T) {
+	limit := 3
+	if err := config.SetAccountOverride("acc-v", config.SettingsOverride{DailyLimitPerUser: &limit}); err != nil {
+		t.Fatal(err)
+	}
+	defer config.DeleteAccountOverride("acc-v")
+
+	phone := testPhone()
+	logSent(t, phone, "acc-v", "manual")
+	logSent(t, phone, "acc-v", "reply")
+
+	tests := []struct {
+		name      string
+		accountID string
+		limit     int
+		remaining int
+	}{
+		{"account limit", "acc-v", 3, 2},
+		{"global limit", "", 5, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp struct {
+				CanSend bool `json:"can_send"`
+				Trace   []struct {
+					Check string `json:"check"`
+				} `json:"trace"`
+				Quota struct {
+					Limit     int    `json:"limit"`
+					Used      int    `json:"used"`
+					Remaining int    `json:"remaining"`
+					ResetsAt  string `json:"resets_at"`
+				} `json:"quota"`
+			}
+			url := fmt.Sprintf("/api/validate?phone=%s&type=manual&account_id=%s", phone, tt.accountID)
+			if code := serve(t, http.MethodGet, url, nil, &resp); code != http.StatusOK {
+				t.Fatalf("GET %s = %d", url, code)
+			}
+			if resp.Quota.Limit != tt.limit || resp.Quota.Used != 1 || resp.Quota.Remaining != tt.remaining || resp.Quota.ResetsAt == "" {
+				t.Fatalf("quota = %+v, want %d of %d left", resp.Quota, tt.remaining, tt.limit)
+			}
+			if len(resp.Trace) == 0 {
+				t.Fatal("response has no trace")
+			}
+		})
+	}
+}
+
+func TestValidateRequiresAPhone(t *testing.T) {
+	if code := serve(t, http.MethodGet, "/api/validate?type=manual", nil, nil); code != http.StatusBadRequest {
+		t.Fatalf("GET /api/validate without phone = %d, want %d", code, http.StatusBadRequest)
+	}
+}
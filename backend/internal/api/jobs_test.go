@@ -0,0 +1,58 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"esther-whatsapp/internal/queue"
+	"esther-whatsapp/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAsyncSendReturnsAJob(t *testing.T) {
+	phone := testPhone()
+	var sent struct {
+		Status string     `json:"status"`
+		JobID  string     `json:"job_id"`
+		Job    *store.Job `json:"job"`
+	}
+	code := serve(t, http.MethodPost, "/api/send", gin.H{"phone": phone, "message": "Halo", "async": true}, &sent)
+	if code != http.StatusAccepted || sent.Status != queue.StatusQueued || sent.JobID == "" {
+		t.Fatalf("POST /api/send = %d, %+v, want 202 with a queued job", code, sent)
+	}
+	defer store.DB.DeleteJob(sent.JobID)
+	if sent.Job.MsgType != "manual" || sent.Job.Strategy != "any_connected" || sent.Job.Lane != queue.LaneManual {
+		t.Fatalf("job = %+v, want a manual job for any connected account", sent.Job)
+	}
+
+	var got struct {
+		Job *store.Job `json:"job"`
+	}
+	if code := serve(t, http.MethodGet, "/api/jobs/"+sent.JobID, nil, &got); code != http.StatusOK || got.Job == nil || got.Job.Phone != phone {
+		t.Fatalf("GET /api/jobs/%s = %d, %+v", sent.JobID, code, got.Job)
+	}
+}
+
+func TestAsyncSendErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		body gin.H
+		want int
+	}{
+		{"unknown strategy", gin.H{"phone": testPhone(), "message": "Halo", "async": true, "strategy": "round_robin"}, http.StatusBadRequest},
+		{"unknown account", gin.H{"phone": testPhone(), "message": "Halo", "async": true, "account_id": "acc-missing"}, http.StatusNotFound},
+		{"nothing to send", gin.H{"phone": testPhone(), "async": true}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := serve(t, http.MethodPost, "/api/send", tt.body, nil); code != tt.want {
+				t.Fatalf("POST /api/send = %d, want %d", code, tt.want)
+			}
+		})
+	}
+
+	if code := serve(t, http.MethodGet, "/api/jobs/missing", nil, nil); code != http.StatusNotFound {
+		t.Fatalf("GET /api/jobs/missing = %d, want %d", code, http.StatusNotFound)
+	}
+}
@@ -37,9 +37,21 @@ func SetupRouter() *gin.Engine {
 		api.POST("/settings", UpdateAllSettings)
 
 		// Keywords
+		api.GET("/keywords", GetKeywords)
 		api.POST("/keywords", AddKeyword)
 		api.DELETE("/keywords/:keyword", DeleteKeyword)
 
+		// Conversational flows
+		api.GET("/flows", GetFlows)
+		api.POST("/flows", SaveFlow)
+		api.GET("/flows/:id", GetFlow)
+		api.PUT("/flows/:id", SaveFlow)
+		api.DELETE("/flows/:id", DeleteFlow)
+
+		// Stored media (received attachments)
+		api.GET("/media/:id", RequireMediaAccess(), GetMedia)
+		api.GET("/media/:id/url", RequireToken(), GetMediaURL)
+
 		// Templates
 		api.GET("/templates", GetTemplates)
 		api.POST("/templates", AddTemplate)
@@ -50,11 +62,23 @@ func SetupRouter() *gin.Engine {
 		api.POST("/scheduled", AddScheduled)
 		api.DELETE("/scheduled/:id", DeleteScheduled)
 
+		// Send queue
+		api.GET("/jobs", GetJobs)
+		api.GET("/jobs/:id", GetJob)
+		api.POST("/jobs/:id/retry", RetryJob)
+		api.DELETE("/jobs/:id", DiscardJob)
+
 		// Broadcast
 		api.GET("/broadcasts", GetBroadcasts)
 		api.POST("/broadcasts", CreateBroadcast)
+		api.POST("/broadcasts/preview", PreviewAudience)
 		api.GET("/broadcasts/:id", GetBroadcastStatus)
 		api.POST("/broadcasts/:id/start", StartBroadcast)
+		api.POST("/broadcasts/:id/pause", PauseBroadcast)
+		api.POST("/broadcasts/:id/resume", ResumeBroadcast)
+		api.POST("/broadcasts/:id/cancel", CancelBroadcast)
+		api.POST("/broadcasts/:id/retry-failed", RetryFailedBroadcast)
+		api.GET("/broadcasts/:id/recipients", GetBroadcastRecipients)
 		api.DELETE("/broadcasts/:id", DeleteBroadcast)
 
 		// Account management (multi-account)
@@ -62,6 +86,8 @@ func SetupRouter() *gin.Engine {
 		api.POST("/accounts", AddAccount)
 		api.DELETE("/accounts/:id", DeleteAccount)
 		api.GET("/accounts/:id/status", GetAccountStatus)
+		api.GET("/accounts/:id/settings", GetAccountSettings)
+		api.POST("/accounts/:id/settings", UpdateAccountSettings)
 		api.POST("/accounts/:id/connect", ConnectAccount)
 		api.POST("/accounts/:id/disconnect", DisconnectAccount)
 		api.GET("/accounts/:id/qr", HandleAccountQRWebSocket)
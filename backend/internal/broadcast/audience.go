@@ -0,0 +1,139 @@
+package broadcast
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"esther-whatsapp/internal/config"
+	"esther-whatsapp/internal/store"
+)
+
+// ErrInvalidAudience is returned when an audience filter cannot be applied
+var ErrInvalidAudience = errors.New("invalid audience")
+
+// ValidateAudience checks the filters of an audience
+func ValidateAudience(a *store.Audience) error {
+	if a.InteractedWithinDays < 0 {
+		return fmt.Errorf("%w: interacted_within_days must not be negative", ErrInvalidAudience)
+	}
+	if _, err := parseDate(a.CreatedAfter); err != nil {
+		return fmt.Errorf("%w: created_after: %v", ErrInvalidAudience, err)
+	}
+	if _, err := parseDate(a.CreatedBefore); err != nil {
+		return fmt.Errorf("%w: created_before: %v", ErrInvalidAudience, err)
+	}
+	return nil
+}
+
+// Resolve returns the users matching an audience right now, one per phone.
+// Users are ordered newest first, like the users list.
+func Resolve(a *store.Audience) ([]store.User, error) {
+	if err := ValidateAudience(a); err != nil {
+		return nil, err
+	}
+
+	var users []store.User
+	var err error
+	if a.AccountID != "" {
+		users, err = store.DB.GetUsersByAccount(a.AccountID)
+	} else {
+		users, err = store.DB.GetUsers()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	createdAfter, _ := parseDate(a.CreatedAfter)
+	createdBefore, _ := parseDate(a.CreatedBefore)
+	var interactedAfter time.Time
+	if a.InteractedWithinDays > 0 {
+		interactedAfter = time.Now().AddDate(0, 0, -a.InteractedWithinDays)
+	}
+
+	matched := make([]store.User, 0, len(users))
+	seen := make(map[string]bool)
+	for _, u := range users {
+		if seen[u.Phone] {
+			continue
+		}
+		if !a.IncludeOptedOut && !u.OptIn {
+			continue
+		}
+		if !a.IncludeBlocked && u.Blocked {
+			continue
+		}
+		if !hasTags(u.Tags, a.Tags) {
+			continue
+		}
+		if !interactedAfter.IsZero() && !after(u.LastUserMessageAt, interactedAfter) {
+			continue
+		}
+		created := u.CreatedAt
+		if !createdAfter.IsZero() && !after(&created, createdAfter) {
+			continue
+		}
+		if !createdBefore.IsZero() && after(&created, createdBefore) {
+			continue
+		}
+		seen[u.Phone] = true
+		matched = append(matched, u)
+	}
+	return matched, nil
+}
+
+// Phones returns the phone numbers of users
+func Phones(users []store.User) []string {
+	phones := make([]string, 0, len(users))
+	for _, u := range users {
+		phones = append(phones, u.Phone)
+	}
+	return phones
+}
+
+// hasTags reports whether tags contains every wanted tag, ignoring case
+func hasTags(tags, wanted []string) bool {
+	for _, w := range wanted {
+		found := false
+		for _, t := range tags {
+			if strings.EqualFold(strings.TrimSpace(t), strings.TrimSpace(w)) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// after reports whether a stored timestamp is at or after t; unset or
+// unreadable timestamps never are
+func after(timestamp *string, t time.Time) bool {
+	if timestamp == nil {
+		return false
+	}
+	ts, err := time.Parse(time.RFC3339, *timestamp)
+	if err != nil {
+		return false
+	}
+	return !ts.Before(t)
+}
+
+// parseDate reads a YYYY-MM-DD date in the business timezone or an RFC3339
+// time. Empty is the zero time.
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, config.Location()); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a YYYY-MM-DD date or RFC3339 time", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,89 @@
+package broadcast
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"esther-whatsapp/internal/store"
+)
+
+// audienceUser stores a user of an account with the given column values
+func audienceUser(t *testing.T, accountID, phone string, updates map[string]interface{}) {
+	t.Helper()
+	u, err := store.DB.CreateUserWithAccount(phone, nil, accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DB.UpdateUser(u.ID, updates); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestResolveAudience(t *testing.T) {
+	account := fmt.Sprintf("aud-%d", time.Now().UnixNano())
+	other := account + "-other"
+	longAgo := time.Now().AddDate(0, 0, -10).UTC().Format(time.RFC3339)
+
+	audienceUser(t, account, "62801", map[string]interface{}{"tags": []string{"VIP", "jakarta"}, "last_user_message_at": "now()"})
+	audienceUser(t, account, "62802", map[string]interface{}{"tags": []string{"vip"}, "last_user_message_at": longAgo})
+	audienceUser(t, account, "62803", map[string]interface{}{"tags": []string{"vip"}, "opt_in": false})
+	audienceUser(t, account, "62804", map[string]interface{}{"tags": []string{"vip"}, "blocked": true})
+	audienceUser(t, other, "62805", map[string]interface{}{"tags": []string{"vip"}})
+
+	tomorrow := time.Now().AddDate(0, 0, 1).Format(time.DateOnly)
+	tests := []struct {
+		name     string
+		audience store.Audience
+		want     []string
+	}{
+		{"account", store.Audience{AccountID: account}, []string{"62801", "62802"}},
+		{"tags ignore case", store.Audience{AccountID: account, Tags: []string{" Vip ", "JAKARTA"}}, []string{"62801"}},
+		{"opted out included", store.Audience{AccountID: account, IncludeOptedOut: true}, []string{"62801", "62802", "62803"}},
+		{"blocked included", store.Audience{AccountID: account, IncludeBlocked: true}, []string{"62801", "62802", "62804"}},
+		{"interacted recently", store.Audience{AccountID: account, InteractedWithinDays: 7}, []string{"62801"}},
+		{"created after tomorrow", store.Audience{AccountID: account, CreatedAfter: tomorrow}, nil},
+		{"created before tomorrow", store.Audience{AccountID: account, CreatedBefore: tomorrow}, []string{"62801", "62802"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users, err := Resolve(&tt.audience)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := Phones(users)
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Fatalf("Resolve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveAudienceOncePerPhone(t *testing.T) {
+	account := fmt.Sprintf("aud-%d", time.Now().UnixNano())
+	audienceUser(t, account+"-a", "62811", map[string]interface{}{"tags": []string{"dup"}})
+	audienceUser(t, account+"-b", "62811", map[string]interface{}{"tags": []string{"dup"}})
+
+	users, err := Resolve(&store.Audience{Tags: []string{"dup"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if phones := Phones(users); !slices.Equal(phones, []string{"62811"}) {
+		t.Fatalf("Resolve() across accounts = %v, want the phone once", phones)
+	}
+}
+
+func TestInvalidAudience(t *testing.T) {
+	for _, a := range []store.Audience{
+		{InteractedWithinDays: -1},
+		{CreatedAfter: "yesterday"},
+		{CreatedBefore: "2026-13-01"},
+	} {
+		if _, err := Resolve(&a); !errors.Is(err, ErrInvalidAudience) {
+			t.Errorf("Resolve(%+v) = %v, want ErrInvalidAudience", a, err)
+		}
+	}
+}
@@ -1,107 +1,492 @@
 package broadcast
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"slices"
 	"sync"
 	"time"
 
+	"esther-whatsapp/internal/config"
+	"esther-whatsapp/internal/rules"
 	"esther-whatsapp/internal/store"
+	"esther-whatsapp/internal/template"
 	"esther-whatsapp/internal/whatsapp"
 )
 
 var (
-	runningBroadcasts = make(map[string]chan struct{})
+	// ErrNotFound is returned for an unknown broadcast ID
+	ErrNotFound = errors.New("broadcast not found")
+	// ErrAlreadyRunning is returned when a broadcast is started while a run is still in progress
+	ErrAlreadyRunning = errors.New("broadcast is already running")
+	// ErrInvalidState is returned when a broadcast's status does not allow the action
+	ErrInvalidState = errors.New("invalid broadcast state")
+	// ErrStillRunning is returned when a stopped run does not exit in time
+	ErrStillRunning = errors.New("broadcast is still stopping")
+)
+
+// Minimum delay between two recipients, for safety
+const minDelay = 3 * time.Second
+
+// msgType is the message type broadcasts are evaluated and logged as, so every
+// recipient goes through the broadcast policy
+const msgType = "broadcast"
+
+// run is an in-progress broadcast. Its goroutine owns the progress and writes
+// it back on every recipient; halt asks it to stop with a final status.
+type run struct {
+	stop     chan struct{}
+	done     chan struct{} // Closed once the goroutine has exited
+	halted   string        // paused | cancelled once halted
+	progress store.BroadcastProgress
+	mu       sync.Mutex
+}
+
+var (
+	runningBroadcasts = make(map[string]*run)
 	broadcastMu       sync.Mutex
 )
 
-// Start starts a broadcast
-func Start(broadcastID string) {
-	broadcast, exists := store.GetBroadcast(broadcastID)
-	if !exists {
-		log.Printf("Broadcast %s not found", broadcastID)
-		return
+// Start starts a pending broadcast
+func Start(broadcastID string) error {
+	return launch(broadcastID, "pending")
+}
+
+// Resume continues a paused broadcast with the first recipient it has not sent to
+func Resume(broadcastID string) error {
+	return launch(broadcastID, "paused")
+}
+
+// RetryFailed sends again to the failed recipients of a completed or paused
+// broadcast, which runs until its remaining recipients are through. It returns
+// how many recipients were retried.
+func RetryFailed(broadcastID string) (int, error) {
+	b, err := load(broadcastID)
+	if err != nil {
+		return 0, err
+	}
+	if IsRunning(broadcastID) {
+		return 0, ErrAlreadyRunning
+	}
+	if b.Status != "completed" && b.Status != "paused" {
+		return 0, fmt.Errorf("%w: broadcast is %s", ErrInvalidState, b.Status)
 	}
 
+	n, err := store.DB.RequeueFailedRecipients(b.ID)
+	if err != nil {
+		return 0, err
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("%w: broadcast has no failed recipients", ErrInvalidState)
+	}
+	p := b.Progress()
+	p.Failed = max(p.Failed-n, 0)
+	if err := store.DB.UpdateBroadcast(b.ID, p); err != nil {
+		return 0, err
+	}
+	log.Printf("📢 Broadcast %s retrying %d failed recipient(s)", b.ID, n)
+	return n, launch(b.ID, b.Status)
+}
+
+// Pause stops a running broadcast after the current recipient. Its counters
+// are kept and Resume picks up where it stopped. A manual pause is not
+// resumed by the scheduler.
+func Pause(broadcastID string) error {
 	broadcastMu.Lock()
-	if _, running := runningBroadcasts[broadcastID]; running {
-		broadcastMu.Unlock()
-		log.Printf("Broadcast %s already running", broadcastID)
-		return
+	r, running := runningBroadcasts[broadcastID]
+	broadcastMu.Unlock()
+	if !running || !r.halt(broadcastID, "paused", nil) {
+		return fmt.Errorf("%w: broadcast is not running", ErrInvalidState)
 	}
-	stopChan := make(chan struct{})
-	runningBroadcasts[broadcastID] = stopChan
+	log.Printf("📢 Broadcast %s paused", broadcastID)
+	return nil
+}
+
+// Stop cancels a pending, running or paused broadcast, keeping the counters
+// of what was already sent
+func Stop(broadcastID string) error {
+	broadcastMu.Lock()
+	r, running := runningBroadcasts[broadcastID]
 	broadcastMu.Unlock()
+	if running {
+		if !r.halt(broadcastID, "cancelled", nil) {
+			return fmt.Errorf("%w: broadcast is already stopping", ErrInvalidState)
+		}
+		log.Printf("📢 Broadcast %s stopped", broadcastID)
+		return nil
+	}
 
-	go run(broadcast, stopChan)
+	b, err := load(broadcastID)
+	if err != nil {
+		return err
+	}
+	if b.Status != "pending" && b.Status != "paused" {
+		return fmt.Errorf("%w: broadcast is %s", ErrInvalidState, b.Status)
+	}
+	p := b.Progress()
+	p.Status = "cancelled"
+	p.ResumeAt = nil
+	if err := store.DB.UpdateBroadcast(b.ID, p); err != nil {
+		return err
+	}
+	log.Printf("📢 Broadcast %s stopped", broadcastID)
+	return nil
 }
 
-// Stop stops a broadcast
-func Stop(broadcastID string) {
+// StopAndWait cancels the run of a broadcast, if any, and waits up to
+// timeout for it to exit, so nothing writes its progress afterwards. A run
+// already halted by a pause is only waited for.
+func StopAndWait(broadcastID string, timeout time.Duration) error {
+	broadcastMu.Lock()
+	r, running := runningBroadcasts[broadcastID]
+	broadcastMu.Unlock()
+	if !running {
+		return nil
+	}
+	if r.halt(broadcastID, "cancelled", nil) {
+		log.Printf("📢 Broadcast %s stopped", broadcastID)
+	}
+
+	select {
+	case <-r.done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("%w after %v", ErrStillRunning, timeout)
+	}
+}
+
+// IsRunning checks if a broadcast is running
+func IsRunning(broadcastID string) bool {
 	broadcastMu.Lock()
 	defer broadcastMu.Unlock()
+	_, running := runningBroadcasts[broadcastID]
+	return running
+}
 
-	if stopChan, exists := runningBroadcasts[broadcastID]; exists {
-		close(stopChan)
-		delete(runningBroadcasts, broadcastID)
-		store.UpdateBroadcast(broadcastID, 0, 0, "cancelled")
-		log.Printf("Broadcast %s stopped", broadcastID)
+// Recover pauses the broadcasts left running by a previous process, so they
+// can be resumed instead of staying stuck as running
+func Recover() error {
+	broadcasts, err := store.DB.GetBroadcasts()
+	if err != nil {
+		return err
+	}
+	for _, b := range broadcasts {
+		if b.Status != "running" || IsRunning(b.ID) {
+			continue
+		}
+		p := b.Progress()
+		p.Status = "paused"
+		if err := store.DB.UpdateBroadcast(b.ID, p); err != nil {
+			return err
+		}
+		log.Printf("📢 Broadcast %s was interrupted, paused at %d/%d", b.ID, done(b), b.Total)
+	}
+	return nil
+}
+
+// Validate checks that every placeholder of the message has a value source:
+// a builtin, a custom field of a recipient's user or a key of the data rows
+func Validate(b *store.Broadcast) error {
+	if len(template.Placeholders(b.Message)) == 0 {
+		return nil
+	}
+	users := make([]*store.User, 0, len(b.Recipients))
+	for _, phone := range b.Recipients {
+		user, err := template.RecipientUser(phone, b.AccountID)
+		if err != nil {
+			return err
+		}
+		users = append(users, user)
 	}
+	return template.Validate(b.Message, template.FieldKeys(users, b.Data))
 }
 
-func run(broadcast *store.Broadcast, stopChan chan struct{}) {
-	log.Printf("📢 Starting broadcast: %s (ID: %s)", broadcast.Name, broadcast.ID)
-	store.UpdateBroadcast(broadcast.ID, 0, 0, "running")
+// launch starts a run of a broadcast in the given status. A broadcast has at
+// most one run: a second start is refused until the first has exited.
+func launch(broadcastID, from string) error {
+	b, err := load(broadcastID)
+	if err != nil {
+		return err
+	}
+	if b.Status != from {
+		return fmt.Errorf("%w: broadcast is %s", ErrInvalidState, b.Status)
+	}
+
+	// An audience is resolved once, when the broadcast first starts. Resumed
+	// runs keep its recipients.
+	if from == "pending" && b.Audience != nil {
+		users, err := Resolve(b.Audience)
+		if err != nil {
+			return err
+		}
+		if len(users) == 0 {
+			return fmt.Errorf("%w: no users match the audience", ErrInvalidAudience)
+		}
+		b.Recipients = Phones(users)
+		b.Total = len(b.Recipients)
+		if err := store.DB.SetBroadcastRecipients(b.ID, b.Recipients); err != nil {
+			return err
+		}
+	}
+
+	// Users may have changed since the broadcast was created
+	if err := Validate(b); err != nil {
+		return err
+	}
+
+	broadcastMu.Lock()
+	defer broadcastMu.Unlock()
+	if _, running := runningBroadcasts[broadcastID]; running {
+		return ErrAlreadyRunning
+	}
+
+	var media *whatsapp.Media
+	if b.MediaFile != "" {
+		media, err = whatsapp.LoadMediaFile(b.MediaFile, b.Message)
+		if err != nil {
+			return err
+		}
+	}
+	pending, err := pendingRecipients(b)
+	if err != nil {
+		return err
+	}
+
+	r := &run{
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		progress: b.Progress(),
+	}
+	r.progress.Status = "running"
+	r.progress.ResumeAt = nil
+	if err := store.DB.UpdateBroadcast(b.ID, r.progress); err != nil {
+		return err
+	}
+	runningBroadcasts[broadcastID] = r
+	go r.run(b, pending, media)
+	return nil
+}
+
+// pendingRecipients returns the recipients still to be sent, in order. The
+// delivery log is written when the broadcast first starts.
+func pendingRecipients(b *store.Broadcast) ([]store.BroadcastRecipient, error) {
+	logged, err := store.DB.GetBroadcastRecipients(b.ID, store.RecipientFilter{Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(logged) == 0 {
+		// Broadcasts paused before the log existed carry on after the recipients they went through
+		if err := store.DB.AddBroadcastRecipients(b.ID, b.Recipients[min(done(b), len(b.Recipients)):]); err != nil {
+			return nil, err
+		}
+	}
+	return store.DB.GetBroadcastRecipients(b.ID, store.RecipientFilter{Status: "pending"})
+}
+
+func load(broadcastID string) (*store.Broadcast, error) {
+	b, err := store.DB.GetBroadcast(broadcastID)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
+
+// done returns how many recipients the broadcast has been through
+func done(b *store.Broadcast) int {
+	return b.Sent + b.Failed + b.Skipped
+}
+
+// halt stops the run with a final status, saved right away with the counters
+// so far and the time the scheduler resumes it, if any. It returns false when
+// the run was already halted.
+func (r *run) halt(broadcastID, status string, resumeAt *string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.halted != "" {
+		return false
+	}
+	r.halted = status
+	close(r.stop)
+	r.progress.Status = status
+	r.progress.ResumeAt = resumeAt
+	r.save(broadcastID)
+	return true
+}
+
+// record logs the outcome of a recipient, counts it and saves the progress.
+// Once halted, the halt status is kept so a send that was in flight does not
+// flip it back to running.
+func (r *run) record(broadcastID string, recipient *store.BroadcastRecipient, waID, skipReason string, err error) {
+	switch {
+	case skipReason != "":
+		recipient.Status = "skipped"
+		recipient.Error = skipReason
+	case err != nil:
+		recipient.Status = "failed"
+		recipient.Error = err.Error()
+	default:
+		now := time.Now().UTC().Format(time.RFC3339)
+		recipient.Status = "sent"
+		recipient.Error = ""
+		recipient.WAMessageID = waID
+		recipient.SentAt = &now
+		recipient.Receipt = "sent"
+	}
+	if err := store.DB.UpdateBroadcastRecipient(recipient); err != nil {
+		log.Printf("❌ Failed to log broadcast %s recipient %s: %v", broadcastID, recipient.Phone, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch recipient.Status {
+	case "skipped":
+		r.progress.Skipped++
+		r.progress.SkipReasons[skipReason]++
+	case "failed":
+		r.progress.Failed++
+	default:
+		r.progress.Sent++
+	}
+	r.save(broadcastID)
+}
+
+// save writes the progress. Caller must hold r.mu.
+func (r *run) save(broadcastID string) {
+	if err := store.DB.UpdateBroadcast(broadcastID, r.progress); err != nil {
+		log.Printf("❌ Failed to update broadcast %s: %v", broadcastID, err)
+	}
+}
+
+func (r *run) run(broadcast *store.Broadcast, pending []store.BroadcastRecipient, media *whatsapp.Media) {
+	defer func() {
+		broadcastMu.Lock()
+		delete(runningBroadcasts, broadcast.ID)
+		broadcastMu.Unlock()
+		close(r.done)
+	}()
+
+	log.Printf("📢 Starting broadcast: %s (ID: %s), %d/%d recipients to go",
+		broadcast.Name, broadcast.ID, len(pending), broadcast.Total)
 
-	sent := 0
-	failed := 0
 	delay := time.Duration(broadcast.DelayMs) * time.Millisecond
-	if delay < 3*time.Second {
-		delay = 3 * time.Second // Minimum 3 second delay for safety
+	if delay < minDelay {
+		delay = minDelay
 	}
 
-	for _, phone := range broadcast.Recipients {
+	// Skipped recipients were not messaged, so only a send is followed by the delay
+	sentLast := false
+	for i := range pending {
+		if sentLast {
+			select {
+			case <-r.stop:
+				return
+			case <-time.After(delay):
+			}
+		}
 		select {
-		case <-stopChan:
-			log.Printf("📢 Broadcast %s cancelled", broadcast.ID)
+		case <-r.stop:
 			return
 		default:
 		}
+		if closed, opening := closedUntil(broadcast.AccountID); closed {
+			r.pauseUntil(broadcast.ID, opening)
+			return
+		}
 
-		// Send message
-		err := whatsapp.Manager.SendMessage(broadcast.AccountID, phone, broadcast.Message)
-		if err != nil {
+		recipient := &pending[i]
+		phone := recipient.Phone
+		waID, skipReason, err := send(broadcast, phone, media)
+		switch {
+		case skipReason != "":
+			log.Printf("⏭️ Skipped %s: %s", phone, skipReason)
+		case err != nil:
 			log.Printf("❌ Failed to send to %s: %v", phone, err)
-			failed++
-		} else {
+		default:
 			log.Printf("✅ Sent to %s", phone)
-			sent++
 		}
+		r.record(broadcast.ID, recipient, waID, skipReason, err)
+		sentLast = skipReason == ""
+	}
 
-		// Update progress
-		store.UpdateBroadcast(broadcast.ID, sent, failed, "running")
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.halted != "" {
+		return
+	}
+	r.halted = "completed"
+	r.progress.Status = "completed"
+	r.save(broadcast.ID)
+	log.Printf("📢 Broadcast %s completed: %d sent, %d failed, %d skipped",
+		broadcast.ID, r.progress.Sent, r.progress.Failed, r.progress.Skipped)
+}
 
-		// Delay before next message
-		select {
-		case <-stopChan:
-			log.Printf("📢 Broadcast %s cancelled during delay", broadcast.ID)
-			return
-		case <-time.After(delay):
+// pauseUntil pauses the run outside business hours. The scheduler resumes it
+// at the next opening; without one it waits for a manual resume.
+func (r *run) pauseUntil(broadcastID string, opening time.Time) {
+	if opening.IsZero() {
+		if r.halt(broadcastID, "paused", nil) {
+			log.Printf("🌙 Broadcast %s paused outside business hours, no opening within a year", broadcastID)
 		}
+		return
 	}
+	resumeAt := opening.UTC().Format(time.RFC3339)
+	if r.halt(broadcastID, "paused", &resumeAt) {
+		log.Printf("🌙 Broadcast %s paused outside business hours, resuming at %s",
+			broadcastID, opening.Format(time.RFC3339))
+	}
+}
 
-	store.UpdateBroadcast(broadcast.ID, sent, failed, "completed")
-	log.Printf("📢 Broadcast %s completed: %d sent, %d failed", broadcast.ID, sent, failed)
-
-	// Clean up
-	broadcastMu.Lock()
-	delete(runningBroadcasts, broadcast.ID)
-	broadcastMu.Unlock()
+// closedUntil reports whether the account is outside business hours while the
+// broadcast policy checks them, with the next opening
+func closedUntil(accountID string) (bool, time.Time) {
+	settings := config.SettingsFor(accountID)
+	checks, _ := settings.GetPolicy(msgType)
+	if !slices.Contains(checks, "business_hours") {
+		return false, time.Time{}
+	}
+	hours := settings.GetBusinessHours()
+	now := time.Now()
+	if hours.IsOpen(now) {
+		return false, time.Time{}
+	}
+	return true, hours.NextOpen(now)
 }
 
-// IsRunning checks if a broadcast is running
-func IsRunning(broadcastID string) bool {
-	broadcastMu.Lock()
-	defer broadcastMu.Unlock()
-	_, running := runningBroadcasts[broadcastID]
-	return running
+// send personalises the message for one recipient, checks it against the
+// anti-ban rules and delivers it. A refused recipient is skipped with the
+// reason; an account that is only busy or offline is still tried and fails.
+func send(broadcast *store.Broadcast, phone string, media *whatsapp.Media) (waID, skipReason string, err error) {
+	text := broadcast.Message
+	if len(template.Placeholders(text)) > 0 {
+		user, err := template.RecipientUser(phone, broadcast.AccountID)
+		if err != nil {
+			return "", "", err
+		}
+		text, err = template.Render(text, template.Values(phone, user, broadcast.Data[phone]))
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	decision := rules.Evaluate(rules.Request{
+		MsgType:   msgType,
+		Phone:     phone,
+		AccountID: broadcast.AccountID,
+		Text:      text,
+	})
+	if !decision.CanSend && !decision.Transient() {
+		return "", decision.Reason, nil
+	}
+
+	if media != nil {
+		personal := *media
+		personal.Caption = text
+		media = &personal
+	}
+	waID, err = whatsapp.Manager.Deliver(broadcast.AccountID, phone, msgType, text, media)
+	return waID, "", err
 }
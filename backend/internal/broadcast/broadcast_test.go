@@ -0,0 +1,406 @@
+package broadcast
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"esther-whatsapp/internal/config"
+	"esther-whatsapp/internal/store"
+)
+
+// TestMain runs the engine against a throwaway SQLite store, always open
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "broadcast-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.AppConfig = &config.Config{StoreDriver: "sqlite", SQLitePath: filepath.Join(dir, "test.db")}
+	store.DB, err = store.NewSQLite(config.AppConfig.SQLitePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := config.Settings.SetBusinessHours(alwaysOpen()); err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// alwaysOpen is a calendar open around the clock, every day
+func alwaysOpen() config.BusinessHours {
+	h := config.BusinessHours{Timezone: "UTC", Weekly: map[string]config.DayHours{}}
+	for _, day := range []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"} {
+		h.Weekly[day] = config.DayHours{Open: "00:00", Close: "24:00"}
+	}
+	return h
+}
+
+// newBroadcast stores a broadcast in the given status whose delivery log has
+// the given recipient statuses, keyed by phone
+func newBroadcast(t *testing.T, status string, recipients map[string]string) *store.Broadcast {
+	t.Helper()
+	phones := make([]string, 0, len(recipients))
+	for phone := range recipients {
+		phones = append(phones, phone)
+	}
+	b, err := store.DB.CreateBroadcast(&store.Broadcast{Name: t.Name(), Message: "Halo", Recipients: phones, DelayMs: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DB.AddBroadcastRecipients(b.ID, phones); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := store.DB.GetBroadcastRecipients(b.ID, store.RecipientFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := b.Progress()
+	for i := range rows {
+		rows[i].Status = recipients[rows[i].Phone]
+		switch rows[i].Status {
+		case "sent":
+			p.Sent++
+		case "failed":
+			p.Failed++
+			rows[i].Error = "account is not connected"
+		}
+		if err := store.DB.UpdateBroadcastRecipient(&rows[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Status = status
+	if err := store.DB.UpdateBroadcast(b.ID, p); err != nil {
+		t.Fatal(err)
+	}
+	return reload(t, b.ID)
+}
+
+func reload(t *testing.T, id string) *store.Broadcast {
+	t.Helper()
+	b, err := store.DB.GetBroadcast(id)
+	if err != nil || b == nil {
+		t.Fatalf("GetBroadcast(%s) = %v, %v", id, b, err)
+	}
+	return b
+}
+
+// waitDone waits for the run of a broadcast to exit
+func waitDone(t *testing.T, id string) *store.Broadcast {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for IsRunning(id) {
+		if time.Now().After(deadline) {
+			t.Fatalf("broadcast %s is still running", id)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return reload(t, id)
+}
+
+func TestRetryFailed(t *testing.T) {
+	b := newBroadcast(t, "completed", map[string]string{"62811": "sent", "62812": "failed", "62813": "failed"})
+
+	n, err := RetryFailed(b.ID)
+	if err != nil || n != 2 {
+		t.Fatalf("RetryFailed() = %d, %v, want 2 retried", n, err)
+	}
+	b = waitDone(t, b.ID)
+
+	// The test users are unknown, so the broadcast policy skips them this time
+	if b.Status != "completed" || b.Sent != 1 || b.Failed != 0 || b.Skipped != 2 {
+		t.Fatalf("after retry: %s with %d sent, %d failed, %d skipped, want completed with 1, 0, 2",
+			b.Status, b.Sent, b.Failed, b.Skipped)
+	}
+	failed, err := store.DB.GetBroadcastRecipients(b.ID, store.RecipientFilter{Status: "failed"})
+	if err != nil || len(failed) != 0 {
+		t.Fatalf("failed recipients = %v, %v, want none", failed, err)
+	}
+}
+
+func TestRetryFailedRefused(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     string
+		recipients map[string]string
+		want       error
+	}{
+		{"pending", "pending", map[string]string{"62811": "pending"}, ErrInvalidState},
+		{"cancelled", "cancelled", map[string]string{"62811": "failed"}, ErrInvalidState},
+		{"nothing failed", "completed", map[string]string{"62811": "sent"}, ErrInvalidState},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newBroadcast(t, tt.status, tt.recipients)
+			if _, err := RetryFailed(b.ID); !errors.Is(err, tt.want) {
+				t.Fatalf("RetryFailed() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := RetryFailed("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("RetryFailed() of an unknown broadcast = %v, want ErrNotFound", err)
+	}
+}
+
+func TestControlWhenNotRunning(t *testing.T) {
+	b := newBroadcast(t, "pending", map[string]string{"62811": "pending"})
+
+	if err := Pause(b.ID); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("Pause() of a pending broadcast = %v, want ErrInvalidState", err)
+	}
+	if err := Resume(b.ID); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("Resume() of a pending broadcast = %v, want ErrInvalidState", err)
+	}
+	if err := Stop(b.ID); err != nil {
+		t.Fatalf("Stop() of a pending broadcast = %v", err)
+	}
+	if b = reload(t, b.ID); b.Status != "cancelled" {
+		t.Fatalf("status = %s, want cancelled", b.Status)
+	}
+	if err := Stop(b.ID); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("Stop() of a cancelled broadcast = %v, want ErrInvalidState", err)
+	}
+	if err := Start(b.ID); !errors.Is(err, ErrInvalidState) {
+		t.Fatalf("Start() of a cancelled broadcast = %v, want ErrInvalidState", err)
+	}
+}
+
+func TestHalt(t *testing.T) {
+	b := newBroadcast(t, "running", map[string]string{"62811": "sent", "62812": "pending"})
+	r := &run{stop: make(chan struct{}), progress: b.Progress()}
+
+	if !r.halt(b.ID, "paused", nil) {
+		t.Fatal("halt() = false, want the first halt to win")
+	}
+	if r.halt(b.ID, "cancelled", nil) {
+		t.Fatal("halt() = true, want a second halt to be refused")
+	}
+	select {
+	case <-r.stop:
+	default:
+		t.Fatal("halt() did not close the stop channel")
+	}
+	if b = reload(t, b.ID); b.Status != "paused" || b.Sent != 1 {
+		t.Fatalf("stored %s with %d sent, want paused with 1", b.Status, b.Sent)
+	}
+}
+
+// fakeRun registers a run that exits the given time after being halted
+func fakeRun(t *testing.T, b *store.Broadcast, exitAfter time.Duration) *run {
+	t.Helper()
+	r := &run{stop: make(chan struct{}), done: make(chan struct{}), progress: b.Progress()}
+	broadcastMu.Lock()
+	runningBroadcasts[b.ID] = r
+	broadcastMu.Unlock()
+	go func() {
+		<-r.stop
+		time.Sleep(exitAfter)
+		broadcastMu.Lock()
+		delete(runningBroadcasts, b.ID)
+		broadcastMu.Unlock()
+		close(r.done)
+	}()
+	return r
+}
+
+func TestStopAndWait(t *testing.T) {
+	if err := StopAndWait("missing", time.Second); err != nil {
+		t.Fatalf("StopAndWait() without a run = %v, want nil", err)
+	}
+
+	b := newBroadcast(t, "running", map[string]string{"62811": "pending"})
+	fakeRun(t, b, 50*time.Millisecond)
+	if err := StopAndWait(b.ID, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if IsRunning(b.ID) {
+		t.Fatal("StopAndWait() returned before the run exited")
+	}
+	if b = reload(t, b.ID); b.Status != "cancelled" {
+		t.Fatalf("broadcast is %s, want cancelled", b.Status)
+	}
+
+	// A run stuck in a send is reported, not waited for forever
+	b = newBroadcast(t, "running", map[string]string{"62811": "pending"})
+	r := fakeRun(t, b, time.Second)
+	if err := StopAndWait(b.ID, 10*time.Millisecond); !errors.Is(err, ErrStillRunning) {
+		t.Fatalf("StopAndWait() = %v, want ErrStillRunning", err)
+	}
+	<-r.done
+}
+
+func TestRecord(t *testing.T) {
+	b := newBroadcast(t, "running", map[string]string{"62811": "pending", "62812": "pending", "62813": "pending"})
+	r := &run{stop: make(chan struct{}), progress: b.Progress()}
+
+	rows, err := store.DB.GetBroadcastRecipients(b.ID, store.RecipientFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.record(b.ID, &rows[0], "WA1", "", nil)
+	r.record(b.ID, &rows[1], "", "", errors.New("offline"))
+	r.record(b.ID, &rows[2], "", "User has opted out", nil)
+
+	b = reload(t, b.ID)
+	if b.Sent != 1 || b.Failed != 1 || b.Skipped != 1 || b.SkipReasons["User has opted out"] != 1 {
+		t.Fatalf("stored %d sent, %d failed, %d skipped, reasons %v, want one of each",
+			b.Sent, b.Failed, b.Skipped, b.SkipReasons)
+	}
+
+	rows, err = store.DB.GetBroadcastRecipients(b.ID, store.RecipientFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct{ status, errText, waID string }{
+		{"sent", "", "WA1"},
+		{"failed", "offline", ""},
+		{"skipped", "User has opted out", ""},
+	}
+	for i, w := range want {
+		got := rows[i]
+		if got.Status != w.status || got.Error != w.errText || got.WAMessageID != w.waID {
+			t.Errorf("recipient %d = %s %q %q, want %s %q %q", i, got.Status, got.Error, got.WAMessageID, w.status, w.errText, w.waID)
+		}
+	}
+	if rows[0].SentAt == nil || rows[0].Receipt != "sent" {
+		t.Errorf("sent recipient has sent_at %v and receipt %q, want both set", rows[0].SentAt, rows[0].Receipt)
+	}
+}
+
+// closedToday is open around the clock except today, a holiday in UTC
+func closedToday() config.BusinessHours {
+	h := alwaysOpen()
+	h.Holidays = []string{time.Now().UTC().Format(time.DateOnly)}
+	return h
+}
+
+// useHours swaps the business hours for the rest of the test
+func useHours(t *testing.T, h config.BusinessHours) {
+	t.Helper()
+	previous := config.Settings.GetBusinessHours()
+	t.Cleanup(func() { config.Settings.SetBusinessHours(previous) })
+	if err := config.Settings.SetBusinessHours(h); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestClosedUntil(t *testing.T) {
+	tomorrow := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1)
+	neverOpen := config.BusinessHours{Timezone: "UTC"}
+	tests := []struct {
+		name     string
+		hours    config.BusinessHours
+		checks   []string
+		closed   bool
+		resumeAt time.Time
+	}{
+		{"open", alwaysOpen(), config.DefaultPolicies()["broadcast"], false, time.Time{}},
+		{"closed", closedToday(), config.DefaultPolicies()["broadcast"], true, tomorrow},
+		{"never open", neverOpen, config.DefaultPolicies()["broadcast"], true, time.Time{}},
+		{"policy without business hours", closedToday(), []string{"blocked", "content"}, false, time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policies := config.Settings.GetPolicies()
+			t.Cleanup(func() { config.Settings.SetPolicies(policies) })
+			config.Settings.SetPolicies(map[string][]string{msgType: tt.checks})
+			useHours(t, tt.hours)
+
+			closed, opening := closedUntil("")
+			if closed != tt.closed || !opening.Equal(tt.resumeAt) {
+				t.Fatalf("closedUntil() = %v, %v, want %v, %v", closed, opening, tt.closed, tt.resumeAt)
+			}
+		})
+	}
+}
+
+func TestRunPausesOutsideBusinessHours(t *testing.T) {
+	useHours(t, closedToday())
+	b := newBroadcast(t, "pending", map[string]string{"62811": "pending", "62812": "pending"})
+
+	if err := Start(b.ID); err != nil {
+		t.Fatalf("Start() = %v", err)
+	}
+	b = waitDone(t, b.ID)
+	tomorrow := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, 1).Format(time.RFC3339)
+	if b.Status != "paused" || b.ResumeAt == nil || *b.ResumeAt != tomorrow || done(b) != 0 {
+		t.Fatalf("stored %s resuming at %v after %d recipients, want paused until %s before any",
+			b.Status, b.ResumeAt, done(b), tomorrow)
+	}
+
+	due, err := store.DB.GetDueBroadcasts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range due {
+		if d.ID == b.ID {
+			t.Fatal("GetDueBroadcasts() returned the broadcast before its resume_at")
+		}
+	}
+
+	// Once open, a resume goes through every recipient and clears resume_at
+	useHours(t, alwaysOpen())
+	if err := Resume(b.ID); err != nil {
+		t.Fatalf("Resume() = %v", err)
+	}
+	b = waitDone(t, b.ID)
+	if b.Status != "completed" || b.ResumeAt != nil || done(b) != 2 {
+		t.Fatalf("stored %s resuming at %v after %d recipients, want completed after 2",
+			b.Status, b.ResumeAt, done(b))
+	}
+}
+
+func TestDueBroadcasts(t *testing.T) {
+	past := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	future := time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	schedule := func(at *string) *store.Broadcast {
+		b, err := store.DB.CreateBroadcast(&store.Broadcast{Name: t.Name(), Message: "Halo", Recipients: []string{"62811"}, ScheduledAt: at})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	scheduled := schedule(&past)
+	later := schedule(&future)
+	unscheduled := schedule(nil)
+	waiting := newBroadcast(t, "paused", map[string]string{"62811": "pending"})
+	manual := newBroadcast(t, "paused", map[string]string{"62811": "pending"})
+	p := waiting.Progress()
+	p.ResumeAt = &past
+	if err := store.DB.UpdateBroadcast(waiting.ID, p); err != nil {
+		t.Fatal(err)
+	}
+
+	due, err := store.DB.GetDueBroadcasts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := make(map[string]bool)
+	for _, d := range due {
+		found[d.ID] = true
+	}
+	tests := []struct {
+		name string
+		b    *store.Broadcast
+		due  bool
+	}{
+		{"scheduled in the past", scheduled, true},
+		{"scheduled in the future", later, false},
+		{"not scheduled", unscheduled, false},
+		{"paused until the past", waiting, true},
+		{"paused by hand", manual, false},
+	}
+	for _, tt := range tests {
+		if found[tt.b.ID] != tt.due {
+			t.Errorf("%s: due = %v, want %v", tt.name, found[tt.b.ID], tt.due)
+		}
+	}
+}
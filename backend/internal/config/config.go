@@ -1,13 +1,19 @@
 package config
 
 import (
+	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
+	// Storage backend: supabase | sqlite
+	StoreDriver string
+	SQLitePath  string
+
 	// Supabase
 	SupabaseURL string
 	SupabaseKey string
@@ -16,12 +22,37 @@ type Config struct {
 	Port string
 	Env  string
 
-	// Rate Limits
-	MaxSystemMsgPerDay int
+	// WhatsApp sessions and account registry location
+	SessionDir string
+
+	// Directory of stored files that can be sent by name
+	MediaDir string
+
+	// Where received media is kept: local
+	MediaStorage    string
+	MediaStorageDir string
+
+	// Bearer token protecting sensitive endpoints
+	APIToken string
+
+	// Defaults of the business hours: IANA timezone and daily open/close hour
+	Timezone           string
 	OperatingHourStart int
 	OperatingHourEnd   int
+
+	// Rate Limits
+	MaxSystemMsgPerDay int
 	MinDelaySeconds    int
 	MaxDelaySeconds    int
+
+	// Send queue: attempts before a job is dead-lettered
+	QueueMaxAttempts int
+
+	// Send queue: jobs sending at once in each priority lane
+	QueueReplyWorkers  int
+	QueueManualWorkers int
+	QueueSystemWorkers int
+	QueueBulkWorkers   int
 }
 
 var AppConfig *Config
@@ -30,21 +61,50 @@ func Load() error {
 	godotenv.Load()
 
 	AppConfig = &Config{
+		StoreDriver: getEnv("STORE_DRIVER", "supabase"),
+		SQLitePath:  getEnv("SQLITE_PATH", "esther.db"),
+
 		SupabaseURL: getEnv("SUPABASE_URL", ""),
 		SupabaseKey: getEnv("SUPABASE_KEY", ""),
 		Port:        getEnv("PORT", "8080"),
 		Env:         getEnv("ENV", "development"),
+		SessionDir:  getEnv("SESSION_DIR", "."),
+		MediaDir:    getEnv("MEDIA_DIR", "media"),
 
-		MaxSystemMsgPerDay: getEnvInt("MAX_SYSTEM_MSG_PER_DAY", 1),
+		MediaStorage:    getEnv("MEDIA_STORAGE", "local"),
+		MediaStorageDir: getEnv("MEDIA_STORAGE_DIR", "media_store"),
+		APIToken:        getEnv("API_TOKEN", ""),
+
+		Timezone:           getEnv("TIMEZONE", "Asia/Jakarta"),
 		OperatingHourStart: getEnvInt("OPERATING_HOUR_START", 8),
 		OperatingHourEnd:   getEnvInt("OPERATING_HOUR_END", 20),
+
+		MaxSystemMsgPerDay: getEnvInt("MAX_SYSTEM_MSG_PER_DAY", 1),
 		MinDelaySeconds:    getEnvInt("MIN_DELAY_SECONDS", 3),
 		MaxDelaySeconds:    getEnvInt("MAX_DELAY_SECONDS", 10),
+		QueueMaxAttempts:   getEnvInt("QUEUE_MAX_ATTEMPTS", 5),
+		QueueReplyWorkers:  getEnvInt("QUEUE_REPLY_WORKERS", 4),
+		QueueManualWorkers: getEnvInt("QUEUE_MANUAL_WORKERS", 2),
+		QueueSystemWorkers: getEnvInt("QUEUE_SYSTEM_WORKERS", 1),
+		QueueBulkWorkers:   getEnvInt("QUEUE_BULK_WORKERS", 1),
+	}
+
+	// The env values seed the business hours and the human delay until they are edited in the settings
+	hours := defaultBusinessHours(AppConfig.Timezone, AppConfig.OperatingHourStart, AppConfig.OperatingHourEnd)
+	if err := Settings.SetBusinessHours(hours); err != nil {
+		log.Printf("⚠️ Ignoring TIMEZONE and OPERATING_HOUR_* from the environment: %v", err)
 	}
+	_, _, dailyLimit := Settings.GetRateLimits()
+	Settings.SetRateLimits(AppConfig.MinDelaySeconds*1000, AppConfig.MaxDelaySeconds*1000, dailyLimit)
 
 	return nil
 }
 
+// Location returns the timezone of the business hours
+func Location() *time.Location {
+	return Settings.GetBusinessHours().Location()
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidHours is returned when a business-hours calendar is inconsistent
+var ErrInvalidHours = errors.New("invalid business hours")
+
+// weekdays are the keys of BusinessHours.Weekly, indexed by time.Weekday
+var weekdays = [7]string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+
+// DayHours are the opening hours of one weekday as "HH:MM" in 24h format.
+// Close may be "24:00" for a day open until midnight.
+type DayHours struct {
+	Closed bool   `json:"closed"`
+	Open   string `json:"open"`
+	Close  string `json:"close"`
+}
+
+// BusinessHours is the weekly calendar of the business in its own timezone.
+// Weekdays missing from Weekly are closed, and so are the Holidays ("2006-01-02").
+type BusinessHours struct {
+	Timezone string              `json:"timezone"` // IANA name, e.g. Asia/Jakarta
+	Weekly   map[string]DayHours `json:"weekly"`   // Keyed by lowercase weekday name
+	Holidays []string            `json:"holidays"`
+
+	loc *time.Location
+}
+
+// defaultBusinessHours opens Monday to Saturday from start to end hour, closed on Sunday
+func defaultBusinessHours(timezone string, start, end int) BusinessHours {
+	h := BusinessHours{
+		Timezone: timezone,
+		Weekly:   make(map[string]DayHours, len(weekdays)),
+		Holidays: []string{},
+	}
+	for _, day := range weekdays {
+		h.Weekly[day] = DayHours{
+			Closed: day == "sunday",
+			Open:   fmt.Sprintf("%02d:00", start),
+			Close:  fmt.Sprintf("%02d:00", end),
+		}
+	}
+	return h
+}
+
+// Validate checks the calendar and resolves its timezone
+func (h *BusinessHours) Validate() error {
+	if h.Timezone == "" {
+		return fmt.Errorf("%w: timezone is required", ErrInvalidHours)
+	}
+	loc, err := time.LoadLocation(h.Timezone)
+	if err != nil {
+		return fmt.Errorf("%w: unknown timezone %q", ErrInvalidHours, h.Timezone)
+	}
+
+	known := make(map[string]bool, len(weekdays))
+	for _, day := range weekdays {
+		known[day] = true
+	}
+	weekly := make(map[string]DayHours, len(h.Weekly))
+	for name, d := range h.Weekly {
+		day := strings.ToLower(strings.TrimSpace(name))
+		if !known[day] {
+			return fmt.Errorf("%w: unknown weekday %q", ErrInvalidHours, name)
+		}
+		if !d.Closed {
+			open, err := parseClock(d.Open)
+			if err != nil {
+				return fmt.Errorf("%w: %s open: %v", ErrInvalidHours, day, err)
+			}
+			end, err := parseClock(d.Close)
+			if err != nil {
+				return fmt.Errorf("%w: %s close: %v", ErrInvalidHours, day, err)
+			}
+			if end <= open {
+				return fmt.Errorf("%w: %s closes before it opens", ErrInvalidHours, day)
+			}
+		}
+		weekly[day] = d
+	}
+
+	holidays := make([]string, 0, len(h.Holidays))
+	for _, date := range h.Holidays {
+		if _, err := time.Parse(time.DateOnly, date); err != nil {
+			return fmt.Errorf("%w: holiday %q is not a YYYY-MM-DD date", ErrInvalidHours, date)
+		}
+		holidays = append(holidays, date)
+	}
+
+	h.Weekly = weekly
+	h.Holidays = holidays
+	h.loc = loc
+	return nil
+}
+
+// Location returns the calendar's timezone
+func (h BusinessHours) Location() *time.Location {
+	if h.loc == nil {
+		return time.Local
+	}
+	return h.loc
+}
+
+// IsOpen reports whether the business is open at t
+func (h BusinessHours) IsOpen(t time.Time) bool {
+	local := t.In(h.Location())
+	open, end, ok := h.hoursOn(local)
+	if !ok {
+		return false
+	}
+	minute := local.Hour()*60 + local.Minute()
+	return minute >= open && minute < end
+}
+
+// NextOpen returns t when the business is open, else its next opening. It is
+// the zero time when the calendar has no opening within a year.
+func (h BusinessHours) NextOpen(t time.Time) time.Time {
+	if h.IsOpen(t) {
+		return t
+	}
+	local := t.In(h.Location())
+	for i := 0; i <= 366; i++ {
+		date := time.Date(local.Year(), local.Month(), local.Day()+i, 0, 0, 0, 0, h.Location())
+		open, _, ok := h.hoursOn(date)
+		if !ok {
+			continue
+		}
+		opening := time.Date(date.Year(), date.Month(), date.Day(), open/60, open%60, 0, 0, h.Location())
+		if opening.After(local) {
+			return opening
+		}
+	}
+	return time.Time{}
+}
+
+// WithDailyHours returns a copy of the calendar with every weekday's hours set
+// from the start to the end hour. Closed days stay closed.
+func (h BusinessHours) WithDailyHours(start, end int) BusinessHours {
+	c := h.clone()
+	for day, d := range c.Weekly {
+		d.Open = fmt.Sprintf("%02d:00", start)
+		d.Close = fmt.Sprintf("%02d:00", end)
+		c.Weekly[day] = d
+	}
+	return c
+}
+
+// hoursOn returns the opening and closing minute of a local date, with ok
+// false when the business is closed that day
+func (h BusinessHours) hoursOn(local time.Time) (open, end int, ok bool) {
+	if h.isHoliday(local) {
+		return 0, 0, false
+	}
+	d, found := h.Weekly[weekdays[local.Weekday()]]
+	if !found || d.Closed {
+		return 0, 0, false
+	}
+	open, err := parseClock(d.Open)
+	if err != nil {
+		return 0, 0, false
+	}
+	end, err = parseClock(d.Close)
+	if err != nil {
+		return 0, 0, false
+	}
+	return open, end, true
+}
+
+func (h BusinessHours) isHoliday(local time.Time) bool {
+	date := local.Format(time.DateOnly)
+	for _, holiday := range h.Holidays {
+		if holiday == date {
+			return true
+		}
+	}
+	return false
+}
+
+// clone returns a copy that does not share the weekly map or holiday list
+func (h BusinessHours) clone() BusinessHours {
+	c := h
+	c.Weekly = make(map[string]DayHours, len(h.Weekly))
+	for day, d := range h.Weekly {
+		c.Weekly[day] = d
+	}
+	c.Holidays = append([]string{}, h.Holidays...)
+	return c
+}
+
+// parseClock returns the minutes since midnight of an "HH:MM" time
+func parseClock(s string) (int, error) {
+	var hour, minute int
+	if _, err := fmt.Sscanf(s, "%d:%d", &hour, &minute); err != nil || len(s) != 5 {
+		return 0, fmt.Errorf("%q is not an HH:MM time", s)
+	}
+	if hour < 0 || minute < 0 || minute > 59 || hour > 24 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("%q is not a valid time", s)
+	}
+	return hour*60 + minute, nil
+}
@@ -0,0 +1,158 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// testHours is open Monday, Wednesday and Thursday 08:00-17:00, all day on
+// Friday and Saturday morning. Tuesday is closed, Sunday missing and New
+// Year's Day (a Thursday) a holiday.
+func testHours(t *testing.T) BusinessHours {
+	t.Helper()
+	h := BusinessHours{
+		Timezone: "Asia/Jakarta",
+		Weekly: map[string]DayHours{
+			"monday":    {Open: "08:00", Close: "17:00"},
+			"tuesday":   {Closed: true, Open: "08:00", Close: "17:00"},
+			"wednesday": {Open: "08:00", Close: "17:00"},
+			"thursday":  {Open: "08:00", Close: "17:00"},
+			"friday":    {Open: "00:00", Close: "24:00"},
+			"saturday":  {Open: "09:00", Close: "12:00"},
+		},
+		Holidays: []string{"2026-01-01"},
+	}
+	if err := h.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	return h
+}
+
+// jakarta returns a January 2026 time in the calendar's timezone
+func jakarta(t *testing.T, day, hour, minute int) time.Time {
+	t.Helper()
+	loc, err := time.LoadLocation("Asia/Jakarta")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return time.Date(2026, time.January, day, hour, minute, 0, 0, loc)
+}
+
+func TestIsOpen(t *testing.T) {
+	h := testHours(t)
+	tests := []struct {
+		name string
+		at   time.Time
+		want bool
+	}{
+		{"before opening", jakarta(t, 5, 7, 59), false},
+		{"at opening", jakarta(t, 5, 8, 0), true},
+		{"last minute", jakarta(t, 5, 16, 59), true},
+		{"at closing", jakarta(t, 5, 17, 0), false},
+		{"closed day", jakarta(t, 6, 10, 0), false},
+		{"missing weekday", jakarta(t, 4, 10, 0), false},
+		{"holiday", jakarta(t, 1, 10, 0), false},
+		{"open until midnight, first minute", jakarta(t, 2, 0, 0), true},
+		{"open until midnight, last minute", jakarta(t, 2, 23, 59), true},
+		{"midnight rolls over to the next day", jakarta(t, 3, 0, 0), false},
+		{"next day opening", jakarta(t, 3, 9, 0), true},
+		{"UTC time at local opening", time.Date(2026, time.January, 5, 1, 0, 0, 0, time.UTC), true},
+		{"UTC time before local opening", time.Date(2026, time.January, 5, 0, 59, 0, 0, time.UTC), false},
+		{"UTC Friday is local Saturday", time.Date(2026, time.January, 2, 17, 30, 0, 0, time.UTC), false},
+	}
+	for _, tt := range tests {
+		if got := h.IsOpen(tt.at); got != tt.want {
+			t.Errorf("%s: IsOpen(%v) = %v, want %v", tt.name, tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestValidateHours(t *testing.T) {
+	tests := []struct {
+		name string
+		h    BusinessHours
+	}{
+		{"no timezone", BusinessHours{}},
+		{"unknown timezone", BusinessHours{Timezone: "Mars/Olympus"}},
+		{"unknown weekday", BusinessHours{Timezone: "UTC", Weekly: map[string]DayHours{"funday": {Open: "08:00", Close: "17:00"}}}},
+		{"closes before it opens", BusinessHours{Timezone: "UTC", Weekly: map[string]DayHours{"monday": {Open: "17:00", Close: "08:00"}}}},
+		{"closes when it opens", BusinessHours{Timezone: "UTC", Weekly: map[string]DayHours{"monday": {Open: "08:00", Close: "08:00"}}}},
+		{"short clock", BusinessHours{Timezone: "UTC", Weekly: map[string]DayHours{"monday": {Open: "8:00", Close: "17:00"}}}},
+		{"past midnight", BusinessHours{Timezone: "UTC", Weekly: map[string]DayHours{"monday": {Open: "08:00", Close: "24:30"}}}},
+		{"bad minute", BusinessHours{Timezone: "UTC", Weekly: map[string]DayHours{"monday": {Open: "08:60", Close: "17:00"}}}},
+		{"bad holiday", BusinessHours{Timezone: "UTC", Holidays: []string{"01-01-2026"}}},
+	}
+	for _, tt := range tests {
+		if err := tt.h.Validate(); !errors.Is(err, ErrInvalidHours) {
+			t.Errorf("%s: Validate() = %v, want ErrInvalidHours", tt.name, err)
+		}
+	}
+}
+
+func TestValidateHoursNormalizesWeekdays(t *testing.T) {
+	h := BusinessHours{
+		Timezone: "UTC",
+		Weekly: map[string]DayHours{
+			" Monday ": {Open: "08:00", Close: "17:00"},
+			"sunday":   {Closed: true, Open: "bad", Close: "bad"}, // Hours of closed days are not checked
+		},
+	}
+	if err := h.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if _, ok := h.Weekly["monday"]; !ok {
+		t.Fatalf("Weekly = %v, want a monday key", h.Weekly)
+	}
+}
+
+func TestWithDailyHours(t *testing.T) {
+	h := testHours(t)
+	c := h.WithDailyHours(10, 14)
+
+	if !c.Weekly["tuesday"].Closed {
+		t.Error("tuesday was reopened, closed days must stay closed")
+	}
+	if d := c.Weekly["friday"]; d.Open != "10:00" || d.Close != "14:00" {
+		t.Errorf("friday = %s-%s, want 10:00-14:00", d.Open, d.Close)
+	}
+	if d := h.Weekly["friday"]; d.Open != "00:00" {
+		t.Errorf("the original calendar changed: friday opens at %s", d.Open)
+	}
+	reversed := h.WithDailyHours(14, 10)
+	if err := reversed.Validate(); !errors.Is(err, ErrInvalidHours) {
+		t.Errorf("Validate() of 14-10 = %v, want ErrInvalidHours", err)
+	}
+}
+
+func TestNextOpen(t *testing.T) {
+	h := testHours(t)
+	tests := []struct {
+		name string
+		at   time.Time
+		want time.Time
+	}{
+		{"open now", jakarta(t, 5, 10, 30), jakarta(t, 5, 10, 30)},
+		{"before opening", jakarta(t, 5, 7, 0), jakarta(t, 5, 8, 0)},
+		{"at closing skips the closed day", jakarta(t, 5, 17, 0), jakarta(t, 7, 8, 0)},
+		{"before the holiday", jakarta(t, 1, 6, 0), jakarta(t, 2, 0, 0)},
+		{"after the morning hours skips the missing day", jakarta(t, 3, 12, 0), jakarta(t, 5, 8, 0)},
+		{"evening before a day open from midnight", jakarta(t, 8, 20, 0), jakarta(t, 9, 0, 0)},
+		{"UTC time", time.Date(2026, time.January, 5, 10, 0, 0, 0, time.UTC), jakarta(t, 7, 8, 0)},
+	}
+	for _, tt := range tests {
+		if got := h.NextOpen(tt.at); !got.Equal(tt.want) {
+			t.Errorf("%s: NextOpen(%v) = %v, want %v", tt.name, tt.at, got, tt.want)
+		}
+	}
+}
+
+func TestNextOpenNever(t *testing.T) {
+	h := BusinessHours{Timezone: "UTC", Weekly: map[string]DayHours{"monday": {Closed: true}}}
+	if err := h.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if got := h.NextOpen(time.Now()); !got.IsZero() {
+		t.Fatalf("NextOpen() = %v, want the zero time", got)
+	}
+}
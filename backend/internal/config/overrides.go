@@ -0,0 +1,192 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrInvalidSettings is returned when account settings are out of range
+var ErrInvalidSettings = errors.New("invalid settings")
+
+// SettingsOverride replaces some of the global settings for one account.
+// Nil fields inherit the global value.
+type SettingsOverride struct {
+	AutoReplyEnabled  *bool               `json:"auto_reply_enabled"`
+	AwayEnabled       *bool               `json:"away_enabled"`
+	AwayMessage       *string             `json:"away_message"`
+	Hours             *BusinessHours      `json:"business_hours"`
+	MinDelayMs        *int                `json:"min_delay_ms"`
+	MaxDelayMs        *int                `json:"max_delay_ms"`
+	DailyLimitPerUser *int                `json:"daily_limit_per_user"`
+	AccountPerMinute  *int                `json:"account_per_minute"`
+	AccountPerHour    *int                `json:"account_per_hour"`
+	AccountPerDay     *int                `json:"account_per_day"`
+	FrequencyCapMin   *int                `json:"frequency_cap_minutes"`
+	BlockedWords      []string            `json:"blocked_words"` // Replaces the global list when not null
+	Policies          map[string][]string `json:"policies"`      // Replaces the checks of the listed message types
+}
+
+var (
+	overrides   = make(map[string]SettingsOverride) // key: account ID
+	overridesMu sync.RWMutex
+)
+
+// isEmpty reports whether the override inherits every setting
+func (o SettingsOverride) isEmpty() bool {
+	return o.AutoReplyEnabled == nil && o.AwayEnabled == nil && o.AwayMessage == nil && o.Hours == nil &&
+		o.MinDelayMs == nil && o.MaxDelayMs == nil && o.DailyLimitPerUser == nil &&
+		o.AccountPerMinute == nil && o.AccountPerHour == nil && o.AccountPerDay == nil &&
+		o.FrequencyCapMin == nil && o.BlockedWords == nil && len(o.Policies) == 0
+}
+
+// apply writes the overridden fields over d
+func (o SettingsOverride) apply(d *settingsData) {
+	if o.AutoReplyEnabled != nil {
+		d.AutoReplyEnabled = *o.AutoReplyEnabled
+	}
+	if o.AwayEnabled != nil {
+		d.AwayEnabled = *o.AwayEnabled
+	}
+	if o.AwayMessage != nil {
+		d.AwayMessage = *o.AwayMessage
+	}
+	if o.Hours != nil {
+		d.Hours = o.Hours.clone()
+	}
+	if o.MinDelayMs != nil {
+		d.MinDelayMs = *o.MinDelayMs
+	}
+	if o.MaxDelayMs != nil {
+		d.MaxDelayMs = *o.MaxDelayMs
+	}
+	if o.DailyLimitPerUser != nil {
+		d.DailyLimitPerUser = *o.DailyLimitPerUser
+	}
+	if o.AccountPerMinute != nil {
+		d.AccountPerMinute = *o.AccountPerMinute
+	}
+	if o.AccountPerHour != nil {
+		d.AccountPerHour = *o.AccountPerHour
+	}
+	if o.AccountPerDay != nil {
+		d.AccountPerDay = *o.AccountPerDay
+	}
+	if o.FrequencyCapMin != nil {
+		d.FrequencyCapMin = *o.FrequencyCapMin
+	}
+	if o.BlockedWords != nil {
+		d.BlockedWords = append([]string{}, o.BlockedWords...)
+	}
+	for msgType, checks := range o.Policies {
+		d.Policies[msgType] = append([]string{}, checks...)
+	}
+}
+
+// validate checks the override against the global settings it is applied to.
+// Overrides set through the API and restored from the store go through it alike.
+func (o *SettingsOverride) validate() error {
+	if err := validatePolicies(o.Policies); err != nil {
+		return err
+	}
+	if o.Hours != nil {
+		h := o.Hours.clone()
+		if err := h.Validate(); err != nil {
+			return err
+		}
+		o.Hours = &h
+	}
+
+	d := Settings.data()
+	o.apply(&d)
+	switch {
+	case d.MinDelayMs < 1000:
+		return fmt.Errorf("%w: min_delay_ms must be at least 1000", ErrInvalidSettings)
+	case d.MaxDelayMs < d.MinDelayMs:
+		return fmt.Errorf("%w: max_delay_ms must not be below min_delay_ms", ErrInvalidSettings)
+	case d.DailyLimitPerUser < 1:
+		return fmt.Errorf("%w: daily_limit_per_user must be at least 1", ErrInvalidSettings)
+	case d.AccountPerMinute < 0 || d.AccountPerHour < 0 || d.AccountPerDay < 0:
+		return fmt.Errorf("%w: account limits must not be negative", ErrInvalidSettings)
+	case d.FrequencyCapMin < 0:
+		return fmt.Errorf("%w: frequency_cap_minutes must not be negative", ErrInvalidSettings)
+	}
+	return nil
+}
+
+// SettingsFor returns the settings in effect for an account: the global
+// settings with the account's override applied
+func SettingsFor(accountID string) *BotSettings {
+	overridesMu.RLock()
+	o, ok := overrides[accountID]
+	overridesMu.RUnlock()
+	if !ok {
+		return Settings
+	}
+
+	d := Settings.data()
+	o.apply(&d)
+	s := &BotSettings{}
+	s.set(d)
+	return s
+}
+
+// GetAccountOverride returns the override of an account, empty when it has none
+func GetAccountOverride(accountID string) SettingsOverride {
+	overridesMu.RLock()
+	defer overridesMu.RUnlock()
+	return overrides[accountID]
+}
+
+// SetAccountOverride validates and replaces the override of an account.
+// An empty override removes it.
+func SetAccountOverride(accountID string, o SettingsOverride) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+	if o.isEmpty() {
+		delete(overrides, accountID)
+	} else {
+		overrides[accountID] = o
+	}
+	return nil
+}
+
+// DeleteAccountOverride removes the override of an account
+func DeleteAccountOverride(accountID string) {
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+	delete(overrides, accountID)
+}
+
+// ExportOverrides returns every account override as a JSON document for storage
+func ExportOverrides() ([]byte, error) {
+	overridesMu.RLock()
+	defer overridesMu.RUnlock()
+	return json.Marshal(overrides)
+}
+
+// ImportOverrides replaces the account overrides with a document written by
+// ExportOverrides. Every override is validated like SetAccountOverride does,
+// against the global settings, so those must be imported first.
+func ImportOverrides(data []byte) error {
+	loaded := make(map[string]SettingsOverride)
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return err
+	}
+	for id, o := range loaded {
+		if err := o.validate(); err != nil {
+			return fmt.Errorf("account %s: %w", id, err)
+		}
+		loaded[id] = o
+	}
+
+	overridesMu.Lock()
+	defer overridesMu.Unlock()
+	overrides = loaded
+	return nil
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+// override sets the override of an account until the test ends
+func override(t *testing.T, accountID string, o SettingsOverride) {
+	t.Helper()
+	if err := SetAccountOverride(accountID, o); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { DeleteAccountOverride(accountID) })
+}
+
+func TestSettingsForMergesTheOverride(t *testing.T) {
+	override(t, "acc-a", SettingsOverride{
+		AwayMessage:       ptr("Tutup"),
+		DailyLimitPerUser: ptr(10),
+		BlockedWords:      []string{"judi"},
+		Policies:          map[string][]string{"manual": {"content"}},
+	})
+
+	if SettingsFor("acc-b") != Settings {
+		t.Fatal("SettingsFor() of an account without an override is not the global settings")
+	}
+
+	s := SettingsFor("acc-a")
+	if s.GetAwayMessage() != "Tutup" {
+		t.Errorf("away message = %q, want the override", s.GetAwayMessage())
+	}
+	minDelay, maxDelay, dailyLimit := s.GetRateLimits()
+	globalMin, globalMax, _ := Settings.GetRateLimits()
+	if dailyLimit != 10 || minDelay != globalMin || maxDelay != globalMax {
+		t.Errorf("rate limits = %d, %d, %d, want the global delays with a limit of 10", minDelay, maxDelay, dailyLimit)
+	}
+	if _, words := s.GetContentRules(); !slices.Equal(words, []string{"judi"}) {
+		t.Errorf("blocked words = %v, want the override's list", words)
+	}
+	if checks, _ := s.GetPolicy("manual"); !slices.Equal(checks, []string{"content"}) {
+		t.Errorf("manual policy = %v, want the override", checks)
+	}
+	global, _ := Settings.GetPolicy("system")
+	if checks, _ := s.GetPolicy("system"); !slices.Equal(checks, global) {
+		t.Errorf("system policy = %v, want the global %v", checks, global)
+	}
+	if s.IsAutoReplyEnabled() != Settings.IsAutoReplyEnabled() {
+		t.Error("auto-reply is not inherited")
+	}
+}
+
+func TestOverrideIsValidatedAgainstTheGlobalSettings(t *testing.T) {
+	_, maxDelay, _ := Settings.GetRateLimits()
+	tests := []struct {
+		name string
+		o    SettingsOverride
+	}{
+		{"min delay above the global max", SettingsOverride{MinDelayMs: ptr(maxDelay + 1)}},
+		{"min delay too short", SettingsOverride{MinDelayMs: ptr(500)}},
+		{"no daily messages", SettingsOverride{DailyLimitPerUser: ptr(0)}},
+		{"negative account limit", SettingsOverride{AccountPerHour: ptr(-1)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetAccountOverride("acc-a", tt.o); !errors.Is(err, ErrInvalidSettings) {
+				DeleteAccountOverride("acc-a")
+				t.Fatalf("SetAccountOverride() = %v, want ErrInvalidSettings", err)
+			}
+		})
+	}
+	if err := SetAccountOverride("acc-a", SettingsOverride{Hours: &BusinessHours{Timezone: "Mars/Olympus"}}); err == nil {
+		DeleteAccountOverride("acc-a")
+		t.Fatal("SetAccountOverride() with an unknown timezone succeeded")
+	}
+}
+
+func TestOverridesRoundTrip(t *testing.T) {
+	override(t, "acc-a", SettingsOverride{AwayEnabled: ptr(false)})
+	data, err := ExportOverrides()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	DeleteAccountOverride("acc-a")
+	if err := ImportOverrides(data); err != nil {
+		t.Fatal(err)
+	}
+	if SettingsFor("acc-a").IsAwayEnabled() {
+		t.Fatal("imported override lost away_enabled")
+	}
+
+	// A broken document leaves the overrides as they were
+	if err := ImportOverrides([]byte(`{"acc-b": {"business_hours": {"timezone": "Mars/Olympus"}}}`)); err == nil {
+		t.Fatal("ImportOverrides() with an unknown timezone succeeded")
+	}
+	for _, doc := range []string{
+		`{"acc-b": {"min_delay_ms": 0}}`,
+		`{"acc-b": {"daily_limit_per_user": -1}}`,
+		`{"acc-b": {"account_per_day": -5}}`,
+		`{"acc-b": {"frequency_cap_minutes": -1}}`,
+	} {
+		if err := ImportOverrides([]byte(doc)); !errors.Is(err, ErrInvalidSettings) {
+			t.Fatalf("ImportOverrides(%s) = %v, want ErrInvalidSettings", doc, err)
+		}
+	}
+	if SettingsFor("acc-a").IsAwayEnabled() {
+		t.Fatal("failed import replaced the overrides")
+	}
+}
@@ -1,20 +1,27 @@
 package config
 
 import (
+	"encoding/json"
+	"slices"
 	"sync"
 	"time"
 )
 
 // BotSettings holds runtime settings for the bot
 type BotSettings struct {
-	AutoReplyEnabled  bool   `json:"auto_reply_enabled"`
-	AwayEnabled       bool   `json:"away_enabled"`
-	AwayMessage       string `json:"away_message"`
-	OperatingStart    int    `json:"operating_start"`      // Hour in 24h format (e.g. 8 for 08:00)
-	OperatingEnd      int    `json:"operating_end"`        // Hour in 24h format (e.g. 20 for 20:00)
-	MinDelayMs        int    `json:"min_delay_ms"`         // Minimum delay between messages
-	MaxDelayMs        int    `json:"max_delay_ms"`         // Maximum delay between messages
-	DailyLimitPerUser int    `json:"daily_limit_per_user"` // Max messages per user per day
+	AutoReplyEnabled  bool                `json:"auto_reply_enabled"`
+	AwayEnabled       bool                `json:"away_enabled"`
+	AwayMessage       string              `json:"away_message"`
+	Hours             BusinessHours       `json:"business_hours"`        // When the business is open, outside it the away message is sent
+	MinDelayMs        int                 `json:"min_delay_ms"`          // Minimum delay between messages
+	MaxDelayMs        int                 `json:"max_delay_ms"`          // Maximum delay between messages
+	DailyLimitPerUser int                 `json:"daily_limit_per_user"`  // Max messages per user per day
+	AccountPerMinute  int                 `json:"account_per_minute"`    // Max messages sent by an account per minute, 0 for no limit
+	AccountPerHour    int                 `json:"account_per_hour"`      // Max messages sent by an account per hour, 0 for no limit
+	AccountPerDay     int                 `json:"account_per_day"`       // Max messages sent by an account per day, 0 for no limit
+	FrequencyCapMin   int                 `json:"frequency_cap_minutes"` // Min minutes between non-reply messages to a user, 0 for no cap
+	BlockedWords      []string            `json:"blocked_words"`         // Messages containing these words are refused
+	Policies          map[string][]string `json:"policies"`              // Anti-ban checks run for each message type
 	mu                sync.RWMutex
 }
 
@@ -22,11 +29,167 @@ var Settings = &BotSettings{
 	AutoReplyEnabled:  true,
 	AwayEnabled:       true,
 	AwayMessage:       "Terima kasih telah menghubungi kami. Saat ini kami sedang di luar jam operasional.\n\n📅 Jam Operasional:\nSenin - Sabtu: 08.00 - 20.00 WIB\n\nPesan Anda akan kami balas saat jam kerja. Terima kasih! 🙏",
-	OperatingStart:    8,
-	OperatingEnd:      20,
+	Hours:             defaultBusinessHours("Asia/Jakarta", 8, 20),
 	MinDelayMs:        3000,  // 3 seconds
 	MaxDelayMs:        10000, // 10 seconds
 	DailyLimitPerUser: 5,     // 5 messages per user per day
+	AccountPerMinute:  10,
+	AccountPerHour:    200,
+	AccountPerDay:     1000,
+	FrequencyCapMin:   60,
+	BlockedWords:      []string{},
+	Policies:          DefaultPolicies(),
+}
+
+// DefaultPolicies returns the anti-ban checks of each message type, see the rules package
+func DefaultPolicies() map[string][]string {
+	return map[string][]string{
+		"reply":     {"blocked", "account_health", "content"},
+		"away":      {"blocked", "account_health", "content"},
+		"manual":    {"blocked", "daily_quota", "account_health", "content"},
+		"system":    {"blocked", "opt_out", "business_hours", "daily_quota", "frequency_cap", "account_health", "content"},
+		"promo":     {"prohibited"},
+		"broadcast": {"blocked", "opt_out", "business_hours", "daily_quota", "frequency_cap", "account_health", "content"},
+		"blast":     {"prohibited"},
+	}
+}
+
+// PolicyValidator checks the check names of imported policies. The rules
+// package owns the checks and sets it, as config cannot import rules.
+var PolicyValidator func(policies map[string][]string) error
+
+// validatePolicies runs PolicyValidator when it is set
+func validatePolicies(policies map[string][]string) error {
+	if PolicyValidator == nil {
+		return nil
+	}
+	return PolicyValidator(policies)
+}
+
+// settingsVersion is the version of the documents written by Export. Bump it
+// and add a step to migrateSettings when a stored value changes meaning.
+const settingsVersion = 1
+
+// legacyBroadcastPolicies are the broadcast defaults of documents saved before
+// they had a version: broadcasts were prohibited, then checked without the
+// daily quota and frequency cap
+var legacyBroadcastPolicies = [][]string{
+	{"prohibited"},
+	{"blocked", "opt_out", "business_hours", "account_health", "content"},
+}
+
+func init() {
+	// Resolve the default timezone
+	Settings.Hours.Validate()
+}
+
+// settingsData is the stored form of BotSettings
+type settingsData struct {
+	Version           int                 `json:"version"`
+	AutoReplyEnabled  bool                `json:"auto_reply_enabled"`
+	AwayEnabled       bool                `json:"away_enabled"`
+	AwayMessage       string              `json:"away_message"`
+	Hours             BusinessHours       `json:"business_hours"`
+	MinDelayMs        int                 `json:"min_delay_ms"`
+	MaxDelayMs        int                 `json:"max_delay_ms"`
+	DailyLimitPerUser int                 `json:"daily_limit_per_user"`
+	AccountPerMinute  int                 `json:"account_per_minute"`
+	AccountPerHour    int                 `json:"account_per_hour"`
+	AccountPerDay     int                 `json:"account_per_day"`
+	FrequencyCapMin   int                 `json:"frequency_cap_minutes"`
+	BlockedWords      []string            `json:"blocked_words"`
+	Policies          map[string][]string `json:"policies"`
+}
+
+// data returns a copy of the settings values
+func (s *BotSettings) data() settingsData {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return settingsData{
+		Version:           settingsVersion,
+		AutoReplyEnabled:  s.AutoReplyEnabled,
+		AwayEnabled:       s.AwayEnabled,
+		AwayMessage:       s.AwayMessage,
+		Hours:             s.Hours.clone(),
+		MinDelayMs:        s.MinDelayMs,
+		MaxDelayMs:        s.MaxDelayMs,
+		DailyLimitPerUser: s.DailyLimitPerUser,
+		AccountPerMinute:  s.AccountPerMinute,
+		AccountPerHour:    s.AccountPerHour,
+		AccountPerDay:     s.AccountPerDay,
+		FrequencyCapMin:   s.FrequencyCapMin,
+		BlockedWords:      append([]string{}, s.BlockedWords...),
+		Policies:          clonePolicies(s.Policies),
+	}
+}
+
+// set replaces the settings values
+func (s *BotSettings) set(d settingsData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.AutoReplyEnabled = d.AutoReplyEnabled
+	s.AwayEnabled = d.AwayEnabled
+	s.AwayMessage = d.AwayMessage
+	s.Hours = d.Hours
+	s.MinDelayMs = d.MinDelayMs
+	s.MaxDelayMs = d.MaxDelayMs
+	s.DailyLimitPerUser = d.DailyLimitPerUser
+	s.AccountPerMinute = d.AccountPerMinute
+	s.AccountPerHour = d.AccountPerHour
+	s.AccountPerDay = d.AccountPerDay
+	s.FrequencyCapMin = d.FrequencyCapMin
+	s.BlockedWords = d.BlockedWords
+	s.Policies = d.Policies
+}
+
+// Export returns the settings as a JSON document for storage
+func (s *BotSettings) Export() ([]byte, error) {
+	return json.Marshal(s.data())
+}
+
+// Import applies a document written by Export. Fields missing from it keep
+// their current values, so settings added later fall back to their defaults.
+// It reports whether the document was from an older version and was migrated,
+// in which case it should be saved again so the migration runs only once.
+func (s *BotSettings) Import(data []byte) (bool, error) {
+	d := s.data()
+	current := d.Hours
+	d.Hours = BusinessHours{}
+	d.Version = 0
+	if err := json.Unmarshal(data, &d); err != nil {
+		return false, err
+	}
+	// The calendar is replaced as a whole, never merged day by day
+	if d.Hours.Timezone == "" {
+		d.Hours = current
+	}
+	migrated := migrateSettings(&d)
+	if err := d.Hours.Validate(); err != nil {
+		return false, err
+	}
+	if err := validatePolicies(d.Policies); err != nil {
+		return false, err
+	}
+	s.set(d)
+	return migrated, nil
+}
+
+// migrateSettings upgrades a stored document to settingsVersion and reports
+// whether it was older. Values of current documents are never changed.
+func migrateSettings(d *settingsData) bool {
+	if d.Version >= settingsVersion {
+		return false
+	}
+	// Version 1: broadcasts run their own checks. Only an untouched legacy
+	// default is replaced, a chain edited by the operator is kept.
+	for _, legacy := range legacyBroadcastPolicies {
+		if slices.Equal(d.Policies["broadcast"], legacy) {
+			d.Policies["broadcast"] = DefaultPolicies()["broadcast"]
+			break
+		}
+	}
+	d.Version = settingsVersion
+	return true
 }
 
 // IsAutoReplyEnabled returns whether auto-reply is enabled
@@ -71,19 +234,51 @@ func (s *BotSettings) SetAwayMessage(msg string) {
 	s.AwayMessage = msg
 }
 
-// GetOperatingHours returns start and end hours
+// GetOperatingHours returns the start and end hour of the first open weekday,
+// a summary of the weekly calendar for clients that only know one range
 func (s *BotSettings) GetOperatingHours() (int, int) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return s.OperatingStart, s.OperatingEnd
+	for _, day := range weekdays[1:] {
+		if d, ok := s.Hours.Weekly[day]; ok && !d.Closed {
+			start, _ := parseClock(d.Open)
+			end, _ := parseClock(d.Close)
+			return start / 60, end / 60
+		}
+	}
+	return 0, 0
+}
+
+// SetOperatingHours opens every weekday that is not closed from start to end hour
+func (s *BotSettings) SetOperatingHours(start, end int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	h := s.Hours.WithDailyHours(start, end)
+	if err := h.Validate(); err != nil {
+		return err
+	}
+	s.Hours = h
+	return nil
+}
+
+// GetBusinessHours returns a copy of the weekly calendar
+func (s *BotSettings) GetBusinessHours() BusinessHours {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Hours.clone()
 }
 
-// SetOperatingHours sets operating hours
-func (s *BotSettings) SetOperatingHours(start, end int) {
+// SetBusinessHours validates and replaces the weekly calendar
+func (s *BotSettings) SetBusinessHours(h BusinessHours) error {
+	h = h.clone()
+	if err := h.Validate(); err != nil {
+		return err
+	}
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.OperatingStart = start
-	s.OperatingEnd = end
+	s.Hours = h
+	return nil
 }
 
 // GetRateLimits returns rate limit settings
@@ -108,23 +303,27 @@ func (s *BotSettings) SetRateLimits(minDelay, maxDelay, dailyLimit int) {
 	}
 }
 
-// IsWithinOperatingHours checks if current time is within operating hours
-func (s *BotSettings) IsWithinOperatingHours() bool {
+// GetAccountLimits returns the per-account send limits per minute, hour and day
+func (s *BotSettings) GetAccountLimits() (int, int, int) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
+	return s.AccountPerMinute, s.AccountPerHour, s.AccountPerDay
+}
 
-	// Get current hour in local timezone
-	now := time.Now()
-	hour := now.Hour()
-	weekday := now.Weekday()
-
-	// Check if it's Sunday (closed)
-	if weekday == time.Sunday {
-		return false
-	}
+// SetAccountLimits sets the per-account send limits, 0 disables a limit
+func (s *BotSettings) SetAccountLimits(perMinute, perHour, perDay int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.AccountPerMinute = max(perMinute, 0)
+	s.AccountPerHour = max(perHour, 0)
+	s.AccountPerDay = max(perDay, 0)
+}
 
-	// Check if within operating hours
-	return hour >= s.OperatingStart && hour < s.OperatingEnd
+// IsWithinOperatingHours checks if the business is open now
+func (s *BotSettings) IsWithinOperatingHours() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.Hours.IsOpen(time.Now())
 }
 
 // ShouldSendAwayMessage returns if away message should be sent
@@ -135,19 +334,85 @@ func (s *BotSettings) ShouldSendAwayMessage() bool {
 	return !s.IsWithinOperatingHours()
 }
 
+// GetContentRules returns the frequency cap in minutes and the blocked words
+func (s *BotSettings) GetContentRules() (int, []string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.FrequencyCapMin, append([]string{}, s.BlockedWords...)
+}
+
+// SetContentRules sets the frequency cap in minutes (0 disables it) and the blocked words
+func (s *BotSettings) SetContentRules(frequencyCapMin int, blockedWords []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.FrequencyCapMin = max(frequencyCapMin, 0)
+	if blockedWords == nil {
+		blockedWords = []string{}
+	}
+	s.BlockedWords = append([]string{}, blockedWords...)
+}
+
+// GetPolicy returns the anti-ban checks of a message type, and false for unknown types
+func (s *BotSettings) GetPolicy(msgType string) ([]string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	checks, ok := s.Policies[msgType]
+	return append([]string{}, checks...), ok
+}
+
+// GetPolicies returns the anti-ban checks of every message type
+func (s *BotSettings) GetPolicies() map[string][]string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return clonePolicies(s.Policies)
+}
+
+// SetPolicies replaces the checks of the given message types, keeping the others
+func (s *BotSettings) SetPolicies(policies map[string][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	merged := clonePolicies(s.Policies)
+	for msgType, checks := range policies {
+		merged[msgType] = append([]string{}, checks...)
+	}
+	s.Policies = merged
+}
+
+func clonePolicies(policies map[string][]string) map[string][]string {
+	c := make(map[string][]string, len(policies))
+	for msgType, checks := range policies {
+		c[msgType] = append([]string{}, checks...)
+	}
+	return c
+}
+
 // GetAllSettings returns all current settings
 func GetAllSettings() map[string]interface{} {
-	start, end := Settings.GetOperatingHours()
-	minDelay, maxDelay, dailyLimit := Settings.GetRateLimits()
+	return Settings.All()
+}
+
+// All returns the settings in the shape of GET /api/settings
+func (s *BotSettings) All() map[string]interface{} {
+	start, end := s.GetOperatingHours()
+	minDelay, maxDelay, dailyLimit := s.GetRateLimits()
+	perMinute, perHour, perDay := s.GetAccountLimits()
+	frequencyCap, blockedWords := s.GetContentRules()
 	return map[string]interface{}{
-		"auto_reply_enabled":   Settings.IsAutoReplyEnabled(),
-		"away_enabled":         Settings.IsAwayEnabled(),
-		"away_message":         Settings.GetAwayMessage(),
-		"operating_start":      start,
-		"operating_end":        end,
-		"is_operating":         Settings.IsWithinOperatingHours(),
-		"min_delay_ms":         minDelay,
-		"max_delay_ms":         maxDelay,
-		"daily_limit_per_user": dailyLimit,
+		"auto_reply_enabled":    s.IsAutoReplyEnabled(),
+		"away_enabled":          s.IsAwayEnabled(),
+		"away_message":          s.GetAwayMessage(),
+		"operating_start":       start,
+		"operating_end":         end,
+		"is_operating":          s.IsWithinOperatingHours(),
+		"min_delay_ms":          minDelay,
+		"max_delay_ms":          maxDelay,
+		"daily_limit_per_user":  dailyLimit,
+		"business_hours":        s.GetBusinessHours(),
+		"account_per_minute":    perMinute,
+		"account_per_hour":      perHour,
+		"account_per_day":       perDay,
+		"frequency_cap_minutes": frequencyCap,
+		"blocked_words":         blockedWords,
+		"policies":              s.GetPolicies(),
 	}
 }
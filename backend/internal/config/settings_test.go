@@ -0,0 +1,65 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func newTestSettings(t *testing.T) *BotSettings {
+	t.Helper()
+	s := &BotSettings{Hours: defaultBusinessHours("UTC", 8, 20), Policies: DefaultPolicies()}
+	if err := s.Hours.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestImportMigratesBroadcastPolicy(t *testing.T) {
+	custom := []string{"blocked", "opt_out"}
+	tests := []struct {
+		name         string
+		doc          string
+		wantMigrated bool
+		want         []string
+	}{
+		{"unversioned prohibited default", `{"policies": {"broadcast": ["prohibited"]}}`, true, DefaultPolicies()["broadcast"]},
+		{"unversioned checks without quota", `{"policies": {"broadcast": ["blocked", "opt_out", "business_hours", "account_health", "content"]}}`, true, DefaultPolicies()["broadcast"]},
+		{"unversioned custom chain", `{"policies": {"broadcast": ["blocked", "opt_out"]}}`, true, custom},
+		{"unversioned without policies", `{"away_message": "Tutup"}`, true, DefaultPolicies()["broadcast"]},
+		{"current prohibited is kept", `{"version": 1, "policies": {"broadcast": ["prohibited"]}}`, false, []string{"prohibited"}},
+		{"current custom chain", `{"version": 1, "policies": {"broadcast": ["blocked", "opt_out"]}}`, false, custom},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSettings(t)
+			migrated, err := s.Import([]byte(tt.doc))
+			if err != nil {
+				t.Fatalf("Import() = %v", err)
+			}
+			if migrated != tt.wantMigrated {
+				t.Errorf("Import() migrated = %v, want %v", migrated, tt.wantMigrated)
+			}
+			if got, _ := s.GetPolicy("broadcast"); !slices.Equal(got, tt.want) {
+				t.Errorf("broadcast policy = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExportedSettingsAreNotMigratedAgain(t *testing.T) {
+	s := newTestSettings(t)
+	s.SetPolicies(map[string][]string{"broadcast": {"prohibited"}})
+	data, err := s.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	restored := newTestSettings(t)
+	migrated, err := restored.Import(data)
+	if err != nil || migrated {
+		t.Fatalf("Import() = %v, %v, want no migration", migrated, err)
+	}
+	if got, _ := restored.GetPolicy("broadcast"); !slices.Equal(got, []string{"prohibited"}) {
+		t.Fatalf("broadcast policy = %v, want the saved [prohibited]", got)
+	}
+}
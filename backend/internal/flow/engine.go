@@ -0,0 +1,361 @@
+package flow
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"esther-whatsapp/internal/store"
+)
+
+// DefaultTimeout ends a conversation when the user stops answering
+const DefaultTimeout = 5 * time.Minute
+
+// Words that control a running flow
+var (
+	backWords   = map[string]bool{"0": true, "back": true, "kembali": true}
+	cancelWords = map[string]bool{"cancel": true, "batal": true, "keluar": true}
+)
+
+const (
+	invalidChoiceMessage = "⚠️ Pilihan tidak valid, silakan pilih salah satu opsi di bawah."
+	cancelledMessage     = "✅ Percakapan dibatalkan. Ketik *info* untuk melihat menu."
+	finishedMessage      = "✅ Terima kasih!"
+	menuFooter           = "Ketik *0* untuk kembali atau *batal* untuk keluar."
+)
+
+var (
+	// ErrInvalidFlow is returned when a flow definition is inconsistent
+	ErrInvalidFlow = errors.New("invalid flow")
+	// ErrDuplicateTrigger is returned when another flow of the same account scope has the trigger
+	ErrDuplicateTrigger = errors.New("trigger already used")
+)
+
+// session is a user's position inside a flow
+type session struct {
+	flowID    string
+	node      string
+	history   []string
+	updatedAt time.Time
+}
+
+// Engine runs conversational flows and remembers each user's current node
+type Engine struct {
+	flows     map[string]store.Flow
+	sessions  map[string]*session // key: accountID + ":" + phone
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// Default is the engine used by the WhatsApp handler
+var Default = &Engine{
+	flows:    make(map[string]store.Flow),
+	sessions: make(map[string]*session),
+}
+
+// Load loads all flows from the store
+func (e *Engine) Load() error {
+	flows, err := store.DB.GetFlows()
+	if err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.flows = make(map[string]store.Flow, len(flows))
+	for _, f := range flows {
+		e.flows[f.ID] = f
+	}
+	return nil
+}
+
+// List returns all flows
+func (e *Engine) List() []store.Flow {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	result := make([]store.Flow, 0, len(e.flows))
+	for _, f := range e.flows {
+		result = append(result, f)
+	}
+	return result
+}
+
+// Get returns a flow by ID
+func (e *Engine) Get(id string) (store.Flow, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	f, ok := e.flows[id]
+	return f, ok
+}
+
+// Save validates and stores a flow, creating it when ID is empty. A trigger
+// starts one flow per account scope: global flows share one scope, and each
+// account has its own.
+func (e *Engine) Save(f store.Flow) (store.Flow, error) {
+	f.Trigger = strings.ToLower(strings.TrimSpace(f.Trigger))
+	if err := Validate(f); err != nil {
+		return store.Flow{}, err
+	}
+
+	// Held through the write, so two saves cannot take the same trigger
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, other := range e.flows {
+		if other.ID != f.ID && other.AccountID == f.AccountID && other.Trigger == f.Trigger {
+			return store.Flow{}, fmt.Errorf("%w: %q starts flow %s", ErrDuplicateTrigger, f.Trigger, other.ID)
+		}
+	}
+	if existing, ok := e.flows[f.ID]; ok {
+		f.CreatedAt = existing.CreatedAt
+	}
+
+	saved, err := store.DB.SaveFlow(f)
+	if err != nil {
+		return store.Flow{}, err
+	}
+
+	e.flows[saved.ID] = saved
+	// Sessions may point at nodes that no longer exist
+	for key, s := range e.sessions {
+		if s.flowID == saved.ID {
+			delete(e.sessions, key)
+		}
+	}
+	return saved, nil
+}
+
+// Delete removes a flow and ends its running sessions
+func (e *Engine) Delete(id string) error {
+	if err := store.DB.DeleteFlow(id); err != nil {
+		return err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.flows, id)
+	for key, s := range e.sessions {
+		if s.flowID == id {
+			delete(e.sessions, key)
+		}
+	}
+	return nil
+}
+
+// Validate checks that a flow is complete and all node references resolve
+func Validate(f store.Flow) error {
+	if f.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidFlow)
+	}
+	if f.Trigger == "" {
+		return fmt.Errorf("%w: trigger is required", ErrInvalidFlow)
+	}
+	if len(f.Nodes) == 0 {
+		return fmt.Errorf("%w: at least one node is required", ErrInvalidFlow)
+	}
+
+	nodes := make(map[string]bool, len(f.Nodes))
+	for _, n := range f.Nodes {
+		if n.ID == "" {
+			return fmt.Errorf("%w: every node needs an id", ErrInvalidFlow)
+		}
+		if nodes[n.ID] {
+			return fmt.Errorf("%w: duplicate node %q", ErrInvalidFlow, n.ID)
+		}
+		nodes[n.ID] = true
+	}
+	if !nodes[f.StartNode] {
+		return fmt.Errorf("%w: start node %q not found", ErrInvalidFlow, f.StartNode)
+	}
+
+	for _, n := range f.Nodes {
+		keys := make(map[string]bool, len(n.Options))
+		for _, opt := range n.Options {
+			key := strings.ToLower(strings.TrimSpace(opt.Key))
+			if key == "" {
+				return fmt.Errorf("%w: node %q has an option without key", ErrInvalidFlow, n.ID)
+			}
+			if backWords[key] || cancelWords[key] {
+				return fmt.Errorf("%w: option key %q is reserved", ErrInvalidFlow, opt.Key)
+			}
+			if keys[key] {
+				return fmt.Errorf("%w: node %q has duplicate option %q", ErrInvalidFlow, n.ID, opt.Key)
+			}
+			keys[key] = true
+			if opt.Next != "" && !nodes[opt.Next] {
+				return fmt.Errorf("%w: option %q of node %q points to unknown node %q", ErrInvalidFlow, opt.Key, n.ID, opt.Next)
+			}
+		}
+	}
+	return nil
+}
+
+// Handle feeds an incoming message to the flow engine. It returns the reply to
+// send and whether the message was consumed by a flow; when handled is false
+// the caller should fall back to keyword matching.
+func (e *Engine) Handle(accountID, phone, text string) (reply string, handled bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	now := time.Now()
+	e.sweep(now)
+
+	input := strings.ToLower(strings.TrimSpace(text))
+	key := accountID + ":" + phone
+
+	if s, ok := e.sessions[key]; ok {
+		f, exists := e.flows[s.flowID]
+		if !exists || now.Sub(s.updatedAt) > timeoutOf(f) {
+			delete(e.sessions, key)
+			log.Printf("⌛ Flow session for %s expired", phone)
+		} else {
+			return e.advance(key, s, f, input, now), true
+		}
+	}
+
+	f, ok := e.findTrigger(accountID, input)
+	if !ok {
+		return "", false
+	}
+
+	s := &session{
+		flowID:    f.ID,
+		node:      f.StartNode,
+		updatedAt: now,
+	}
+	e.sessions[key] = s
+	e.settle(key, s, f)
+	log.Printf("🧭 Flow %q started for %s", f.Name, phone)
+	return renderNode(f, f.StartNode), true
+}
+
+// settle ends the session when it reached a node without options.
+// Caller must hold e.mu.
+func (e *Engine) settle(key string, s *session, f store.Flow) {
+	if node, ok := findNode(f, s.node); !ok || len(node.Options) == 0 {
+		delete(e.sessions, key)
+	}
+}
+
+// advance moves a running session according to the user's answer.
+// Caller must hold e.mu.
+func (e *Engine) advance(key string, s *session, f store.Flow, input string, now time.Time) string {
+	s.updatedAt = now
+
+	if cancelWords[input] {
+		delete(e.sessions, key)
+		return cancelledMessage
+	}
+
+	if backWords[input] {
+		if len(s.history) > 0 {
+			s.node = s.history[len(s.history)-1]
+			s.history = s.history[:len(s.history)-1]
+		}
+		return renderNode(f, s.node)
+	}
+
+	node, ok := findNode(f, s.node)
+	if !ok {
+		delete(e.sessions, key)
+		return cancelledMessage
+	}
+
+	for _, opt := range node.Options {
+		if input != strings.ToLower(strings.TrimSpace(opt.Key)) && input != strings.ToLower(strings.TrimSpace(opt.Label)) {
+			continue
+		}
+
+		if opt.Next == "" {
+			delete(e.sessions, key)
+			if opt.Reply != "" {
+				return opt.Reply
+			}
+			return finishedMessage
+		}
+
+		s.history = append(s.history, s.node)
+		s.node = opt.Next
+		e.settle(key, s, f)
+		return renderNode(f, s.node)
+	}
+
+	return invalidChoiceMessage + "\n\n" + renderNode(f, s.node)
+}
+
+// findTrigger returns the flow started by the input, preferring the
+// account's own flows over global ones. Caller must hold e.mu.
+func (e *Engine) findTrigger(accountID, input string) (store.Flow, bool) {
+	var global *store.Flow
+	for id := range e.flows {
+		f := e.flows[id]
+		if f.Trigger != input {
+			continue
+		}
+		if f.AccountID == accountID {
+			return f, true
+		}
+		if f.AccountID == "" {
+			global = &f
+		}
+	}
+	if global != nil {
+		return *global, true
+	}
+	return store.Flow{}, false
+}
+
+// sweep drops expired sessions at most once a minute. Caller must hold e.mu.
+func (e *Engine) sweep(now time.Time) {
+	if now.Sub(e.lastSweep) < time.Minute {
+		return
+	}
+	e.lastSweep = now
+
+	for key, s := range e.sessions {
+		f, ok := e.flows[s.flowID]
+		if !ok || now.Sub(s.updatedAt) > timeoutOf(f) {
+			delete(e.sessions, key)
+		}
+	}
+}
+
+// timeoutOf returns how long a session of the flow may stay idle
+func timeoutOf(f store.Flow) time.Duration {
+	if f.TimeoutSeconds <= 0 {
+		return DefaultTimeout
+	}
+	return time.Duration(f.TimeoutSeconds) * time.Second
+}
+
+func findNode(f store.Flow, id string) (store.FlowNode, bool) {
+	for _, n := range f.Nodes {
+		if n.ID == id {
+			return n, true
+		}
+	}
+	return store.FlowNode{}, false
+}
+
+// renderNode formats a node's message followed by its numbered options
+func renderNode(f store.Flow, id string) string {
+	node, ok := findNode(f, id)
+	if !ok {
+		return cancelledMessage
+	}
+
+	var b strings.Builder
+	b.WriteString(node.Message)
+	if len(node.Options) > 0 {
+		b.WriteString("\n")
+		for _, opt := range node.Options {
+			fmt.Fprintf(&b, "\n*%s* - %s", opt.Key, opt.Label)
+		}
+		b.WriteString("\n\n")
+		b.WriteString(menuFooter)
+	}
+	return b.String()
+}
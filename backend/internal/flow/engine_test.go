@@ -0,0 +1,189 @@
+package flow
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"esther-whatsapp/internal/store"
+)
+
+// menuFlow is a two-level menu: 1 opens the products node, 2 ends with a reply
+func menuFlow() store.Flow {
+	return store.Flow{
+		ID:        "menu",
+		Name:      "Menu",
+		Trigger:   "menu",
+		StartNode: "start",
+		Nodes: []store.FlowNode{
+			{ID: "start", Message: "Main menu", Options: []store.FlowOption{
+				{Key: "1", Label: "Products", Next: "products"},
+				{Key: "2", Label: "Address", Reply: "Jl. Merdeka 1"},
+			}},
+			{ID: "products", Message: "Products", Options: []store.FlowOption{
+				{Key: "1", Label: "Prices", Next: "prices"},
+			}},
+			{ID: "prices", Message: "Price list"},
+		},
+	}
+}
+
+func newEngine(flows ...store.Flow) *Engine {
+	e := &Engine{
+		flows:    make(map[string]store.Flow),
+		sessions: make(map[string]*session),
+	}
+	for _, f := range flows {
+		e.flows[f.ID] = f
+	}
+	return e
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(f *store.Flow)
+		valid  bool
+	}{
+		{"valid", func(f *store.Flow) {}, true},
+		{"no name", func(f *store.Flow) { f.Name = "" }, false},
+		{"no trigger", func(f *store.Flow) { f.Trigger = "" }, false},
+		{"no nodes", func(f *store.Flow) { f.Nodes = nil }, false},
+		{"unknown start node", func(f *store.Flow) { f.StartNode = "nope" }, false},
+		{"node without id", func(f *store.Flow) { f.Nodes[2].ID = "" }, false},
+		{"duplicate node", func(f *store.Flow) { f.Nodes[2].ID = "products" }, false},
+		{"option without key", func(f *store.Flow) { f.Nodes[0].Options[0].Key = " " }, false},
+		{"reserved back key", func(f *store.Flow) { f.Nodes[0].Options[0].Key = "0" }, false},
+		{"reserved cancel key", func(f *store.Flow) { f.Nodes[0].Options[0].Key = "Batal" }, false},
+		{"duplicate option", func(f *store.Flow) { f.Nodes[0].Options[1].Key = " 1" }, false},
+		{"option to unknown node", func(f *store.Flow) { f.Nodes[1].Options[0].Next = "nope" }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := menuFlow()
+			tt.modify(&f)
+			err := Validate(f)
+			if tt.valid && err != nil {
+				t.Fatalf("Validate() = %v, want nil", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalidFlow) {
+				t.Fatalf("Validate() = %v, want ErrInvalidFlow", err)
+			}
+		})
+	}
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name   string
+		inputs []string
+		reply  string // Prefix of the last reply
+		active bool   // Whether a session is left afterwards
+	}{
+		{"no trigger", []string{"hello"}, "", false},
+		{"trigger", []string{"menu"}, "Main menu", true},
+		{"trigger ignores case and spaces", []string{"  MENU "}, "Main menu", true},
+		{"option by key", []string{"menu", "1"}, "Products", true},
+		{"option by label", []string{"menu", "products"}, "Products", true},
+		{"invalid option", []string{"menu", "9"}, invalidChoiceMessage, true},
+		{"back", []string{"menu", "1", "0"}, "Main menu", true},
+		{"back at start", []string{"menu", "back"}, "Main menu", true},
+		{"cancel", []string{"menu", "1", "batal"}, cancelledMessage, false},
+		{"reply ends the flow", []string{"menu", "2"}, "Jl. Merdeka 1", false},
+		{"node without options ends the flow", []string{"menu", "1", "1"}, "Price list", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newEngine(menuFlow())
+			var reply string
+			var handled bool
+			for _, input := range tt.inputs {
+				reply, handled = e.Handle("acc", "628123", input)
+			}
+			if tt.reply == "" {
+				if handled {
+					t.Fatalf("Handle() handled the message with %q", reply)
+				}
+				return
+			}
+			if !handled || !strings.HasPrefix(reply, tt.reply) {
+				t.Fatalf("Handle() = %q, %v, want a reply starting with %q", reply, handled, tt.reply)
+			}
+			if _, active := e.sessions["acc:628123"]; active != tt.active {
+				t.Fatalf("session active = %v, want %v", active, tt.active)
+			}
+		})
+	}
+}
+
+func TestHandleSessionsPerAccount(t *testing.T) {
+	e := newEngine(menuFlow())
+	e.Handle("a", "628123", "menu")
+
+	// The same phone on another account has no session, so "1" is not an answer
+	if reply, handled := e.Handle("b", "628123", "1"); handled {
+		t.Fatalf("Handle() on another account = %q, want unhandled", reply)
+	}
+	if reply, _ := e.Handle("a", "628123", "1"); !strings.HasPrefix(reply, "Products") {
+		t.Fatalf("Handle() = %q, want the products node", reply)
+	}
+}
+
+func TestHandleTimeout(t *testing.T) {
+	f := menuFlow()
+	f.TimeoutSeconds = 60
+	e := newEngine(f)
+	e.Handle("acc", "628123", "menu")
+	e.sessions["acc:628123"].updatedAt = time.Now().Add(-2 * time.Minute)
+
+	// The expired session is dropped, so "1" is no longer an answer
+	if reply, handled := e.Handle("acc", "628123", "1"); handled {
+		t.Fatalf("Handle() after timeout = %q, want unhandled", reply)
+	}
+}
+
+func TestFindTriggerPrefersAccountFlow(t *testing.T) {
+	global := menuFlow()
+	own := menuFlow()
+	own.ID = "own"
+	own.AccountID = "acc"
+	own.Nodes[0].Message = "Account menu"
+	e := newEngine(global, own)
+
+	if reply, _ := e.Handle("acc", "628123", "menu"); !strings.HasPrefix(reply, "Account menu") {
+		t.Fatalf("Handle() = %q, want the account's own flow", reply)
+	}
+	if reply, _ := e.Handle("other", "628123", "menu"); !strings.HasPrefix(reply, "Main menu") {
+		t.Fatalf("Handle() = %q, want the global flow", reply)
+	}
+}
+
+func TestSaveRejectsDuplicateTrigger(t *testing.T) {
+	global := menuFlow()
+	own := menuFlow()
+	own.ID = "own"
+	own.AccountID = "acc"
+	e := newEngine(global, own)
+
+	tests := []struct {
+		name      string
+		accountID string
+		trigger   string
+	}{
+		{"global", "", "menu"},
+		{"same account", "acc", "menu"},
+		{"case and spaces", "acc", " MENU "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := menuFlow()
+			f.ID = ""
+			f.AccountID = tt.accountID
+			f.Trigger = tt.trigger
+			if _, err := e.Save(f); !errors.Is(err, ErrDuplicateTrigger) {
+				t.Fatalf("Save() = %v, want ErrDuplicateTrigger", err)
+			}
+		})
+	}
+}
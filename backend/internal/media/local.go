@@ -0,0 +1,66 @@
+package media
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// localStorage keeps media files on disk, grouped by day
+type localStorage struct {
+	dir string
+}
+
+// NewLocal creates a storage rooted at dir
+func NewLocal(dir string) (Storage, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create media dir: %w", err)
+	}
+	return &localStorage{dir: dir}, nil
+}
+
+// Save writes data under a new unique key, keeping the extension of name
+func (s *localStorage) Save(name string, data []byte) (string, error) {
+	ext := strings.ToLower(filepath.Ext(name))
+	key := path.Join(time.Now().Format("2006/01/02"), uuid.New().String()+ext)
+
+	full := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+		return "", err
+	}
+
+	tmp := full + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, full); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return key, nil
+}
+
+// Open returns the content stored under key
+func (s *localStorage) Open(key string) (io.ReadCloser, error) {
+	return os.Open(s.path(key))
+}
+
+// Delete removes the content stored under key
+func (s *localStorage) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// path resolves a key inside the storage dir without letting it escape
+func (s *localStorage) path(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(path.Clean("/"+key)))
+}
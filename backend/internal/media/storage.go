@@ -0,0 +1,34 @@
+package media
+
+import (
+	"fmt"
+	"io"
+
+	"esther-whatsapp/internal/config"
+)
+
+// Storage keeps the content of media files. Keys are opaque to callers and
+// are what gets recorded in the media_files table.
+type Storage interface {
+	Save(name string, data []byte) (key string, err error)
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+// Default is the active storage, set by Init
+var Default Storage
+
+// Init opens the storage selected by MEDIA_STORAGE
+func Init() error {
+	switch config.AppConfig.MediaStorage {
+	case "local":
+		s, err := NewLocal(config.AppConfig.MediaStorageDir)
+		if err != nil {
+			return err
+		}
+		Default = s
+	default:
+		return fmt.Errorf("unknown media storage %q", config.AppConfig.MediaStorage)
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package queue
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"esther-whatsapp/internal/config"
+	"esther-whatsapp/internal/store"
+)
+
+// Priority lanes, highest first. Each lane has its own workers, so a backlog
+// in a lower lane never delays a higher one.
+const (
+	LaneReply  = "reply"
+	LaneManual = "manual"
+	LaneSystem = "system"
+	LaneBulk   = "bulk"
+)
+
+// Lanes lists the lanes in priority order
+var Lanes = []string{LaneReply, LaneManual, LaneSystem, LaneBulk}
+
+// LaneOf returns the lane a message type is sent in
+func LaneOf(msgType string) string {
+	switch msgType {
+	case "reply", "away":
+		return LaneReply
+	case "manual":
+		return LaneManual
+	case "system":
+		return LaneSystem
+	default:
+		return LaneBulk
+	}
+}
+
+// laneWorkers returns how many jobs of a lane may be sending at once
+func laneWorkers(name string) int {
+	var n int
+	switch name {
+	case LaneReply:
+		n = config.AppConfig.QueueReplyWorkers
+	case LaneManual:
+		n = config.AppConfig.QueueManualWorkers
+	case LaneSystem:
+		n = config.AppConfig.QueueSystemWorkers
+	default:
+		n = config.AppConfig.QueueBulkWorkers
+	}
+	return max(n, 1)
+}
+
+// lane dispatches the due jobs of one priority. Any account may use every
+// worker of the lane, but to stay fair across accounts the ones with due jobs
+// take turns: each round hands out one job per account, the account served
+// longest ago first, until the free workers are taken.
+type lane struct {
+	name    string
+	workers int
+	wake    chan struct{}
+	running int               // Jobs in flight
+	served  map[string]uint64 // Turn in which each account last got a job
+	turn    uint64
+	mu      sync.Mutex
+}
+
+func newLane(name string) *lane {
+	return &lane{
+		name:    name,
+		workers: laneWorkers(name),
+		wake:    make(chan struct{}, 1),
+		served:  make(map[string]uint64),
+	}
+}
+
+// notify wakes the lane without blocking
+func (l *lane) notify() {
+	select {
+	case l.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (l *lane) run(stop chan struct{}) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		l.dispatch(stop)
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		case <-l.wake:
+		}
+	}
+}
+
+// dispatch claims due jobs for the free workers, the oldest jobs of each
+// account first, accounts in round-robin order
+func (l *lane) dispatch(stop chan struct{}) {
+	l.mu.Lock()
+	free := l.workers - l.running
+	l.mu.Unlock()
+	if free <= 0 {
+		return
+	}
+
+	jobs, err := store.DB.GetDueJobs(l.name, l.workers, batchSize)
+	if err != nil {
+		log.Printf("❌ Failed to load due %s jobs: %v", l.name, err)
+		return
+	}
+
+	next := l.nextJobs(jobs)
+	for _, p := range next {
+		if free == 0 {
+			return
+		}
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		l.mu.Lock()
+		l.running++
+		l.turn++
+		l.served[p.accountID] = l.turn
+		l.mu.Unlock()
+
+		if !claim(p.job) {
+			l.release()
+			continue
+		}
+		free--
+
+		wg.Add(1)
+		go func(j *store.Job, accountID string) {
+			defer wg.Done()
+			defer l.release()
+			processJob(j, accountID, stop)
+		}(p.job, p.accountID)
+	}
+}
+
+// pick is a job and the account chosen to send it
+type pick struct {
+	job       *store.Job
+	accountID string
+}
+
+// nextJobs resolves the sender of each due job and orders the jobs in rounds:
+// each round has the next oldest job of every account, the account served
+// longest ago first. Jobs whose sender cannot be resolved fail right away.
+func (l *lane) nextJobs(jobs []store.Job) []pick {
+	// Account selection may hit the store, resolve each phone once per round
+	selected := make(map[string]string)
+	byAccount := make(map[string][]pick)
+	accounts := make([]string, 0)
+
+	for i := range jobs {
+		j := &jobs[i]
+		key := j.AccountID + ":" + j.Strategy + ":" + j.Phone // Pinned jobs never share an unpinned pick
+		accountID, ok := selected[key]
+		if !ok {
+			var err error
+			accountID, err = selectAccount(j.AccountID, j.Phone, j.Strategy)
+			if err != nil {
+				// Nothing to wait for, count the attempt right away
+				if claim(j) {
+					failJob(j, err)
+				}
+				continue
+			}
+			selected[key] = accountID
+		}
+
+		if _, seen := byAccount[accountID]; !seen {
+			accounts = append(accounts, accountID)
+		}
+		byAccount[accountID] = append(byAccount[accountID], pick{job: j, accountID: accountID})
+	}
+
+	l.mu.Lock()
+	// Never served accounts have turn 0 and go first; ties keep the oldest job first
+	sort.SliceStable(accounts, func(a, b int) bool {
+		return l.served[accounts[a]] < l.served[accounts[b]]
+	})
+	l.mu.Unlock()
+
+	next := make([]pick, 0, len(jobs))
+	for round := 0; ; round++ {
+		added := false
+		for _, accountID := range accounts {
+			if picks := byAccount[accountID]; round < len(picks) {
+				next = append(next, picks[round])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	return next
+}
+
+// release frees a worker and looks for more work
+func (l *lane) release() {
+	l.mu.Lock()
+	l.running--
+	l.mu.Unlock()
+	l.notify()
+}
@@ -0,0 +1,384 @@
+package queue
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"esther-whatsapp/internal/config"
+	"esther-whatsapp/internal/store"
+	"esther-whatsapp/internal/whatsapp"
+)
+
+// TestMain runs the queue against a throwaway SQLite store, without delays
+// and with policies that only look at the content
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "queue-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.AppConfig = &config.Config{
+		StoreDriver:      "sqlite",
+		SQLitePath:       filepath.Join(dir, "test.db"),
+		QueueMaxAttempts: 3,
+	}
+	store.DB, err = store.NewSQLite(config.AppConfig.SQLitePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+	humanDelay = noDelay
+	config.Settings.SetPolicies(map[string][]string{
+		"reply":  {"content"},
+		"manual": {"content"},
+		"system": {"content"},
+		"promo":  {"content"},
+	})
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func noDelay(string) time.Duration { return 0 }
+
+// delivery is a message handed to the stubbed WhatsApp send
+type delivery struct {
+	accountID string
+	phone     string
+	chat      string
+	msgType   string
+}
+
+// fakeSender stands in for the account manager. Sends block while held,
+// and fail with the error set for their phone.
+type fakeSender struct {
+	mu        sync.Mutex
+	delivered []delivery
+	errs      map[string]error
+	hold      chan struct{}
+	started   chan delivery
+}
+
+// stubSender replaces account selection and delivery until the test ends.
+// Unpinned jobs are sent from "acc-a".
+func stubSender(t *testing.T) *fakeSender {
+	t.Helper()
+	f := &fakeSender{errs: make(map[string]error), started: make(chan delivery, 100)}
+	selectAccount = func(accountID, phone, strategy string) (string, error) {
+		if accountID != "" {
+			return accountID, nil
+		}
+		return "acc-a", nil
+	}
+	deliver = func(accountID, phone, chat, msgType, text string, media *whatsapp.Media) (string, error) {
+		d := delivery{accountID: accountID, phone: phone, chat: chat, msgType: msgType}
+		f.started <- d
+		f.mu.Lock()
+		hold := f.hold
+		f.mu.Unlock()
+		if hold != nil {
+			<-hold
+		}
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if err := f.errs[phone]; err != nil {
+			return "", err
+		}
+		f.delivered = append(f.delivered, d)
+		return "wa-" + phone, nil
+	}
+	t.Cleanup(func() {
+		selectAccount = whatsapp.Manager.SelectAccount
+		deliver = whatsapp.Manager.DeliverTo
+		clearJobs(t)
+	})
+	return f
+}
+
+// holdSends makes sends block until the returned function is first called
+func (f *fakeSender) holdSends() func() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.hold = make(chan struct{})
+	hold := f.hold
+	var once sync.Once
+	return func() { once.Do(func() { close(hold) }) }
+}
+
+func (f *fakeSender) sent() []delivery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]delivery{}, f.delivered...)
+}
+
+// waitStarted returns the next n sends to start
+func (f *fakeSender) waitStarted(t *testing.T, n int) []delivery {
+	t.Helper()
+	started := make([]delivery, 0, n)
+	for len(started) < n {
+		select {
+		case d := <-f.started:
+			started = append(started, d)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%d of %d sends started", len(started), n)
+		}
+	}
+	return started
+}
+
+// clearJobs deletes every job, so tests do not see each other's queue
+func clearJobs(t *testing.T) {
+	t.Helper()
+	jobs, err := store.DB.GetJobs(nil, 10000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, j := range jobs {
+		if err := store.DB.DeleteJob(j.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func enqueue(t *testing.T, job Job) *store.Job {
+	t.Helper()
+	j, err := Enqueue(job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+// testLane returns a lane with the given number of workers
+func testLane(name string, workers int) *lane {
+	l := newLane(name)
+	l.workers = workers
+	return l
+}
+
+// drain dispatches the lane one round at a time until nothing is due
+func drain(t *testing.T, l *lane) {
+	t.Helper()
+	stop := make(chan struct{})
+	for i := 0; i < 100; i++ {
+		due, err := store.DB.GetDueJobs(l.name, l.workers, batchSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(due) == 0 {
+			return
+		}
+		l.dispatch(stop)
+		wg.Wait()
+	}
+	t.Fatal("lane still has due jobs after 100 rounds")
+}
+
+func TestAccountsSharingALaneBothSend(t *testing.T) {
+	f := stubSender(t)
+	release := f.holdSends()
+	defer release()
+
+	// A backlog larger than a round on one account, then one job on another
+	for i := 0; i < batchSize+10; i++ {
+		enqueue(t, Job{Phone: "62811", Message: "Halo", MsgType: "manual", AccountID: "acc-a"})
+	}
+	enqueue(t, Job{Phone: "62822", Message: "Halo", MsgType: "manual", AccountID: "acc-b"})
+
+	l := testLane(LaneManual, 2)
+	l.dispatch(make(chan struct{}))
+
+	started := f.waitStarted(t, 2)
+	accounts := map[string]bool{started[0].accountID: true, started[1].accountID: true}
+	if !accounts["acc-a"] || !accounts["acc-b"] {
+		t.Fatalf("sends started from %v, want one from each account", started)
+	}
+	release()
+	wg.Wait()
+}
+
+func TestAccountsTakeTurns(t *testing.T) {
+	f := stubSender(t)
+
+	for i := 0; i < 3; i++ {
+		enqueue(t, Job{Phone: "62811", Message: "Halo", MsgType: "manual", AccountID: "acc-a"})
+	}
+	enqueue(t, Job{Phone: "62822", Message: "Halo", MsgType: "manual", AccountID: "acc-b"})
+	enqueue(t, Job{Phone: "62833", Message: "Halo", MsgType: "manual", AccountID: "acc-c"})
+
+	// One worker: each round sends one job, from the account served longest ago
+	drain(t, testLane(LaneManual, 1))
+
+	var order []string
+	for _, d := range f.sent() {
+		order = append(order, d.accountID)
+	}
+	want := []string{"acc-a", "acc-b", "acc-c", "acc-a", "acc-a"}
+	if len(order) != len(want) {
+		t.Fatalf("sent from %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("sent from %v, want %v", order, want)
+		}
+	}
+}
+
+func TestLaneOf(t *testing.T) {
+	tests := map[string]string{
+		"reply":     LaneReply,
+		"away":      LaneReply,
+		"manual":    LaneManual,
+		"system":    LaneSystem,
+		"broadcast": LaneBulk,
+		"promo":     LaneBulk,
+		"":          LaneBulk,
+	}
+	for msgType, want := range tests {
+		if got := LaneOf(msgType); got != want {
+			t.Errorf("LaneOf(%q) = %q, want %q", msgType, got, want)
+		}
+	}
+
+	want := []string{LaneReply, LaneManual, LaneSystem, LaneBulk}
+	for i, name := range want {
+		if Lanes[i] != name {
+			t.Fatalf("Lanes = %v, want priority order %v", Lanes, want)
+		}
+	}
+}
+
+func TestBulkBacklogDoesNotHoldReplies(t *testing.T) {
+	f := stubSender(t)
+	release := f.holdSends()
+	defer release()
+
+	Start()
+	defer Stop()
+	defer release() // Let the held sends finish before Stop waits for them
+
+	// The only bulk worker is stuck on a send
+	enqueue(t, Job{Phone: "62811", Message: "Promo", MsgType: "promo", AccountID: "acc-a"})
+	enqueue(t, Job{Phone: "62812", Message: "Promo", MsgType: "promo", AccountID: "acc-b"})
+	if d := f.waitStarted(t, 1)[0]; d.msgType != "promo" {
+		t.Fatalf("first send is %q, want promo", d.msgType)
+	}
+
+	// Higher lanes have their own workers and still send
+	enqueue(t, Job{Phone: "62813", Message: "Halo", MsgType: "system", AccountID: "acc-a"})
+	enqueue(t, Job{Phone: "62814", Message: "Halo", MsgType: "manual", AccountID: "acc-a"})
+	enqueue(t, Job{Phone: "62815", Message: "Halo", MsgType: "reply", AccountID: "acc-a"})
+	got := map[string]bool{}
+	for _, d := range f.waitStarted(t, 3) {
+		got[d.msgType] = true
+	}
+	if !got["system"] || !got["manual"] || !got["reply"] {
+		t.Fatalf("started %v while bulk was busy, want system, manual and reply", got)
+	}
+}
+
+func TestLaneWorkersLimitSendsInFlight(t *testing.T) {
+	f := stubSender(t)
+	release := f.holdSends()
+	defer release()
+
+	for _, account := range []string{"acc-a", "acc-b", "acc-c"} {
+		enqueue(t, Job{Phone: "62811", Message: "Halo", MsgType: "system", AccountID: account})
+	}
+
+	l := testLane(LaneSystem, 2)
+	stop := make(chan struct{})
+	l.dispatch(stop)
+	f.waitStarted(t, 2)
+
+	// Both workers are busy: another round starts nothing
+	l.dispatch(stop)
+	select {
+	case d := <-f.started:
+		t.Fatalf("send from %s started with every worker busy", d.accountID)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	release()
+	wg.Wait()
+	l.dispatch(stop)
+	f.waitStarted(t, 1)
+	wg.Wait()
+	if n := len(f.sent()); n != 3 {
+		t.Fatalf("%d jobs sent, want 3", n)
+	}
+}
+
+func TestOneAccountUsesEveryWorker(t *testing.T) {
+	f := stubSender(t)
+	release := f.holdSends()
+	defer release()
+
+	for _, phone := range []string{"62811", "62812", "62813", "62814"} {
+		enqueue(t, Job{Phone: phone, Message: "Halo", MsgType: "manual", AccountID: "acc-a"})
+	}
+
+	// Three workers and a single account: three sends at once, the fourth waits
+	l := testLane(LaneManual, 3)
+	stop := make(chan struct{})
+	l.dispatch(stop)
+	f.waitStarted(t, 3)
+	select {
+	case d := <-f.started:
+		t.Fatalf("send to %s started with every worker busy", d.phone)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	release()
+	wg.Wait()
+	drain(t, l)
+	if n := len(f.sent()); n != 4 {
+		t.Fatalf("%d jobs sent, want 4", n)
+	}
+}
+
+func TestStoreReturnsTheOldestJobsOfEachAccount(t *testing.T) {
+	stubSender(t)
+
+	for i := 0; i < 3; i++ {
+		enqueue(t, Job{Phone: "62811", Message: "Halo", MsgType: "manual", AccountID: "acc-a"})
+	}
+	enqueue(t, Job{Phone: "62822", Message: "Halo", MsgType: "manual", AccountID: "acc-b"})
+
+	jobs, err := store.DB.GetDueJobs(LaneManual, 2, batchSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	perAccount := map[string]int{}
+	for _, j := range jobs {
+		perAccount[j.AccountID]++
+	}
+	if perAccount["acc-a"] != 2 || perAccount["acc-b"] != 1 {
+		t.Fatalf("due jobs per account = %v, want 2 of acc-a and 1 of acc-b", perAccount)
+	}
+}
+
+func TestPinnedJobDoesNotReuseUnpinnedSelection(t *testing.T) {
+	f := stubSender(t)
+
+	// Same phone: the unpinned job goes to acc-a, the pinned one must stay on acc-b
+	enqueue(t, Job{Phone: "62811", Message: "Halo", MsgType: "manual", Strategy: whatsapp.SelectLastConversation})
+	pinned := enqueue(t, Job{Phone: "62811", Message: "Halo", MsgType: "manual", AccountID: "acc-b"})
+
+	drain(t, testLane(LaneManual, 2))
+
+	accounts := map[string]bool{}
+	for _, d := range f.sent() {
+		accounts[d.accountID] = true
+	}
+	if !accounts["acc-a"] || !accounts["acc-b"] {
+		t.Fatalf("sent from %v, want acc-a and acc-b", accounts)
+	}
+	j, err := store.DB.GetJob(pinned.ID)
+	if err != nil || j.AccountID != "acc-b" || j.Status != StatusSent {
+		t.Fatalf("pinned job = %+v, %v, want sent from acc-b", j, err)
+	}
+}
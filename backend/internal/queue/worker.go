@@ -1,104 +1,356 @@
 package queue
 
 import (
+	"errors"
 	"log"
 	"sync"
 	"time"
 
+	"esther-whatsapp/internal/config"
 	"esther-whatsapp/internal/rules"
 	"esther-whatsapp/internal/store"
 	"esther-whatsapp/internal/whatsapp"
 )
 
+// Job statuses
+const (
+	StatusQueued   = "queued"
+	StatusSending  = "sending"
+	StatusSent     = "sent"
+	StatusFailed   = "failed"   // Dead: out of attempts or a permanent error
+	StatusRejected = "rejected" // Dead: refused by the anti-ban rules
+)
+
+// DeadStatuses are the terminal failure states that can be retried or discarded
+var DeadStatuses = []string{StatusFailed, StatusRejected}
+
+// Errors returned by Retry and Discard
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobNotDead  = errors.New("job is not dead")
+)
+
+const (
+	pollInterval = 2 * time.Second
+	batchSize    = 50 // Unpinned due jobs looked at per lane round, pinned ones come one per account
+	baseBackoff  = 30 * time.Second
+	maxBackoff   = time.Hour
+)
+
+// Job is a message waiting to be sent. When AccountID is empty, Strategy
+// (whatsapp.SelectLastConversation or whatsapp.SelectAnyConnected) picks the sender.
+// An attachment is referenced by MediaID (media storage) or MediaFile (MEDIA_DIR).
+// Chat is the JID a reply goes back to, empty for the phone's own chat.
 type Job struct {
 	Phone       string
 	Message     string
 	MsgType     string
+	MediaID     string
+	MediaFile   string
+	AccountID   string
+	Strategy    string
+	Chat        string
 	ScheduledAt time.Time
 }
 
+// Account selection, delivery and the human delay, replaced in tests
+var (
+	selectAccount = whatsapp.Manager.SelectAccount
+	deliver       = whatsapp.Manager.DeliverTo
+	humanDelay    = whatsapp.HumanDelay
+)
+
 var (
-	jobQueue = make(chan Job, 100)
-	wg       sync.WaitGroup
-	running  = false
+	lanes   = make(map[string]*lane)
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	running bool
+	mu      sync.Mutex
 )
 
-// Start starts the queue worker
+// Start starts a dispatcher for each priority lane
 func Start() {
+	mu.Lock()
+	defer mu.Unlock()
 	if running {
 		return
 	}
 	running = true
+	stop = make(chan struct{})
 
-	go func() {
-		for job := range jobQueue {
-			processJob(job)
-		}
-	}()
+	recoverInterrupted()
 
-	log.Println("📬 Queue worker started")
+	for _, name := range Lanes {
+		l := newLane(name)
+		lanes[name] = l
+		wg.Add(1)
+		go l.run(stop)
+		log.Printf("📬 Queue lane %s started with %d worker(s)", name, l.workers)
+	}
 }
 
-// Stop stops the queue worker
+// Stop stops the queue, waiting for the jobs in progress
 func Stop() {
+	mu.Lock()
+	if !running {
+		mu.Unlock()
+		return
+	}
 	running = false
-	close(jobQueue)
+	close(stop)
+	mu.Unlock()
+
 	wg.Wait()
 	log.Println("📭 Queue worker stopped")
 }
 
-// Enqueue adds a job to the queue
-func Enqueue(phone, message, msgType string, scheduledAt time.Time) {
-	job := Job{
-		Phone:       phone,
-		Message:     message,
-		MsgType:     msgType,
-		ScheduledAt: scheduledAt,
+// Enqueue stores a job in the queue and returns it
+func Enqueue(job Job) (*store.Job, error) {
+	if job.ScheduledAt.IsZero() {
+		job.ScheduledAt = time.Now()
+	}
+
+	j := &store.Job{
+		Phone:       job.Phone,
+		Message:     job.Message,
+		MsgType:     job.MsgType,
+		Lane:        LaneOf(job.MsgType),
+		MediaID:     job.MediaID,
+		MediaFile:   job.MediaFile,
+		AccountID:   job.AccountID,
+		Strategy:    job.Strategy,
+		Chat:        job.Chat,
+		Status:      StatusQueued,
+		MaxAttempts: config.AppConfig.QueueMaxAttempts,
+		NextRunAt:   job.ScheduledAt.UTC().Format(time.RFC3339),
 	}
-	jobQueue <- job
-	log.Printf("📥 Job enqueued for %s", phone)
+	if err := store.DB.CreateJob(j); err != nil {
+		return nil, err
+	}
+
+	notify(j.Lane)
+	log.Printf("📥 Job %s enqueued for %s in lane %s", j.ID, j.Phone, j.Lane)
+	return j, nil
 }
 
-// EnqueueNow adds a job to be processed immediately
-func EnqueueNow(phone, message, msgType string) {
-	Enqueue(phone, message, msgType, time.Now())
+func init() {
+	// Replies to incoming messages go out through the reply lane
+	whatsapp.EnqueueReply = enqueueReply
 }
 
-func processJob(job Job) {
-	wg.Add(1)
-	defer wg.Done()
+// enqueueReply queues a reply from the account the user wrote to, into the chat they wrote in
+func enqueueReply(accountID, phone, chat, msgType, text string) error {
+	_, err := Enqueue(Job{Phone: phone, Message: text, MsgType: msgType, AccountID: accountID, Chat: chat})
+	return err
+}
+
+// EnqueueNow adds a job to be processed immediately from the account the user last talked to
+func EnqueueNow(phone, message, msgType string) (*store.Job, error) {
+	return Enqueue(Job{
+		Phone:    phone,
+		Message:  message,
+		MsgType:  msgType,
+		Strategy: whatsapp.SelectLastConversation,
+	})
+}
+
+// List returns jobs in the given statuses, newest first
+func List(statuses []string, limit int) ([]store.Job, error) {
+	return store.DB.GetJobs(statuses, limit)
+}
 
-	// Wait until scheduled time
-	if time.Now().Before(job.ScheduledAt) {
-		waitTime := time.Until(job.ScheduledAt)
-		log.Printf("⏰ Waiting %v for scheduled job to %s", waitTime, job.Phone)
-		time.Sleep(waitTime)
+// Retry puts a dead job back in the queue with fresh attempts
+func Retry(id string) (*store.Job, error) {
+	j, err := deadJob(id)
+	if err != nil {
+		return nil, err
+	}
+
+	j.Status = StatusQueued
+	j.Attempts = 0
+	j.LastError = ""
+	j.NextRunAt = time.Now().UTC().Format(time.RFC3339)
+	if err := store.DB.UpdateJob(j); err != nil {
+		return nil, err
 	}
 
+	notify(j.Lane)
+	log.Printf("🔁 Job %s requeued", j.ID)
+	return j, nil
+}
+
+// Discard deletes a dead job
+func Discard(id string) error {
+	if _, err := deadJob(id); err != nil {
+		return err
+	}
+	return store.DB.DeleteJob(id)
+}
+
+func deadJob(id string) (*store.Job, error) {
+	j, err := store.DB.GetJob(id)
+	if err != nil {
+		return nil, err
+	}
+	if j == nil {
+		return nil, ErrJobNotFound
+	}
+	if j.Status != StatusFailed && j.Status != StatusRejected {
+		return nil, ErrJobNotDead
+	}
+	return j, nil
+}
+
+// notify wakes the dispatcher of a lane
+func notify(name string) {
+	mu.Lock()
+	l := lanes[name]
+	mu.Unlock()
+	if l != nil {
+		l.notify()
+	}
+}
+
+// recoverInterrupted requeues jobs left in sending by a previous run
+func recoverInterrupted() {
+	jobs, err := store.DB.GetJobs([]string{StatusSending}, 1000)
+	if err != nil {
+		log.Printf("❌ Failed to load interrupted jobs: %v", err)
+		return
+	}
+	for i := range jobs {
+		j := &jobs[i]
+		j.Status = StatusQueued
+		if err := store.DB.UpdateJob(j); err != nil {
+			log.Printf("❌ Failed to requeue job %s: %v", j.ID, err)
+			continue
+		}
+		log.Printf("♻️ Job %s for %s was interrupted, requeued", j.ID, j.Phone)
+	}
+}
+
+// claim marks a job as sending and counts the attempt
+func claim(j *store.Job) bool {
+	j.Status = StatusSending
+	j.Attempts++
+	if err := store.DB.UpdateJob(j); err != nil {
+		log.Printf("❌ Failed to update job %s: %v", j.ID, err)
+		return false
+	}
+	return true
+}
+
+// processJob sends a claimed job from the selected account
+func processJob(j *store.Job, accountID string, stop chan struct{}) {
 	// Validate before sending
-	result := rules.IsAntiBanSafe(job.MsgType, job.Phone)
-	if !result.CanSend {
-		log.Printf("🚫 Job rejected for %s: %s", job.Phone, result.Reason)
+	decision := rules.Evaluate(rules.Request{
+		MsgType:   j.MsgType,
+		Phone:     j.Phone,
+		AccountID: accountID,
+		Text:      j.Message,
+	})
+	if decision.Transient() {
+		failJob(j, errors.New(decision.Reason))
+		return
+	}
+	if !decision.CanSend {
+		j.Status = StatusRejected
+		j.LastError = decision.Reason
+		saveJob(j)
+		log.Printf("🚫 Job %s rejected for %s: %s", j.ID, j.Phone, decision.Reason)
+		return
+	}
+
+	attachment, err := loadAttachment(j)
+	if err != nil {
+		failJob(j, err)
 		return
 	}
 
+	// Random human-like delay before sending. Replies answer a user who is
+	// waiting for them and go out right away.
+	if j.Lane != LaneReply {
+		delay := humanDelay(accountID)
+		log.Printf("⏳ Waiting %v before sending to %s", delay, j.Phone)
+		select {
+		case <-stop:
+			// Shutting down: give the attempt back and leave the job for the next run
+			j.Status = StatusQueued
+			j.Attempts--
+			saveJob(j)
+			return
+		case <-time.After(delay):
+		}
+	}
+
 	// Send message
-	err := whatsapp.SendToPhone(job.Phone, job.Message, job.MsgType)
+	waID, err := deliver(accountID, j.Phone, j.Chat, j.MsgType, j.Message, attachment)
 	if err != nil {
-		log.Printf("❌ Failed to send job to %s: %v", job.Phone, err)
+		failJob(j, err)
 		return
 	}
 
+	j.Status = StatusSent
+	j.AccountID = accountID
+	j.WAMessageID = waID
+	j.LastError = ""
+	saveJob(j)
+
 	// Update last_system_sent_at if it's a system message
-	if job.MsgType == "system" {
-		user, _ := store.GetUserByPhone(job.Phone)
+	if j.MsgType == "system" {
+		user, _ := store.DB.GetUserByPhoneAndAccount(j.Phone, accountID)
 		if user != nil {
-			store.UpdateUser(user.ID, map[string]interface{}{
+			store.DB.UpdateUser(user.ID, map[string]interface{}{
 				"last_system_sent_at": "now()",
 			})
-			store.LogMessage(user.ID, "outgoing", "system", job.Message, nil)
 		}
 	}
 
-	log.Printf("✅ Job completed for %s", job.Phone)
+	log.Printf("✅ Job %s completed for %s", j.ID, j.Phone)
+}
+
+// loadAttachment reads the job's file, or returns nil for a text message
+func loadAttachment(j *store.Job) (*whatsapp.Media, error) {
+	switch {
+	case j.MediaID != "":
+		return whatsapp.LoadStoredMedia(j.MediaID, j.Message)
+	case j.MediaFile != "":
+		return whatsapp.LoadMediaFile(j.MediaFile, j.Message)
+	}
+	return nil, nil
+}
+
+// failJob schedules a retry with exponential backoff, or dead-letters the job
+// when the error is permanent or it ran out of attempts
+func failJob(j *store.Job, err error) {
+	j.LastError = err.Error()
+
+	if whatsapp.IsPermanent(err) || j.Attempts >= j.MaxAttempts {
+		j.Status = StatusFailed
+		saveJob(j)
+		log.Printf("💀 Job %s for %s failed after %d attempt(s): %v", j.ID, j.Phone, j.Attempts, err)
+		return
+	}
+
+	wait := backoff(j.Attempts)
+	j.Status = StatusQueued
+	j.NextRunAt = time.Now().Add(wait).UTC().Format(time.RFC3339)
+	saveJob(j)
+	log.Printf("🔁 Job %s for %s failed (attempt %d/%d), retrying in %v: %v",
+		j.ID, j.Phone, j.Attempts, j.MaxAttempts, wait, err)
+}
+
+// backoff returns the wait before the next attempt: 30s, 1m, 2m, ... capped at 1h
+func backoff(attempts int) time.Duration {
+	wait := baseBackoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, maxBackoff)
+}
+
+func saveJob(j *store.Job) {
+	if err := store.DB.UpdateJob(j); err != nil {
+		log.Printf("❌ Failed to update job %s: %v", j.ID, err)
+	}
 }
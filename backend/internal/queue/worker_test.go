@@ -0,0 +1,237 @@
+package queue
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"esther-whatsapp/internal/config"
+	"esther-whatsapp/internal/store"
+	"esther-whatsapp/internal/whatsapp"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{6, 16 * time.Minute},
+		{8, time.Hour},
+		{20, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func reloadJob(t *testing.T, id string) *store.Job {
+	t.Helper()
+	j, err := store.DB.GetJob(id)
+	if err != nil || j == nil {
+		t.Fatalf("GetJob(%s) = %v, %v", id, j, err)
+	}
+	return j
+}
+
+// makeDue moves a job's next run to now, skipping its backoff
+func makeDue(t *testing.T, j *store.Job) {
+	t.Helper()
+	j.NextRunAt = time.Now().UTC().Format(time.RFC3339)
+	if err := store.DB.UpdateJob(j); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFailedSendIsRetriedWithBackoffThenDeadLettered(t *testing.T) {
+	f := stubSender(t)
+	f.errs["62811"] = errors.New("connection reset")
+	l := testLane(LaneManual, 1)
+
+	j := enqueue(t, Job{Phone: "62811", Message: "Halo", MsgType: "manual", AccountID: "acc-a"})
+	for attempt := 1; attempt < config.AppConfig.QueueMaxAttempts; attempt++ {
+		before := time.Now()
+		drain(t, l)
+
+		j = reloadJob(t, j.ID)
+		if j.Status != StatusQueued || j.Attempts != attempt || j.LastError != "connection reset" {
+			t.Fatalf("after attempt %d: %s with %d attempts, %q", attempt, j.Status, j.Attempts, j.LastError)
+		}
+		next, err := time.Parse(time.RFC3339, j.NextRunAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait := next.Sub(before); wait < backoff(attempt)-time.Second || wait > backoff(attempt)+time.Second {
+			t.Fatalf("after attempt %d: next run in %v, want %v", attempt, wait, backoff(attempt))
+		}
+		makeDue(t, j)
+	}
+
+	drain(t, l)
+	j = reloadJob(t, j.ID)
+	if j.Status != StatusFailed || j.Attempts != config.AppConfig.QueueMaxAttempts {
+		t.Fatalf("out of attempts: %s with %d attempts, want failed with %d", j.Status, j.Attempts, config.AppConfig.QueueMaxAttempts)
+	}
+}
+
+func TestPermanentErrorIsDeadLetteredRightAway(t *testing.T) {
+	f := stubSender(t)
+	f.errs["62811"] = whatsapp.ErrAccountNotFound
+
+	j := enqueue(t, Job{Phone: "62811", Message: "Halo", MsgType: "manual", AccountID: "acc-a"})
+	drain(t, testLane(LaneManual, 1))
+
+	j = reloadJob(t, j.ID)
+	if j.Status != StatusFailed || j.Attempts != 1 {
+		t.Fatalf("job is %s after %d attempts, want failed after 1", j.Status, j.Attempts)
+	}
+}
+
+func TestRefusedJobsAreRejectedOrRetried(t *testing.T) {
+	stubSender(t)
+	config.Settings.SetPolicies(map[string][]string{"checked": {"account_health"}})
+	defer config.Settings.SetPolicies(map[string][]string{"checked": {}})
+
+	// blast is prohibited by the default policies
+	rejected := enqueue(t, Job{Phone: "62811", Message: "Halo", MsgType: "blast", AccountID: "acc-a"})
+	// The account is unknown to the manager, which may change: worth retrying
+	retried := enqueue(t, Job{Phone: "62812", Message: "Halo", MsgType: "checked", AccountID: "acc-a"})
+	// A type without a policy never gets one by waiting
+	unknown := enqueue(t, Job{Phone: "62813", Message: "Halo", MsgType: "no-such-type", AccountID: "acc-a"})
+	drain(t, testLane(LaneBulk, 3))
+
+	if j := reloadJob(t, rejected.ID); j.Status != StatusRejected || j.LastError != "Promo/broadcast is prohibited" {
+		t.Fatalf("prohibited job is %s (%q), want rejected", j.Status, j.LastError)
+	}
+	if j := reloadJob(t, retried.ID); j.Status != StatusQueued || j.Attempts != 1 || j.LastError != "Account not found" {
+		t.Fatalf("job refused by account health is %s after %d attempts (%q), want queued for a retry", j.Status, j.Attempts, j.LastError)
+	}
+	if j := reloadJob(t, unknown.ID); j.Status != StatusRejected || j.Attempts != 1 || j.LastError != "Unknown message type" {
+		t.Fatalf("job of an unknown type is %s after %d attempts (%q), want rejected right away", j.Status, j.Attempts, j.LastError)
+	}
+}
+
+func TestAwayMessagesAreDelivered(t *testing.T) {
+	f := stubSender(t)
+
+	// Run the default away checks, bar the account health that needs a real connection
+	defaults, ok := config.DefaultPolicies()["away"]
+	if !ok {
+		t.Fatal("no default away policy")
+	}
+	checks := slices.DeleteFunc(slices.Clone(defaults), func(name string) bool { return name == "account_health" })
+	previous, _ := config.Settings.GetPolicy("away")
+	config.Settings.SetPolicies(map[string][]string{"away": checks})
+	defer config.Settings.SetPolicies(map[string][]string{"away": previous})
+
+	away := enqueue(t, Job{Phone: "62811", Message: "Kami sedang tutup", MsgType: "away", AccountID: "acc-a"})
+	drain(t, testLane(LaneReply, 1))
+
+	if j := reloadJob(t, away.ID); j.Status != StatusSent {
+		t.Fatalf("away job is %s (%q), want sent", j.Status, j.LastError)
+	}
+	if sent := f.sent(); len(sent) != 1 || sent[0].msgType != "away" {
+		t.Fatalf("sent %v, want the away message", sent)
+	}
+}
+
+func TestRepliesSkipTheHumanDelay(t *testing.T) {
+	f := stubSender(t)
+	release := f.holdSends()
+	defer release()
+
+	humanDelay = func(string) time.Duration { return time.Minute }
+	defer func() { humanDelay = noDelay }()
+
+	// A burst of chats on one account is answered at once, not one after another
+	for _, phone := range []string{"62811", "62812", "62813"} {
+		enqueue(t, Job{Phone: phone, Message: "Halo", MsgType: "reply", AccountID: "acc-a"})
+	}
+	l := testLane(LaneReply, 3)
+	l.dispatch(make(chan struct{}))
+	f.waitStarted(t, 3)
+
+	release()
+	wg.Wait()
+}
+
+func TestRetryAndDiscardDeadJobs(t *testing.T) {
+	f := stubSender(t)
+	f.errs["62811"] = whatsapp.ErrAccountNotFound
+	l := testLane(LaneManual, 1)
+
+	dead := enqueue(t, Job{Phone: "62811", Message: "Halo", MsgType: "manual", AccountID: "acc-a"})
+	drain(t, l)
+
+	queued := enqueue(t, Job{Phone: "62812", Message: "Halo", MsgType: "manual", AccountID: "acc-a", ScheduledAt: time.Now().Add(time.Hour)})
+	if _, err := Retry(queued.ID); !errors.Is(err, ErrJobNotDead) {
+		t.Fatalf("Retry(queued) = %v, want ErrJobNotDead", err)
+	}
+	if err := Discard(queued.ID); !errors.Is(err, ErrJobNotDead) {
+		t.Fatalf("Discard(queued) = %v, want ErrJobNotDead", err)
+	}
+	if _, err := Retry("missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Fatalf("Retry(missing) = %v, want ErrJobNotFound", err)
+	}
+
+	// A retried job starts over and is sent once the error is gone
+	delete(f.errs, "62811")
+	j, err := Retry(dead.ID)
+	if err != nil || j.Status != StatusQueued || j.Attempts != 0 || j.LastError != "" {
+		t.Fatalf("Retry() = %+v, %v, want queued with fresh attempts", j, err)
+	}
+	drain(t, l)
+	if j := reloadJob(t, dead.ID); j.Status != StatusSent || j.WAMessageID != "wa-62811" {
+		t.Fatalf("retried job is %s (%q), want sent", j.Status, j.WAMessageID)
+	}
+
+	// Only dead jobs can be discarded
+	f.errs["62813"] = whatsapp.ErrAccountNotFound
+	discarded := enqueue(t, Job{Phone: "62813", Message: "Halo", MsgType: "manual", AccountID: "acc-a"})
+	drain(t, l)
+	if err := Discard(discarded.ID); err != nil {
+		t.Fatalf("Discard() = %v", err)
+	}
+	if j, err := store.DB.GetJob(discarded.ID); err != nil || j != nil {
+		t.Fatalf("discarded job = %+v, %v, want it gone", j, err)
+	}
+}
+
+func TestInterruptedJobsAreRequeued(t *testing.T) {
+	stubSender(t)
+
+	j := enqueue(t, Job{Phone: "62811", Message: "Halo", MsgType: "manual", AccountID: "acc-a"})
+	j.Status = StatusSending
+	j.Attempts = 1
+	if err := store.DB.UpdateJob(j); err != nil {
+		t.Fatal(err)
+	}
+
+	recoverInterrupted()
+	if j := reloadJob(t, j.ID); j.Status != StatusQueued || j.Attempts != 1 {
+		t.Fatalf("interrupted job is %s after %d attempts, want queued keeping its attempt", j.Status, j.Attempts)
+	}
+}
+
+func TestRepliesGoOutThroughTheReplyLane(t *testing.T) {
+	f := stubSender(t)
+
+	// A reply in a group goes back to the group
+	if err := whatsapp.EnqueueReply("acc-b", "62811", "120363025@g.us", "reply", "Halo"); err != nil {
+		t.Fatal(err)
+	}
+	jobs, err := store.DB.GetDueJobs(LaneReply, 1, batchSize)
+	if err != nil || len(jobs) != 1 || jobs[0].AccountID != "acc-b" {
+		t.Fatalf("due replies = %+v, %v, want one pinned to acc-b", jobs, err)
+	}
+
+	drain(t, testLane(LaneReply, 1))
+	if sent := f.sent(); len(sent) != 1 || sent[0] != (delivery{accountID: "acc-b", phone: "62811", chat: "120363025@g.us", msgType: "reply"}) {
+		t.Fatalf("sent %v, want the reply from acc-b into the group", sent)
+	}
+}
@@ -0,0 +1,72 @@
+package rules
+
+import (
+	"fmt"
+	"time"
+
+	"esther-whatsapp/internal/config"
+	"esther-whatsapp/internal/store"
+)
+
+// replyTypes answer the user and never count against the daily limit
+var replyTypes = map[string]bool{"reply": true, "away": true}
+
+// DailyQuota is how many more messages a user may receive today.
+// Limit covers every non-reply type, SystemLimit only system messages.
+type DailyQuota struct {
+	Limit           int    `json:"limit"`
+	Used            int    `json:"used"`
+	Remaining       int    `json:"remaining"`
+	SystemLimit     int    `json:"system_limit"`
+	SystemUsed      int    `json:"system_used"`
+	SystemRemaining int    `json:"system_remaining"`
+	ResetsAt        string `json:"resets_at"`
+}
+
+// GetDailyQuota counts the messages sent to a phone since midnight in the
+// business-hours timezone, with the limits of the sending account when one is given
+func GetDailyQuota(phone, accountID string) (DailyQuota, error) {
+	return dailyQuota(phone, config.SettingsFor(accountID), time.Now())
+}
+
+func dailyQuota(phone string, settings *config.BotSettings, now time.Time) (DailyQuota, error) {
+	now = now.In(settings.GetBusinessHours().Location())
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	counts, err := store.DB.CountOutgoingByType(phone, start.UTC().Format(time.RFC3339))
+	if err != nil {
+		return DailyQuota{}, err
+	}
+
+	_, _, dailyLimit := settings.GetRateLimits()
+	q := DailyQuota{
+		Limit:       dailyLimit,
+		SystemLimit: config.AppConfig.MaxSystemMsgPerDay,
+		SystemUsed:  counts["system"],
+		ResetsAt:    start.AddDate(0, 0, 1).Format(time.RFC3339),
+	}
+	for msgType, n := range counts {
+		if !replyTypes[msgType] {
+			q.Used += n
+		}
+	}
+	q.Remaining = max(q.Limit-q.Used, 0)
+	q.SystemRemaining = max(q.SystemLimit-q.SystemUsed, 0)
+	return q, nil
+}
+
+// checkDailyQuota refuses a message once the user's quota for today is spent
+func checkDailyQuota(req *Request) (bool, string) {
+	q, err := dailyQuota(req.Phone, req.settings, time.Now())
+	if err != nil {
+		return false, "Database error"
+	}
+
+	if q.Remaining == 0 {
+		return false, fmt.Sprintf("Daily limit reached: %d messages per user", q.Limit)
+	}
+	if req.MsgType == "system" && q.SystemRemaining == 0 {
+		return false, fmt.Sprintf("Daily limit reached: %d system messages per user", q.SystemLimit)
+	}
+	return true, fmt.Sprintf("%d of %d messages left today", q.Remaining, q.Limit)
+}
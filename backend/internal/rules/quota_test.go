@@ -0,0 +1,143 @@
+package rules
+
+import (
+	"database/sql"
+	"slices"
+	"testing"
+	"time"
+
+	"esther-whatsapp/internal/config"
+	"esther-whatsapp/internal/store"
+)
+
+// logOutgoing records a message of the given type sent to a phone from an
+// account at the given time
+func logOutgoing(t *testing.T, phone, accountID, msgType, status string, at time.Time) {
+	t.Helper()
+	user, err := store.DB.GetUserByPhoneAndAccount(phone, accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user == nil {
+		if user, err = store.DB.CreateUserWithAccount(phone, nil, accountID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := &store.Message{UserID: user.ID, AccountID: &accountID, Direction: "outgoing", MessageType: msgType, Status: status}
+	if err := store.DB.CreateMessage(m); err != nil {
+		t.Fatal(err)
+	}
+
+	// The store stamps messages with the current time, move it back
+	db, err := sql.Open("sqlite3", config.AppConfig.SQLitePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	if _, err := db.Exec("UPDATE messages SET created_at = ? WHERE id = ?", at.UTC().Format(time.RFC3339), m.ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// settingsIn returns settings with a daily limit whose days start in the given timezone
+func settingsIn(t *testing.T, timezone string, dailyLimit int) *config.BotSettings {
+	t.Helper()
+	s := &config.BotSettings{DailyLimitPerUser: dailyLimit}
+	if err := s.SetBusinessHours(config.BusinessHours{Timezone: timezone}); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestDailyQuotaStartsAtLocalMidnight(t *testing.T) {
+	tests := []struct {
+		timezone string
+		now      time.Time // 10:00 local
+		resets   string
+	}{
+		// 11 hours behind UTC: the local day started at 11:00 UTC
+		{"Pacific/Pago_Pago", time.Date(2026, 3, 10, 21, 0, 0, 0, time.UTC), "2026-03-11T00:00:00-11:00"},
+		// 14 hours ahead of UTC: the local day started at 10:00 UTC the day before
+		{"Pacific/Kiritimati", time.Date(2026, 3, 9, 20, 0, 0, 0, time.UTC), "2026-03-11T00:00:00+14:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.timezone, func(t *testing.T) {
+			phone := testPhone()
+			settings := settingsIn(t, tt.timezone, 5)
+			loc := settings.GetBusinessHours().Location()
+			midnight := time.Date(2026, 3, 10, 0, 0, 0, 0, loc)
+
+			logOutgoing(t, phone, "acc-a", "system", "sent", midnight.Add(-time.Minute)) // Yesterday
+			logOutgoing(t, phone, "acc-a", "system", "sent", midnight)
+			logOutgoing(t, phone, "acc-a", "manual", "delivered", midnight.Add(time.Hour))
+			logOutgoing(t, phone, "acc-b", "broadcast", "read", midnight.Add(2*time.Hour)) // Any account counts
+			logOutgoing(t, phone, "acc-a", "manual", "failed", midnight.Add(3*time.Hour))  // Never reached the user
+			logOutgoing(t, phone, "acc-a", "reply", "sent", midnight.Add(4*time.Hour))     // Replies are free
+			logOutgoing(t, phone, "acc-a", "away", "sent", midnight.Add(4*time.Hour))
+
+			q, err := dailyQuota(phone, settings, tt.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := DailyQuota{Limit: 5, Used: 3, Remaining: 2, SystemLimit: 1, SystemUsed: 1, SystemRemaining: 0, ResetsAt: tt.resets}
+			if q != want {
+				t.Fatalf("dailyQuota() = %+v, want %+v", q, want)
+			}
+		})
+	}
+}
+
+func TestDailyQuotaIsCheckedForEveryNonReplyType(t *testing.T) {
+	minDelay, maxDelay, dailyLimit := config.Settings.GetRateLimits()
+	config.Settings.SetRateLimits(minDelay, maxDelay, 2)
+	defer config.Settings.SetRateLimits(minDelay, maxDelay, dailyLimit)
+
+	phone := testPhone()
+	logOutgoing(t, phone, "acc-a", "manual", "sent", time.Now())
+	logOutgoing(t, phone, "acc-a", "system", "sent", time.Now())
+
+	tests := []struct {
+		msgType string
+		checked bool
+	}{
+		{"reply", false},
+		{"manual", true},
+		{"system", true},
+		{"broadcast", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.msgType, func(t *testing.T) {
+			checks, _ := config.Settings.GetPolicy(tt.msgType)
+			if slices.Contains(checks, "daily_quota") != tt.checked {
+				t.Fatalf("%s policy %v, want daily_quota checked: %v", tt.msgType, checks, tt.checked)
+			}
+
+			d := Evaluate(Request{MsgType: tt.msgType, Phone: phone, AccountID: "acc-a"})
+			for _, step := range d.Trace {
+				if step.Check == "daily_quota" && (step.Passed || step.Reason != "Daily limit reached: 2 messages per user") {
+					t.Fatalf("daily_quota step = %+v, want the spent quota to refuse", step)
+				}
+			}
+		})
+	}
+}
+
+func TestGetDailyQuotaUsesTheAccountLimit(t *testing.T) {
+	limit := 10
+	if err := config.SetAccountOverride("acc-q", config.SettingsOverride{DailyLimitPerUser: &limit}); err != nil {
+		t.Fatal(err)
+	}
+	defer config.DeleteAccountOverride("acc-q")
+
+	phone := testPhone()
+	logOutgoing(t, phone, "acc-q", "manual", "sent", time.Now())
+
+	q, err := GetDailyQuota(phone, "acc-q")
+	if err != nil || q.Limit != 10 || q.Remaining != 9 {
+		t.Fatalf("GetDailyQuota(acc-q) = %+v, %v, want 9 of 10 left", q, err)
+	}
+	q, err = GetDailyQuota(phone, "")
+	if err != nil || q.Limit != 5 || q.Remaining != 4 {
+		t.Fatalf("GetDailyQuota() = %+v, %v, want 4 of the global 5 left", q, err)
+	}
+}
@@ -1,97 +1,274 @@
 package rules
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
 
 	"esther-whatsapp/internal/config"
 	"esther-whatsapp/internal/store"
+	"esther-whatsapp/internal/whatsapp"
 )
 
+// ErrInvalidPolicy is returned when a policy names an unknown check
+var ErrInvalidPolicy = errors.New("invalid policy")
+
+// maxTextLength is the longest text WhatsApp delivers in one message
+const maxTextLength = 4096
+
 type ValidationResult struct {
 	CanSend bool
 	Reason  string
 }
 
-// CanSendSystemMessage checks if a system message can be sent to a user
-func CanSendSystemMessage(phone string) ValidationResult {
-	// Get user
-	user, err := store.GetUserByPhone(phone)
-	if err != nil {
-		return ValidationResult{CanSend: false, Reason: "Database error"}
+// Request is a message about to be sent. AccountID and Text are optional:
+// without them the account health and content checks pass.
+type Request struct {
+	MsgType   string
+	Phone     string
+	AccountID string
+	Text      string
+
+	settings    *config.BotSettings
+	users       []store.User
+	usersErr    error
+	usersLoaded bool
+}
+
+// loadUsers returns the users the block and opt-out checks decide on. Users
+// are per account, so with a sending account only its own row counts and
+// another account's stop or block never applies; none means the user is
+// unknown to that account. Without an account every row of the phone counts.
+func (r *Request) loadUsers() ([]store.User, error) {
+	if !r.usersLoaded {
+		r.users, r.usersErr = r.findUsers()
+		r.usersLoaded = true
 	}
+	return r.users, r.usersErr
+}
 
-	if user == nil {
-		return ValidationResult{CanSend: false, Reason: "User not found"}
+func (r *Request) findUsers() ([]store.User, error) {
+	if r.AccountID == "" {
+		return store.DB.GetUsersByPhone(r.Phone)
 	}
+	user, err := store.DB.GetUserByPhoneAndAccount(r.Phone, r.AccountID)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	return []store.User{*user}, nil
+}
 
-	// Check if user is blocked
-	if user.Blocked {
-		return ValidationResult{CanSend: false, Reason: "User is blocked"}
+// Step is the outcome of one check in a decision
+type Step struct {
+	Check  string `json:"check"`
+	Passed bool   `json:"passed"`
+	Reason string `json:"reason"`
+}
+
+// Decision is the result of running a message type's policy.
+// Reason is the first failed check's reason, Trace lists every check.
+type Decision struct {
+	CanSend bool   `json:"can_send"`
+	Reason  string `json:"reason"`
+	Trace   []Step `json:"trace"`
+}
+
+// Transient reports whether the message was refused only by checks that may
+// pass on their own later, so it is worth retrying rather than dropping. A
+// refusal without a failed check, such as an unknown message type, is final.
+func (d Decision) Transient() bool {
+	if d.CanSend {
+		return false
 	}
+	failed := false
+	for _, step := range d.Trace {
+		if step.Passed {
+			continue
+		}
+		if step.Check != "account_health" {
+			return false
+		}
+		failed = true
+	}
+	return failed
+}
 
-	// Check if user has opted out
-	if !user.OptIn {
-		return ValidationResult{CanSend: false, Reason: "User has opted out"}
+// check decides on one aspect of a message and explains why
+type check func(req *Request) (bool, string)
+
+// checks are the building blocks of the per-type policies in the settings
+var checks = map[string]check{
+	"blocked":        checkBlocked,
+	"opt_out":        checkOptOut,
+	"business_hours": checkBusinessHours,
+	"daily_quota":    checkDailyQuota,
+	"frequency_cap":  checkFrequencyCap,
+	"account_health": checkAccountHealth,
+	"content":        checkContent,
+	"prohibited":     checkProhibited,
+}
+
+func init() {
+	// Stored settings are checked on import as well as through the API
+	config.PolicyValidator = ValidatePolicies
+}
+
+// CheckNames returns the checks a policy can use
+func CheckNames() []string {
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	return names
+}
+
+// ValidatePolicies checks that every policy only names known checks
+func ValidatePolicies(policies map[string][]string) error {
+	for msgType, names := range policies {
+		for _, name := range names {
+			if _, ok := checks[name]; !ok {
+				return fmt.Errorf("%w: %s uses unknown check %q", ErrInvalidPolicy, msgType, name)
+			}
+		}
 	}
+	return nil
+}
+
+// Evaluate runs every check of the message type's policy, using the settings
+// of the sending account when one is given
+func Evaluate(req Request) Decision {
+	req.settings = config.SettingsFor(req.AccountID)
 
-	// Check operating hours (08:00 - 20:00)
-	now := time.Now()
-	hour := now.Hour()
-	startHour := config.AppConfig.OperatingHourStart
-	endHour := config.AppConfig.OperatingHourEnd
+	names, ok := req.settings.GetPolicy(req.MsgType)
+	if !ok {
+		return Decision{CanSend: false, Reason: "Unknown message type", Trace: []Step{}}
+	}
 
-	if hour < startHour || hour >= endHour {
-		return ValidationResult{
-			CanSend: false,
-			Reason:  "Outside operating hours",
+	d := Decision{CanSend: true, Reason: "OK", Trace: make([]Step, 0, len(names))}
+	for _, name := range names {
+		passed, reason := false, fmt.Sprintf("Unknown check %q", name)
+		if check, ok := checks[name]; ok {
+			passed, reason = check(&req)
+		}
+		d.Trace = append(d.Trace, Step{Check: name, Passed: passed, Reason: reason})
+		if !passed && d.CanSend {
+			d.CanSend = false
+			d.Reason = reason
 		}
 	}
+	return d
+}
 
-	// Check last system message sent (max 1 per 24 hours)
-	if user.LastSystemSentAt != nil {
-		lastSent, err := time.Parse(time.RFC3339, *user.LastSystemSentAt)
-		if err == nil {
-			if time.Since(lastSent) < 24*time.Hour {
-				return ValidationResult{
-					CanSend: false,
-					Reason:  "Rate limit: already sent within 24 hours",
-				}
-			}
+// IsAntiBanSafe checks if sending is safe from anti-ban perspective
+func IsAntiBanSafe(msgType string, phone string) ValidationResult {
+	d := Evaluate(Request{MsgType: msgType, Phone: phone})
+	return ValidationResult{CanSend: d.CanSend, Reason: d.Reason}
+}
+
+// checkBlocked refuses users blocked on the sending account. An unknown user
+// cannot have been blocked, so it passes.
+func checkBlocked(req *Request) (bool, string) {
+	users, err := req.loadUsers()
+	if err != nil {
+		return false, "Database error"
+	}
+	for _, user := range users {
+		if user.Blocked {
+			return false, "User is blocked"
 		}
 	}
+	if len(users) == 0 {
+		return true, "Unknown user is not blocked"
+	}
+	return true, "User is not blocked"
+}
 
-	return ValidationResult{CanSend: true, Reason: "OK"}
+// checkOptOut refuses users who typed stop on the sending account. An unknown
+// user never opted in there, so it is refused too.
+func checkOptOut(req *Request) (bool, string) {
+	users, err := req.loadUsers()
+	if err != nil {
+		return false, "Database error"
+	}
+	if len(users) == 0 {
+		return false, "User not found"
+	}
+	for _, user := range users {
+		if !user.OptIn {
+			return false, "User has opted out"
+		}
+	}
+	return true, "User has opted in"
 }
 
-// CanReply checks if bot can reply to a user message (always allowed)
-func CanReply(phone string) ValidationResult {
-	// Get user
-	user, err := store.GetUserByPhone(phone)
+func checkBusinessHours(req *Request) (bool, string) {
+	if !req.settings.IsWithinOperatingHours() {
+		return false, "Outside operating hours"
+	}
+	return true, "Within operating hours"
+}
+
+// checkFrequencyCap keeps a gap between non-reply messages to the same user
+func checkFrequencyCap(req *Request) (bool, string) {
+	capMinutes, _ := req.settings.GetContentRules()
+	if capMinutes == 0 {
+		return true, "No frequency cap"
+	}
+
+	since := time.Now().Add(-time.Duration(capMinutes) * time.Minute)
+	counts, err := store.DB.CountOutgoingByType(req.Phone, since.UTC().Format(time.RFC3339))
 	if err != nil {
-		return ValidationResult{CanSend: false, Reason: "Database error"}
+		return false, "Database error"
+	}
+	for msgType, n := range counts {
+		if !replyTypes[msgType] && n > 0 {
+			return false, fmt.Sprintf("Frequency cap: already messaged within %d minutes", capMinutes)
+		}
 	}
+	return true, fmt.Sprintf("No message in the last %d minutes", capMinutes)
+}
 
-	if user != nil && user.Blocked {
-		return ValidationResult{CanSend: false, Reason: "User is blocked"}
+// checkAccountHealth refuses sending from an account that is offline or out of daily sends
+func checkAccountHealth(req *Request) (bool, string) {
+	if req.AccountID == "" {
+		if whatsapp.Manager.AnyConnected() {
+			return true, "A connected account is available"
+		}
+		return false, "No connected account available"
 	}
 
-	// Replies are always allowed (user initiated conversation)
-	return ValidationResult{CanSend: true, Reason: "OK"}
+	connected, exists := whatsapp.Manager.IsAccountConnected(req.AccountID)
+	if !exists {
+		return false, "Account not found"
+	}
+	if !connected {
+		return false, "Account is not connected"
+	}
+	for window, status := range whatsapp.Limiter.Status(req.AccountID) {
+		if window == "per_day" && status.Limit > 0 && status.Remaining == 0 {
+			return false, "Account reached its daily send limit"
+		}
+	}
+	return true, "Account is connected"
 }
 
-// IsAntiBanSafe checks if sending is safe from anti-ban perspective
-func IsAntiBanSafe(msgType string, phone string) ValidationResult {
-	switch msgType {
-	case "reply":
-		return CanReply(phone)
-	case "system":
-		return CanSendSystemMessage(phone)
-	case "manual":
-		// Manual messages are allowed (dashboard operator sends)
-		return CanReply(phone)
-	case "promo", "broadcast", "blast":
-		return ValidationResult{CanSend: false, Reason: "Promo/broadcast is prohibited"}
-	default:
-		return ValidationResult{CanSend: false, Reason: "Unknown message type"}
+// checkContent refuses over-long texts and texts with blocked words
+func checkContent(req *Request) (bool, string) {
+	if len([]rune(req.Text)) > maxTextLength {
+		return false, fmt.Sprintf("Message is longer than %d characters", maxTextLength)
+	}
+
+	_, blockedWords := req.settings.GetContentRules()
+	text := strings.ToLower(req.Text)
+	for _, word := range blockedWords {
+		if word != "" && strings.Contains(text, strings.ToLower(word)) {
+			return false, fmt.Sprintf("Message contains blocked word %q", word)
+		}
 	}
+	return true, "Content allowed"
+}
+
+func checkProhibited(req *Request) (bool, string) {
+	return false, "Promo/broadcast is prohibited"
 }
@@ -0,0 +1,243 @@
+package rules
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"esther-whatsapp/internal/config"
+	"esther-whatsapp/internal/store"
+)
+
+// TestMain runs the checks against a throwaway SQLite store
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "rules-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.AppConfig = &config.Config{StoreDriver: "sqlite", SQLitePath: filepath.Join(dir, "test.db"), MaxSystemMsgPerDay: 1}
+	store.DB, err = store.NewSQLite(config.AppConfig.SQLitePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+var phoneSeq atomic.Int64
+
+func init() {
+	phoneSeq.Store(time.Now().UnixNano() % 1e9)
+}
+
+// testPhone returns a phone no other test or run has used
+func testPhone() string {
+	return fmt.Sprintf("628%d", phoneSeq.Add(1))
+}
+
+// setPolicy gives a message type only the given checks until the test ends
+func setPolicy(t *testing.T, msgType string, checks ...string) {
+	t.Helper()
+	previous, _ := config.Settings.GetPolicy(msgType)
+	config.Settings.SetPolicies(map[string][]string{msgType: checks})
+	t.Cleanup(func() {
+		config.Settings.SetPolicies(map[string][]string{msgType: previous})
+	})
+}
+
+// newUser stores the user of a phone on an account
+func newUser(t *testing.T, phone, accountID string, optIn, blocked bool) {
+	t.Helper()
+	user, err := store.DB.CreateUserWithAccount(phone, nil, accountID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DB.UpdateUser(user.ID, map[string]interface{}{"opt_in": optIn, "blocked": blocked}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBlockAndOptOutArePerAccount(t *testing.T) {
+	setPolicy(t, "campaign", "blocked", "opt_out")
+
+	stopped, blocked, unknown := testPhone(), testPhone(), testPhone()
+	// Stopped on A, still subscribed on B
+	newUser(t, stopped, "acc-a", false, false)
+	newUser(t, stopped, "acc-b", true, false)
+	// Blocked on A, subscribed on B
+	newUser(t, blocked, "acc-a", true, true)
+	newUser(t, blocked, "acc-b", true, false)
+
+	tests := []struct {
+		name      string
+		phone     string
+		accountID string
+		want      bool
+		reason    string
+	}{
+		{"opted out on the sending account", stopped, "acc-a", false, "User has opted out"},
+		{"opted in on the sending account", stopped, "acc-b", true, "OK"},
+		{"unknown to the sending account", stopped, "acc-c", false, "User not found"},
+		{"blocked on the sending account", blocked, "acc-a", false, "User is blocked"},
+		{"another account's block does not apply", blocked, "acc-b", true, "OK"},
+		{"without an account any opt-out counts", stopped, "", false, "User has opted out"},
+		{"without an account any block counts", blocked, "", false, "User is blocked"},
+		{"unknown phone", unknown, "", false, "User not found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Evaluate(Request{MsgType: "campaign", Phone: tt.phone, AccountID: tt.accountID})
+			if d.CanSend != tt.want || d.Reason != tt.reason {
+				t.Fatalf("Evaluate() = %v %q, want %v %q", d.CanSend, d.Reason, tt.want, tt.reason)
+			}
+		})
+	}
+}
+
+func TestUnknownUserIsNotBlocked(t *testing.T) {
+	setPolicy(t, "campaign", "blocked")
+
+	d := Evaluate(Request{MsgType: "campaign", Phone: testPhone(), AccountID: "acc-a"})
+	if !d.CanSend || d.Trace[0].Reason != "Unknown user is not blocked" {
+		t.Fatalf("Evaluate() = %+v, want an unknown user to pass the block check", d)
+	}
+}
+
+func TestCheckNames(t *testing.T) {
+	names := CheckNames()
+	for _, want := range []string{"blocked", "opt_out", "business_hours", "daily_quota", "frequency_cap", "account_health", "content", "prohibited"} {
+		if !slices.Contains(names, want) {
+			t.Errorf("CheckNames() = %v, missing %q", names, want)
+		}
+	}
+}
+
+func TestValidatePolicies(t *testing.T) {
+	if err := ValidatePolicies(config.DefaultPolicies()); err != nil {
+		t.Fatalf("ValidatePolicies(defaults) = %v", err)
+	}
+	err := ValidatePolicies(map[string][]string{"manual": {"blocked", "nope"}})
+	if !errors.Is(err, ErrInvalidPolicy) || !strings.Contains(err.Error(), `"nope"`) {
+		t.Fatalf("ValidatePolicies(unknown check) = %v, want ErrInvalidPolicy naming it", err)
+	}
+}
+
+func TestStoredPoliciesWithUnknownChecksAreRefused(t *testing.T) {
+	before := config.Settings.GetPolicies()
+
+	_, err := config.Settings.Import([]byte(`{"version": 1, "policies": {"manual": ["nope"]}}`))
+	if !errors.Is(err, ErrInvalidPolicy) {
+		t.Fatalf("Import(unknown check) = %v, want ErrInvalidPolicy", err)
+	}
+	if got, _ := config.Settings.GetPolicy("manual"); !slices.Equal(got, before["manual"]) {
+		t.Fatalf("manual policy = %v after a refused import, want %v", got, before["manual"])
+	}
+
+	err = config.ImportOverrides([]byte(`{"acc-a": {"policies": {"manual": ["nope"]}}}`))
+	if !errors.Is(err, ErrInvalidPolicy) {
+		t.Fatalf("ImportOverrides(unknown check) = %v, want ErrInvalidPolicy", err)
+	}
+}
+
+func TestEvaluateTracesEveryCheck(t *testing.T) {
+	setPolicy(t, "campaign", "content", "prohibited", "nope", "content")
+
+	d := Evaluate(Request{MsgType: "campaign", Phone: testPhone(), Text: "Halo"})
+	want := []Step{
+		{Check: "content", Passed: true, Reason: "Content allowed"},
+		{Check: "prohibited", Passed: false, Reason: "Promo/broadcast is prohibited"},
+		{Check: "nope", Passed: false, Reason: `Unknown check "nope"`},
+		{Check: "content", Passed: true, Reason: "Content allowed"},
+	}
+	if d.CanSend || d.Reason != "Promo/broadcast is prohibited" || !slices.Equal(d.Trace, want) {
+		t.Fatalf("Evaluate() = %+v, want refused by the first failed check with trace %+v", d, want)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	setPolicy(t, "campaign", "content")
+	frequencyCap, blockedWords := config.Settings.GetContentRules()
+	config.Settings.SetContentRules(frequencyCap, []string{"judi"})
+	defer config.Settings.SetContentRules(frequencyCap, blockedWords)
+
+	tests := []struct {
+		name    string
+		msgType string
+		text    string
+		want    bool
+		reason  string
+	}{
+		{"every check passes", "campaign", "Halo", true, "OK"},
+		{"blocked word", "campaign", "Main JUDI online", false, `Message contains blocked word "judi"`},
+		{"too long", "campaign", strings.Repeat("a", maxTextLength+1), false, "Message is longer than 4096 characters"},
+		{"unknown message type", "unknown", "Halo", false, "Unknown message type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := Evaluate(Request{MsgType: tt.msgType, Phone: testPhone(), Text: tt.text})
+			if d.CanSend != tt.want || d.Reason != tt.reason {
+				t.Fatalf("Evaluate() = %v %q, want %v %q", d.CanSend, d.Reason, tt.want, tt.reason)
+			}
+		})
+	}
+}
+
+func TestAccountHealth(t *testing.T) {
+	setPolicy(t, "campaign", "account_health")
+
+	tests := []struct {
+		accountID string
+		reason    string
+	}{
+		{"", "No connected account available"},
+		{"missing", "Account not found"},
+	}
+	for _, tt := range tests {
+		d := Evaluate(Request{MsgType: "campaign", Phone: testPhone(), AccountID: tt.accountID})
+		if d.CanSend || d.Reason != tt.reason {
+			t.Errorf("Evaluate(account %q) = %v %q, want refused: %q", tt.accountID, d.CanSend, d.Reason, tt.reason)
+		}
+	}
+}
+
+func TestUnknownMessageTypeIsNotRetried(t *testing.T) {
+	d := Evaluate(Request{MsgType: "no-such-type", Phone: "62811"})
+	if d.CanSend || d.Reason != "Unknown message type" {
+		t.Fatalf("Evaluate(unknown type) = %+v, want refused as unknown", d)
+	}
+	if d.Transient() {
+		t.Fatal("unknown message type is transient, want a final refusal")
+	}
+}
+
+func TestTransient(t *testing.T) {
+	health := Step{Check: "account_health", Passed: false, Reason: "Account is not connected"}
+	optOut := Step{Check: "opt_out", Passed: false, Reason: "User has opted out"}
+	passed := Step{Check: "content", Passed: true, Reason: "Content allowed"}
+
+	tests := []struct {
+		name string
+		d    Decision
+		want bool
+	}{
+		{"allowed", Decision{CanSend: true, Trace: []Step{passed}}, false},
+		{"only account health failed", Decision{Trace: []Step{passed, health}}, true},
+		{"another check failed too", Decision{Trace: []Step{optOut, health}}, false},
+		{"another check failed", Decision{Trace: []Step{optOut, passed}}, false},
+		{"no check failed", Decision{Reason: "Unknown message type", Trace: []Step{}}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.d.Transient(); got != tt.want {
+			t.Errorf("%s: Transient() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
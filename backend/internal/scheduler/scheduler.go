@@ -1,16 +1,50 @@
 package scheduler
 
 import (
+	"errors"
 	"log"
 	"time"
 
+	"esther-whatsapp/internal/broadcast"
 	"esther-whatsapp/internal/queue"
 	"esther-whatsapp/internal/store"
+	"esther-whatsapp/internal/template"
+	"esther-whatsapp/internal/whatsapp"
 )
 
 var stopChan chan struct{}
 
-// Start starts the scheduler that checks for pending scheduled messages
+// accountReady returns why a broadcast cannot be sent from an account yet, or
+// from any account when none is set. Replaced in tests.
+var accountReady = func(accountID string) error {
+	if accountID == "" {
+		if !whatsapp.Manager.AnyConnected() {
+			return whatsapp.ErrNoConnectedAccount
+		}
+		return nil
+	}
+	connected, exists := whatsapp.Manager.IsAccountConnected(accountID)
+	if !exists {
+		return whatsapp.ErrAccountNotFound
+	}
+	if !connected {
+		return whatsapp.ErrAccountNotConnected
+	}
+	return nil
+}
+
+// startRetries holds the scheduled broadcasts that failed to start for a
+// reason that may pass, keyed by broadcast ID. Only the scheduler goroutine
+// touches it.
+var startRetries = make(map[string]startRetry)
+
+type startRetry struct {
+	attempts int
+	next     time.Time
+}
+
+// Start starts the scheduler that checks for pending scheduled messages and
+// for broadcasts due to start or resume
 func Start() {
 	stopChan = make(chan struct{})
 	go run()
@@ -35,15 +69,153 @@ func run() {
 			return
 		case <-ticker.C:
 			processPending()
+			processQueued()
+			processBroadcasts()
 		}
 	}
 }
 
 func processPending() {
-	pending := store.GetPendingScheduled()
+	pending, err := store.DB.GetPendingScheduled()
+	if err != nil {
+		log.Printf("❌ Failed to load scheduled messages: %v", err)
+		return
+	}
 	for _, msg := range pending {
 		log.Printf("⏰ Sending scheduled message to %s", msg.Phone)
-		queue.EnqueueNow(msg.Phone, msg.Message, "system")
-		store.UpdateScheduledStatus(msg.ID, "sent")
+		job, err := queue.Enqueue(queue.Job{
+			Phone:     msg.Phone,
+			Message:   msg.Message,
+			MsgType:   "system",
+			AccountID: msg.AccountID,
+			Strategy:  whatsapp.SelectLastConversation,
+		})
+		if err != nil {
+			log.Printf("❌ Failed to enqueue scheduled message %s: %v", msg.ID, err)
+			continue
+		}
+		if err := store.DB.SetScheduledJob(msg.ID, job.ID); err != nil {
+			log.Printf("❌ Failed to update scheduled message %s: %v", msg.ID, err)
+		}
+	}
+}
+
+// processQueued gives the queued scheduled messages the outcome of their job
+// once it is sent, failed or rejected
+func processQueued() {
+	queued, err := store.DB.GetQueuedScheduled()
+	if err != nil {
+		log.Printf("❌ Failed to load queued scheduled messages: %v", err)
+		return
+	}
+	for _, msg := range queued {
+		job, err := store.DB.GetJob(msg.JobID)
+		if err != nil {
+			log.Printf("❌ Failed to load job %s of scheduled message %s: %v", msg.JobID, msg.ID, err)
+			continue
+		}
+
+		var status string
+		switch {
+		case job == nil:
+			// Discarded from the dead letters
+			status = queue.StatusFailed
+		case job.Status == queue.StatusSent, job.Status == queue.StatusFailed, job.Status == queue.StatusRejected:
+			status = job.Status
+		default:
+			continue
+		}
+		if err := store.DB.UpdateScheduledStatus(msg.ID, status); err != nil {
+			log.Printf("❌ Failed to update scheduled message %s: %v", msg.ID, err)
+		}
+	}
+}
+
+// processBroadcasts starts the scheduled broadcasts that are due and resumes
+// the ones paused outside business hours once the business opens
+func processBroadcasts() {
+	due, err := store.DB.GetDueBroadcasts()
+	if err != nil {
+		log.Printf("❌ Failed to load due broadcasts: %v", err)
+		return
+	}
+	for _, b := range due {
+		if b.Status == "pending" {
+			startScheduled(b)
+			continue
+		}
+
+		log.Printf("⏰ Resuming broadcast %s at business opening", b.ID)
+		err := broadcast.Resume(b.ID)
+		if err == nil || errors.Is(err, broadcast.ErrAlreadyRunning) {
+			continue
+		}
+		// Kept paused for a manual resume
+		log.Printf("❌ Failed to resume broadcast %s: %v", b.ID, err)
+		p := b.Progress()
+		p.ResumeAt = nil
+		if err := store.DB.UpdateBroadcast(b.ID, p); err != nil {
+			log.Printf("❌ Failed to update broadcast %s: %v", b.ID, err)
+		}
+	}
+}
+
+// startScheduled starts a due scheduled broadcast. One that can never start
+// as it is gets cancelled; otherwise it stays pending and is tried again
+// after a backoff.
+func startScheduled(b *store.Broadcast) {
+	retry, retrying := startRetries[b.ID]
+	if retrying && time.Now().Before(retry.next) {
+		return
+	}
+
+	log.Printf("⏰ Starting scheduled broadcast %s", b.ID)
+	err := startBroadcast(b)
+	if err == nil || errors.Is(err, broadcast.ErrAlreadyRunning) || errors.Is(err, broadcast.ErrNotFound) ||
+		errors.Is(err, broadcast.ErrInvalidState) {
+		// Started, or started, deleted or cancelled by someone else meanwhile
+		delete(startRetries, b.ID)
+		return
+	}
+
+	if isPermanent(err) {
+		delete(startRetries, b.ID)
+		log.Printf("❌ Failed to start scheduled broadcast %s, cancelling it: %v", b.ID, err)
+		if err := broadcast.Stop(b.ID); err != nil {
+			log.Printf("❌ Failed to cancel broadcast %s: %v", b.ID, err)
+		}
+		return
+	}
+
+	retry.attempts++
+	retry.next = time.Now().Add(backoff(retry.attempts))
+	startRetries[b.ID] = retry
+	log.Printf("⚠️ Failed to start scheduled broadcast %s, retrying at %s: %v", b.ID, retry.next.Format(time.RFC3339), err)
+}
+
+// startBroadcast starts a broadcast once an account can send it
+func startBroadcast(b *store.Broadcast) error {
+	if err := accountReady(b.AccountID); err != nil {
+		return err
+	}
+	return broadcast.Start(b.ID)
+}
+
+// isPermanent reports whether a scheduled broadcast will never start as it is:
+// its account is gone, its audience matches nobody or its message or media is invalid
+func isPermanent(err error) bool {
+	return errors.Is(err, whatsapp.ErrAccountNotFound) ||
+		errors.Is(err, broadcast.ErrInvalidAudience) ||
+		errors.Is(err, template.ErrUnknownPlaceholder) ||
+		errors.Is(err, whatsapp.ErrInvalidMedia)
+}
+
+// backoff returns the wait before the next start of a scheduled broadcast
+// that failed to start: 30 seconds, doubling up to 30 minutes
+func backoff(attempts int) time.Duration {
+	wait := 30 * time.Second
+	for i := 1; i < attempts && wait < 30*time.Minute; i++ {
+		wait *= 2
 	}
+	return min(wait, 30*time.Minute)
 }
@@ -0,0 +1,195 @@
+package scheduler
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"esther-whatsapp/internal/config"
+	"esther-whatsapp/internal/queue"
+	"esther-whatsapp/internal/store"
+	"esther-whatsapp/internal/whatsapp"
+)
+
+// TestMain runs the scheduler against a throwaway SQLite store
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "scheduler-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.AppConfig = &config.Config{StoreDriver: "sqlite", SQLitePath: filepath.Join(dir, "test.db")}
+	store.DB, err = store.NewSQLite(config.AppConfig.SQLitePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// stubAccounts makes accountReady return the error set for an account and
+// counts its calls, until the test ends
+func stubAccounts(t *testing.T, errs map[string]error) *int {
+	t.Helper()
+	calls := 0
+	previous := accountReady
+	accountReady = func(accountID string) error {
+		calls++
+		return errs[accountID]
+	}
+	t.Cleanup(func() {
+		accountReady = previous
+		startRetries = make(map[string]startRetry)
+	})
+	return &calls
+}
+
+// scheduledBroadcast stores a pending broadcast that was due a minute ago
+func scheduledBroadcast(t *testing.T, accountID string, audience *store.Audience) *store.Broadcast {
+	t.Helper()
+	at := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+	b, err := store.DB.CreateBroadcast(&store.Broadcast{
+		Name:        t.Name(),
+		Message:     "Halo",
+		AccountID:   accountID,
+		Recipients:  []string{"62811"},
+		DelayMs:     1,
+		Audience:    audience,
+		ScheduledAt: &at,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.DB.DeleteBroadcast(b.ID) })
+	return b
+}
+
+func statusOf(t *testing.T, id string) string {
+	t.Helper()
+	b, err := store.DB.GetBroadcast(id)
+	if err != nil || b == nil {
+		t.Fatalf("GetBroadcast(%s) = %v, %v", id, b, err)
+	}
+	return b.Status
+}
+
+func TestScheduledBroadcastWaitsForItsAccount(t *testing.T) {
+	calls := stubAccounts(t, map[string]error{"acc-a": whatsapp.ErrAccountNotConnected})
+	b := scheduledBroadcast(t, "acc-a", nil)
+
+	processBroadcasts()
+	if status := statusOf(t, b.ID); status != "pending" {
+		t.Fatalf("broadcast is %s, want pending until its account connects", status)
+	}
+	retry := startRetries[b.ID]
+	if retry.attempts != 1 || time.Until(retry.next) < 25*time.Second {
+		t.Fatalf("retry = %+v, want the first attempt backed off by 30s", retry)
+	}
+
+	// Not tried again before the backoff is over
+	processBroadcasts()
+	if *calls != 1 {
+		t.Fatalf("start tried %d times, want once within the backoff", *calls)
+	}
+}
+
+func TestScheduledBroadcastThatCannotStartIsCancelled(t *testing.T) {
+	stubAccounts(t, map[string]error{"acc-gone": whatsapp.ErrAccountNotFound})
+	tests := []struct {
+		name      string
+		accountID string
+		audience  *store.Audience
+	}{
+		{"unknown account", "acc-gone", nil},
+		{"audience matching nobody", "acc-a", &store.Audience{AccountID: "acc-a", Tags: []string{"nobody-has-this"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := scheduledBroadcast(t, tt.accountID, tt.audience)
+			processBroadcasts()
+			if status := statusOf(t, b.ID); status != "cancelled" {
+				t.Fatalf("broadcast is %s, want cancelled", status)
+			}
+			if _, retrying := startRetries[b.ID]; retrying {
+				t.Fatal("cancelled broadcast is still retried")
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{6, 16 * time.Minute},
+		{7, 30 * time.Minute},
+		{20, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+// scheduledRow returns the stored scheduled message with the given ID
+func scheduledRow(t *testing.T, id string) store.ScheduledMessage {
+	t.Helper()
+	all, err := store.DB.GetScheduled()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range all {
+		if sm.ID == id {
+			return sm
+		}
+	}
+	t.Fatalf("scheduled message %s not found", id)
+	return store.ScheduledMessage{}
+}
+
+func TestScheduledMessageReportsItsJobOutcome(t *testing.T) {
+	for _, outcome := range []string{queue.StatusSent, queue.StatusFailed, queue.StatusRejected} {
+		t.Run(outcome, func(t *testing.T) {
+			at := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+			sm, err := store.DB.AddScheduled("62812", "Halo", at, "acc-a")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { store.DB.DeleteScheduled(sm.ID) })
+
+			processPending()
+			row := scheduledRow(t, sm.ID)
+			if row.Status != "queued" || row.JobID == "" {
+				t.Fatalf("scheduled message is %s with job %q, want queued with its job", row.Status, row.JobID)
+			}
+
+			// Still queued while the job has not finished
+			processQueued()
+			if status := scheduledRow(t, sm.ID).Status; status != "queued" {
+				t.Fatalf("scheduled message is %s before its job finished, want queued", status)
+			}
+
+			job, err := store.DB.GetJob(row.JobID)
+			if err != nil || job == nil {
+				t.Fatalf("GetJob(%s) = %v, %v", row.JobID, job, err)
+			}
+			job.Status = outcome
+			if err := store.DB.UpdateJob(job); err != nil {
+				t.Fatal(err)
+			}
+
+			processQueued()
+			if status := scheduledRow(t, sm.ID).Status; status != outcome {
+				t.Fatalf("scheduled message is %s, want %s like its job", status, outcome)
+			}
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package store
+
+import "github.com/google/uuid"
+
+// Template represents a message template
+type Template struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Content   string `json:"content"`
+	CreatedAt string `json:"created_at"`
+}
+
+// ScheduledMessage represents a scheduled message
+type ScheduledMessage struct {
+	ID          string `json:"id"`
+	Phone       string `json:"phone"`
+	Message     string `json:"message"`
+	ScheduledAt string `json:"scheduled_at"`
+	AccountID   string `json:"account_id"` // Empty: the account the user last talked to
+	Status      string `json:"status"`     // pending | queued | sent | failed | rejected
+	JobID       string `json:"job_id"`     // Queue job sending it, once queued
+	CreatedAt   string `json:"created_at"`
+}
+
+// Broadcast represents a broadcast campaign
+type Broadcast struct {
+	ID          string                       `json:"id"`
+	Name        string                       `json:"name"`
+	Message     string                       `json:"message"`
+	AccountID   string                       `json:"account_id"`
+	Recipients  []string                     `json:"recipients"` // Phone numbers
+	Sent        int                          `json:"sent"`
+	Failed      int                          `json:"failed"`
+	Skipped     int                          `json:"skipped"`      // Refused by the anti-ban rules
+	SkipReasons map[string]int               `json:"skip_reasons"` // Skipped recipients per reason
+	Total       int                          `json:"total"`
+	Status      string                       `json:"status"` // pending | running | paused | completed | cancelled
+	DelayMs     int                          `json:"delay_ms"`
+	MediaFile   string                       `json:"media_file"`   // Optional attachment, Message becomes its caption
+	Data        map[string]map[string]string `json:"data"`         // Placeholder values per recipient phone
+	Audience    *Audience                    `json:"audience"`     // Resolved into Recipients at start when set
+	ScheduledAt *string                      `json:"scheduled_at"` // Started by the scheduler when set, UTC RFC3339
+	ResumeAt    *string                      `json:"resume_at"`    // Paused outside business hours until then, UTC RFC3339
+	CreatedAt   string                       `json:"created_at"`
+}
+
+// BroadcastRecipient is the delivery log of one broadcast recipient
+type BroadcastRecipient struct {
+	ID          string  `json:"id"`
+	BroadcastID string  `json:"broadcast_id"`
+	Position    int     `json:"position"` // Send order
+	Phone       string  `json:"phone"`
+	Status      string  `json:"status"` // pending | sent | failed | skipped
+	Error       string  `json:"error"`  // Send error or skip reason
+	WAMessageID string  `json:"wa_message_id"`
+	SentAt      *string `json:"sent_at"`
+	Receipt     string  `json:"receipt"` // Delivery status once sent: sent | delivered | read | failed
+	ReceiptAt   *string `json:"receipt_at"`
+}
+
+// RecipientFilter narrows the delivery log of a broadcast. Empty fields match
+// everything; Limit 0 returns every row.
+type RecipientFilter struct {
+	Status  string
+	Receipt string
+	Phone   string
+	Limit   int
+	Offset  int
+}
+
+// BroadcastProgress is the outcome of a broadcast so far, saved after every recipient
+type BroadcastProgress struct {
+	Sent        int
+	Failed      int
+	Skipped     int
+	SkipReasons map[string]int
+	Status      string
+	ResumeAt    *string
+}
+
+// Progress returns the broadcast's saved progress
+func (b *Broadcast) Progress() BroadcastProgress {
+	reasons := make(map[string]int, len(b.SkipReasons))
+	for reason, n := range b.SkipReasons {
+		reasons[reason] = n
+	}
+	return BroadcastProgress{Sent: b.Sent, Failed: b.Failed, Skipped: b.Skipped, SkipReasons: reasons,
+		Status: b.Status, ResumeAt: b.ResumeAt}
+}
+
+// Audience selects broadcast recipients from the users table. Opted-out and
+// blocked users are left out unless explicitly included.
+type Audience struct {
+	AccountID            string   `json:"account_id"` // Empty: users of every account
+	IncludeOptedOut      bool     `json:"include_opted_out"`
+	IncludeBlocked       bool     `json:"include_blocked"`
+	Tags                 []string `json:"tags"`                   // Users with every listed tag
+	InteractedWithinDays int      `json:"interacted_within_days"` // Users who wrote in the last N days, 0 for any
+	CreatedAfter         string   `json:"created_after"`          // YYYY-MM-DD or RFC3339, inclusive
+	CreatedBefore        string   `json:"created_before"`         // YYYY-MM-DD or RFC3339, exclusive
+}
+
+// Keyword is an auto-reply rule. AccountID is empty for global rules.
+type Keyword struct {
+	ID        string `json:"id"`
+	AccountID string `json:"account_id"`
+	Keyword   string `json:"keyword"`
+	Response  string `json:"response"`
+	MatchMode string `json:"match_mode"` // exact | contains | prefix | regex | fuzzy
+	Priority  int    `json:"priority"`   // Higher priority rules are evaluated first
+	CreatedAt string `json:"created_at"`
+}
+
+// Setting is a JSON document of runtime settings stored under a key
+type Setting struct {
+	Key       string `json:"key"`
+	Value     string `json:"value"`
+	UpdatedAt string `json:"updated_at"`
+}
+
+// Flow is a menu-driven dialogue started by a trigger keyword
+type Flow struct {
+	ID             string     `json:"id"`
+	AccountID      string     `json:"account_id"` // Empty for every account
+	Name           string     `json:"name"`
+	Trigger        string     `json:"trigger"`
+	StartNode      string     `json:"start_node"`
+	TimeoutSeconds int        `json:"timeout_seconds"`
+	Nodes          []FlowNode `json:"nodes"`
+	CreatedAt      string     `json:"created_at"`
+	UpdatedAt      string     `json:"updated_at"`
+}
+
+// FlowNode is one step of a flow: a message followed by numbered options
+type FlowNode struct {
+	ID      string       `json:"id"`
+	Message string       `json:"message"`
+	Options []FlowOption `json:"options"`
+}
+
+// FlowOption is an answer to a node. It either moves to the Next node or,
+// when Next is empty, ends the flow with Reply.
+type FlowOption struct {
+	Key   string `json:"key"` // What the user types, e.g. "1"
+	Label string `json:"label"`
+	Next  string `json:"next"`
+	Reply string `json:"reply"`
+}
+
+// MediaFile describes a stored media file; the content itself lives in media storage
+type MediaFile struct {
+	ID         string `json:"id"`
+	AccountID  string `json:"account_id"`
+	MediaType  string `json:"media_type"` // image | video | audio | document | sticker
+	MimeType   string `json:"mime_type"`
+	FileName   string `json:"file_name"`
+	Size       int64  `json:"size"`
+	Caption    string `json:"caption"`
+	StorageKey string `json:"storage_key"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// Job is a message in the durable send queue
+type Job struct {
+	ID          string `json:"id"`
+	Phone       string `json:"phone"`
+	Message     string `json:"message"`
+	MsgType     string `json:"msg_type"`
+	Lane        string `json:"lane"`       // reply | manual | system | bulk
+	MediaID     string `json:"media_id"`   // Attachment in media storage
+	MediaFile   string `json:"media_file"` // Attachment in MEDIA_DIR
+	AccountID   string `json:"account_id"` // Sending account, chosen by Strategy when empty
+	Strategy    string `json:"strategy"`
+	Chat        string `json:"chat"`   // JID of the chat to send to, empty for the phone's own chat
+	Status      string `json:"status"` // queued | sending | sent | failed | rejected
+	Attempts    int    `json:"attempts"`
+	MaxAttempts int    `json:"max_attempts"`
+	NextRunAt   string `json:"next_run_at"`
+	LastError   string `json:"last_error"`
+	WAMessageID string `json:"wa_message_id"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// statusPredecessors lists, for each delivery status, the statuses it may replace.
+// Receipts can arrive out of order, so a message never moves back from read to delivered.
+var statusPredecessors = map[string][]string{
+	"delivered": {"sent"},
+	"read":      {"sent", "delivered"},
+	"failed":    {"sent"},
+}
+
+// prepareBroadcast resets a new broadcast to a pending state ready to be inserted
+func prepareBroadcast(b *Broadcast) {
+	b.Recipients = uniquePhones(b.Recipients)
+	if b.Data == nil {
+		b.Data = map[string]map[string]string{}
+	}
+	b.ID = uuid.New().String()[:8]
+	b.Sent = 0
+	b.Failed = 0
+	b.Skipped = 0
+	b.SkipReasons = map[string]int{}
+	b.ResumeAt = nil
+	b.Total = len(b.Recipients)
+	b.Status = "pending"
+	b.CreatedAt = nowString()
+}
+
+// uniquePhones drops repeated phone numbers, keeping the first occurrence
+func uniquePhones(phones []string) []string {
+	unique := make([]string, 0, len(phones))
+	seen := make(map[string]bool, len(phones))
+	for _, phone := range phones {
+		if !seen[phone] {
+			seen[phone] = true
+			unique = append(unique, phone)
+		}
+	}
+	return unique
+}
+
+// prepareMediaFile fills in the ID and creation time of a new media file
+func prepareMediaFile(m *MediaFile) {
+	m.ID = uuid.New().String()
+	m.CreatedAt = nowString()
+}
+
+// prepareJob fills in the ID and timestamps of a new job
+func prepareJob(j *Job) {
+	now := nowString()
+	j.ID = uuid.New().String()
+	if j.NextRunAt == "" {
+		j.NextRunAt = now
+	}
+	j.CreatedAt = now
+	j.UpdatedAt = now
+}
+
+// prepareFlow fills in the ID and timestamps of a flow about to be saved
+func prepareFlow(f *Flow) {
+	now := nowString()
+	if f.ID == "" {
+		f.ID = uuid.New().String()[:8]
+	}
+	if f.CreatedAt == "" {
+		f.CreatedAt = now
+	}
+	f.UpdatedAt = now
+	if f.Nodes == nil {
+		f.Nodes = []FlowNode{}
+	}
+}
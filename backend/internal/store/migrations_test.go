@@ -0,0 +1,119 @@
+package store
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func schemaVersion(t *testing.T, db *sql.DB) int {
+	t.Helper()
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	return version
+}
+
+func TestMigrationsRunOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	s, err := NewSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := s.(*sqliteStore).db
+	if v := schemaVersion(t, db); v != len(sqliteMigrations) {
+		t.Fatalf("user_version = %d, want %d", v, len(sqliteMigrations))
+	}
+	user, err := s.CreateUser("62811", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	// Reopening applies nothing again and keeps the data
+	s, err = NewSQLite(path)
+	if err != nil {
+		t.Fatalf("reopening: %v", err)
+	}
+	defer s.(*sqliteStore).db.Close()
+	if got, err := s.GetUserByID(user.ID); err != nil || got == nil || got.Phone != "62811" {
+		t.Fatalf("GetUserByID() after reopening = %+v, %v", got, err)
+	}
+}
+
+func TestFailedMigrationIsRolledBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	migrations := sqliteMigrations
+	defer func() { sqliteMigrations = migrations }()
+	sqliteMigrations = append(migrations[:1:1], `CREATE TABLE half (id TEXT); NOT SQL`)
+
+	if err := migrateSQLite(db); err == nil {
+		t.Fatal("migrateSQLite() = nil, want the broken migration to fail")
+	}
+	if v := schemaVersion(t, db); v != 1 {
+		t.Fatalf("user_version = %d, want 1", v)
+	}
+	var n int
+	if err := db.QueryRow("SELECT count(*) FROM sqlite_master WHERE name = 'half'").Scan(&n); err != nil || n != 0 {
+		t.Fatalf("table of the failed migration exists: %d, %v", n, err)
+	}
+}
+
+func TestUpgradeKeepsTemplatesScheduledMessagesAndBroadcasts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A database from before keywords, media, jobs and audiences
+	for _, m := range sqliteMigrations[:2] {
+		if _, err := db.Exec(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, q := range []string{
+		"PRAGMA user_version = 2",
+		`INSERT INTO templates (id, name, content, created_at) VALUES ('t1', 'Greeting', 'Halo', '2026-01-01T00:00:00Z')`,
+		`INSERT INTO scheduled_messages (id, phone, message, scheduled_at, status, created_at)
+			VALUES ('s1', '62811', 'Halo', '2099-01-01T00:00:00Z', 'pending', '2026-01-01T00:00:00Z')`,
+		`INSERT INTO broadcasts (id, name, message, account_id, recipients, sent, failed, total, status, delay_ms, created_at)
+			VALUES ('b1', 'Promo', 'Halo', 'acc-a', '["62811","62812"]', 1, 0, 2, 'paused', 5000, '2026-01-01T00:00:00Z')`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	db.Close()
+
+	s, err := NewSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.(*sqliteStore).db.Close()
+
+	templates, err := s.GetTemplates()
+	if err != nil || len(templates) != 1 || templates[0].Content != "Halo" {
+		t.Fatalf("GetTemplates() = %+v, %v", templates, err)
+	}
+	scheduled, err := s.GetScheduled()
+	if err != nil || len(scheduled) != 1 || scheduled[0].Status != "pending" || scheduled[0].AccountID != "" {
+		t.Fatalf("GetScheduled() = %+v, %v", scheduled, err)
+	}
+	b, err := s.GetBroadcast("b1")
+	if err != nil || b == nil {
+		t.Fatalf("GetBroadcast() = %+v, %v", b, err)
+	}
+	if b.Status != "paused" || b.Sent != 1 || b.Total != 2 || len(b.Recipients) != 2 {
+		t.Fatalf("broadcast = %+v, want it paused after 1 of 2", b)
+	}
+	if b.MediaFile != "" || b.Skipped != 0 || b.Audience != nil || b.ScheduledAt != nil {
+		t.Fatalf("broadcast = %+v, want the later columns empty", b)
+	}
+}
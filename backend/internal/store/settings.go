@@ -0,0 +1,59 @@
+package store
+
+import (
+	"log"
+
+	"esther-whatsapp/internal/config"
+)
+
+// Settings keys of config.Settings and of the per-account overrides
+const (
+	botSettingsKey     = "bot"
+	accountSettingsKey = "accounts"
+)
+
+// LoadBotSettings applies the stored bot settings over the defaults,
+// then restores the per-account overrides. Settings stored by an older
+// version are migrated and saved back once.
+func LoadBotSettings() error {
+	st, err := DB.GetSetting(botSettingsKey)
+	if err != nil {
+		return err
+	}
+	if st != nil {
+		migrated, err := config.Settings.Import([]byte(st.Value))
+		if err != nil {
+			return err
+		}
+		if migrated {
+			log.Println("⚙️ Stored bot settings migrated to the current version")
+			if err := SaveBotSettings(); err != nil {
+				return err
+			}
+		}
+	}
+
+	st, err = DB.GetSetting(accountSettingsKey)
+	if err != nil || st == nil {
+		return err
+	}
+	return config.ImportOverrides([]byte(st.Value))
+}
+
+// SaveBotSettings stores the current bot settings
+func SaveBotSettings() error {
+	data, err := config.Settings.Export()
+	if err != nil {
+		return err
+	}
+	return DB.SaveSetting(botSettingsKey, string(data))
+}
+
+// SaveAccountSettings stores the per-account overrides
+func SaveAccountSettings() error {
+	data, err := config.ExportOverrides()
+	if err != nil {
+		return err
+	}
+	return DB.SaveSetting(accountSettingsKey, string(data))
+}
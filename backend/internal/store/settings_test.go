@@ -0,0 +1,126 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"esther-whatsapp/internal/config"
+)
+
+// useTestDB points DB at a fresh SQLite store and keeps the global settings
+// and overrides as they were, until the test ends
+func useTestDB(t *testing.T) {
+	t.Helper()
+	previousDB := DB
+	DB = newTestSQLite(t)
+	settings, err := config.Settings.Export()
+	if err != nil {
+		t.Fatal(err)
+	}
+	overrides, err := config.ExportOverrides()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		DB = previousDB
+		config.Settings.Import(settings)
+		config.ImportOverrides(overrides)
+	})
+}
+
+func TestBotSettingsSurviveARestart(t *testing.T) {
+	useTestDB(t)
+
+	config.Settings.SetAwayMessage("Kami tutup")
+	config.Settings.SetAutoReplyEnabled(false)
+	away := false
+	if err := config.SetAccountOverride("acc-a", config.SettingsOverride{AwayEnabled: &away}); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveBotSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveAccountSettings(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A restart starts over from the defaults
+	config.Settings.SetAwayMessage("default")
+	config.Settings.SetAutoReplyEnabled(true)
+	config.DeleteAccountOverride("acc-a")
+
+	if err := LoadBotSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if config.Settings.GetAwayMessage() != "Kami tutup" || config.Settings.IsAutoReplyEnabled() {
+		t.Fatalf("loaded away message %q, auto-reply %v, want the saved ones", config.Settings.GetAwayMessage(), config.Settings.IsAutoReplyEnabled())
+	}
+	if config.SettingsFor("acc-a").IsAwayEnabled() {
+		t.Fatal("account override was not restored")
+	}
+}
+
+func TestLoadBotSettingsWithNothingStored(t *testing.T) {
+	useTestDB(t)
+	config.Settings.SetAwayMessage("default")
+
+	if err := LoadBotSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if config.Settings.GetAwayMessage() != "default" {
+		t.Fatalf("away message = %q, want the defaults kept", config.Settings.GetAwayMessage())
+	}
+	if st, err := DB.GetSetting(botSettingsKey); err != nil || st != nil {
+		t.Fatalf("GetSetting() = %+v, %v, want nothing written", st, err)
+	}
+}
+
+func TestInvalidStoredSettingsAreAnError(t *testing.T) {
+	useTestDB(t)
+	if err := DB.SaveSetting(botSettingsKey, `{"policies": {"manual": ["no_such_check"]}}`); err != nil {
+		t.Fatal(err)
+	}
+	config.PolicyValidator = func(policies map[string][]string) error {
+		for _, checks := range policies {
+			for _, check := range checks {
+				if check == "no_such_check" {
+					return errors.New("unknown check no_such_check")
+				}
+			}
+		}
+		return nil
+	}
+	defer func() { config.PolicyValidator = nil }()
+
+	if err := LoadBotSettings(); err == nil {
+		t.Fatal("LoadBotSettings() with an unknown check succeeded")
+	}
+}
+
+func TestLegacySettingsAreMigratedOnce(t *testing.T) {
+	useTestDB(t)
+	if err := DB.SaveSetting(botSettingsKey, `{"policies": {"broadcast": ["prohibited"]}}`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadBotSettings(); err != nil {
+		t.Fatal(err)
+	}
+	want := config.DefaultPolicies()["broadcast"]
+	if checks, _ := config.Settings.GetPolicy("broadcast"); !slices.Equal(checks, want) {
+		t.Fatalf("broadcast policy = %v, want %v", checks, want)
+	}
+
+	// Saved back with the current version: a prohibited chain is now the user's choice
+	config.Settings.SetPolicies(map[string][]string{"broadcast": {"prohibited"}})
+	if err := SaveBotSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadBotSettings(); err != nil {
+		t.Fatal(err)
+	}
+	if checks, _ := config.Settings.GetPolicy("broadcast"); !slices.Equal(checks, []string{"prohibited"}) {
+		t.Fatalf("broadcast policy after a reload = %v, want the saved one kept", checks)
+	}
+}
@@ -0,0 +1,1210 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// sqliteMigrations are applied in order; PRAGMA user_version tracks how many ran.
+// Never edit an existing entry, append a new one instead.
+var sqliteMigrations = []string{
+	`CREATE TABLE users (
+		id TEXT PRIMARY KEY,
+		phone TEXT NOT NULL,
+		name TEXT,
+		notes TEXT,
+		account_id TEXT,
+		opt_in INTEGER NOT NULL DEFAULT 1,
+		blocked INTEGER NOT NULL DEFAULT 0,
+		last_user_message_at TEXT,
+		last_system_sent_at TEXT,
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	);
+	CREATE UNIQUE INDEX idx_users_phone_account ON users(phone, account_id);
+
+	CREATE TABLE messages (
+		id TEXT PRIMARY KEY,
+		user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+		account_id TEXT,
+		direction TEXT NOT NULL,
+		message_type TEXT NOT NULL,
+		content TEXT,
+		status TEXT NOT NULL DEFAULT 'sent',
+		wa_message_id TEXT,
+		created_at TEXT NOT NULL
+	);
+	CREATE INDEX idx_messages_user_id ON messages(user_id);
+	CREATE INDEX idx_messages_created_at ON messages(created_at DESC);`,
+
+	`CREATE TABLE templates (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		content TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);
+
+	CREATE TABLE scheduled_messages (
+		id TEXT PRIMARY KEY,
+		phone TEXT NOT NULL,
+		message TEXT NOT NULL,
+		scheduled_at TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		created_at TEXT NOT NULL
+	);
+	CREATE INDEX idx_scheduled_status ON scheduled_messages(status);
+
+	CREATE TABLE broadcasts (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		message TEXT NOT NULL,
+		account_id TEXT NOT NULL,
+		recipients TEXT NOT NULL DEFAULT '[]',
+		sent INTEGER NOT NULL DEFAULT 0,
+		failed INTEGER NOT NULL DEFAULT 0,
+		total INTEGER NOT NULL DEFAULT 0,
+		status TEXT NOT NULL DEFAULT 'pending',
+		delay_ms INTEGER NOT NULL DEFAULT 0,
+		created_at TEXT NOT NULL
+	);`,
+
+	`CREATE TABLE keywords (
+		id TEXT PRIMARY KEY,
+		account_id TEXT NOT NULL DEFAULT '',
+		keyword TEXT NOT NULL,
+		response TEXT NOT NULL,
+		created_at TEXT NOT NULL,
+		UNIQUE (account_id, keyword)
+	);
+	INSERT INTO keywords (id, account_id, keyword, response, created_at) VALUES
+		(lower(hex(randomblob(16))), '', 'info', 'Halo 👋' || char(10) || 'Selamat datang! Ini adalah bot informasi Esther.' || char(10) || char(10) || 'Ketik:' || char(10) || '• *jadwal* - Lihat jadwal' || char(10) || '• *bantuan* - Hubungi CS', strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+		(lower(hex(randomblob(16))), '', 'jadwal', '📅 Jadwal operasional:' || char(10) || 'Senin - Sabtu: 08.00 - 20.00 WIB', strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+		(lower(hex(randomblob(16))), '', 'bantuan', '🙋 Tim CS kami akan segera menghubungi Anda.' || char(10) || 'Terima kasih telah menunggu.', strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+		(lower(hex(randomblob(16))), '', 'stop', '✅ Anda telah berhenti berlangganan notifikasi.' || char(10) || 'Ketik *start* untuk berlangganan kembali.', strftime('%Y-%m-%dT%H:%M:%SZ', 'now')),
+		(lower(hex(randomblob(16))), '', 'start', '✅ Anda telah berlangganan notifikasi.' || char(10) || 'Ketik *stop* untuk berhenti.', strftime('%Y-%m-%dT%H:%M:%SZ', 'now'));`,
+
+	`ALTER TABLE keywords ADD COLUMN match_mode TEXT NOT NULL DEFAULT 'exact';
+	ALTER TABLE keywords ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;`,
+
+	`CREATE TABLE flows (
+		id TEXT PRIMARY KEY,
+		account_id TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		trigger TEXT NOT NULL,
+		start_node TEXT NOT NULL,
+		timeout_seconds INTEGER NOT NULL DEFAULT 0,
+		nodes TEXT NOT NULL DEFAULT '[]',
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	);`,
+
+	`ALTER TABLE messages ADD COLUMN media_type TEXT;
+	ALTER TABLE broadcasts ADD COLUMN media_file TEXT NOT NULL DEFAULT '';`,
+
+	`CREATE TABLE media_files (
+		id TEXT PRIMARY KEY,
+		account_id TEXT NOT NULL DEFAULT '',
+		media_type TEXT NOT NULL,
+		mime_type TEXT NOT NULL,
+		file_name TEXT NOT NULL DEFAULT '',
+		size INTEGER NOT NULL DEFAULT 0,
+		caption TEXT NOT NULL DEFAULT '',
+		storage_key TEXT NOT NULL,
+		created_at TEXT NOT NULL
+	);
+	ALTER TABLE messages ADD COLUMN media_id TEXT REFERENCES media_files(id);`,
+
+	`CREATE INDEX idx_messages_wa_message_id ON messages(wa_message_id);`,
+
+	`ALTER TABLE scheduled_messages ADD COLUMN account_id TEXT NOT NULL DEFAULT '';`,
+
+	`CREATE TABLE jobs (
+		id TEXT PRIMARY KEY,
+		phone TEXT NOT NULL,
+		message TEXT NOT NULL,
+		msg_type TEXT NOT NULL,
+		account_id TEXT NOT NULL DEFAULT '',
+		strategy TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'queued',
+		attempts INTEGER NOT NULL DEFAULT 0,
+		max_attempts INTEGER NOT NULL DEFAULT 0,
+		next_run_at TEXT NOT NULL,
+		last_error TEXT NOT NULL DEFAULT '',
+		wa_message_id TEXT NOT NULL DEFAULT '',
+		created_at TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	);
+	CREATE INDEX idx_jobs_status_next_run ON jobs(status, next_run_at);`,
+
+	`ALTER TABLE jobs ADD COLUMN media_id TEXT NOT NULL DEFAULT '';
+	ALTER TABLE jobs ADD COLUMN media_file TEXT NOT NULL DEFAULT '';`,
+
+	`ALTER TABLE jobs ADD COLUMN lane TEXT NOT NULL DEFAULT 'bulk';
+	UPDATE jobs SET lane = msg_type WHERE msg_type IN ('reply', 'manual', 'system');
+	CREATE INDEX idx_jobs_lane_status_next_run ON jobs(lane, status, next_run_at);`,
+
+	`CREATE TABLE settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at TEXT NOT NULL
+	)`,
+
+	`ALTER TABLE users ADD COLUMN fields TEXT NOT NULL DEFAULT '{}';
+	ALTER TABLE broadcasts ADD COLUMN data TEXT NOT NULL DEFAULT '{}';`,
+
+	`ALTER TABLE users ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+	ALTER TABLE broadcasts ADD COLUMN audience TEXT;`,
+
+	`ALTER TABLE broadcasts ADD COLUMN skipped INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE broadcasts ADD COLUMN skip_reasons TEXT NOT NULL DEFAULT '{}';`,
+
+	`CREATE TABLE broadcast_recipients (
+		id TEXT PRIMARY KEY,
+		broadcast_id TEXT NOT NULL REFERENCES broadcasts(id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		phone TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		error TEXT NOT NULL DEFAULT '',
+		wa_message_id TEXT NOT NULL DEFAULT '',
+		sent_at TEXT,
+		receipt TEXT NOT NULL DEFAULT '',
+		receipt_at TEXT,
+		UNIQUE (broadcast_id, phone)
+	);
+	CREATE INDEX idx_broadcast_recipients_status ON broadcast_recipients(broadcast_id, status, position);
+	CREATE INDEX idx_broadcast_recipients_wa_message_id ON broadcast_recipients(wa_message_id);`,
+
+	`ALTER TABLE broadcasts ADD COLUMN scheduled_at TEXT;
+	ALTER TABLE broadcasts ADD COLUMN resume_at TEXT;`,
+
+	`ALTER TABLE scheduled_messages ADD COLUMN job_id TEXT NOT NULL DEFAULT '';`,
+
+	`ALTER TABLE jobs ADD COLUMN chat TEXT NOT NULL DEFAULT '';`,
+}
+
+// userUpdatableColumns guards UpdateUser against arbitrary column names
+var userUpdatableColumns = map[string]bool{
+	"name":                 true,
+	"notes":                true,
+	"account_id":           true,
+	"opt_in":               true,
+	"blocked":              true,
+	"last_user_message_at": true,
+	"last_system_sent_at":  true,
+	"fields":               true,
+	"tags":                 true,
+}
+
+const userColumns = `id, phone, name, notes, account_id, opt_in, blocked,
+	last_user_message_at, last_system_sent_at, fields, tags, created_at, updated_at`
+
+const messageColumns = `id, user_id, account_id, direction, message_type,
+	content, status, wa_message_id, media_type, media_id, created_at`
+
+const broadcastColumns = `id, name, message, account_id, recipients,
+	sent, failed, skipped, skip_reasons, total, status, delay_ms, media_file, data, audience,
+	scheduled_at, resume_at, created_at`
+
+const recipientColumns = `id, broadcast_id, position, phone, status, error,
+	wa_message_id, sent_at, receipt, receipt_at`
+
+const mediaFileColumns = `id, account_id, media_type, mime_type, file_name,
+	size, caption, storage_key, created_at`
+
+const jobColumns = `id, phone, message, msg_type, lane, media_id, media_file, account_id, strategy, chat, status,
+	attempts, max_attempts, next_run_at, last_error, wa_message_id, created_at, updated_at`
+
+const scheduledColumns = `id, phone, message, scheduled_at, account_id, status, job_id, created_at`
+
+const keywordColumns = `id, account_id, keyword, response, match_mode, priority, created_at`
+
+// sqliteStore is a self-contained store backed by an embedded SQLite file
+type sqliteStore struct {
+	db *sql.DB
+}
+
+// NewSQLite opens (and migrates) the SQLite database at path
+func NewSQLite(path string) (Store, error) {
+	db, err := sql.Open("sqlite3", fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL", path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+	// SQLite only supports one writer at a time
+	db.SetMaxOpenConns(1)
+
+	if err := migrateSQLite(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &sqliteStore{db: db}, nil
+}
+
+// migrateSQLite applies all pending migrations
+func migrateSQLite(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := version; i < len(sqliteMigrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(sqliteMigrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d failed: %w", i+1, err)
+		}
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// nowString returns the current time in UTC, in the format the stores write
+func nowString() string {
+	return time.Now().UTC().Format(time.RFC3339)
+}
+
+// placeholders returns n comma-separated "?" for an IN clause
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*User, error) {
+	var u User
+	var fields, tags string
+	err := row.Scan(&u.ID, &u.Phone, &u.Name, &u.Notes, &u.AccountID, &u.OptIn, &u.Blocked,
+		&u.LastUserMessageAt, &u.LastSystemSentAt, &fields, &tags, &u.CreatedAt, &u.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(fields), &u.Fields); err != nil {
+		return nil, fmt.Errorf("invalid fields for user %s: %w", u.ID, err)
+	}
+	if err := json.Unmarshal([]byte(tags), &u.Tags); err != nil {
+		return nil, fmt.Errorf("invalid tags for user %s: %w", u.ID, err)
+	}
+	return &u, nil
+}
+
+func scanMessage(row rowScanner) (*Message, error) {
+	var m Message
+	err := row.Scan(&m.ID, &m.UserID, &m.AccountID, &m.Direction, &m.MessageType,
+		&m.Content, &m.Status, &m.WAMessageID, &m.MediaType, &m.MediaID, &m.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func scanBroadcast(row rowScanner) (*Broadcast, error) {
+	var b Broadcast
+	var recipients, skipReasons, data string
+	var audience *string
+	err := row.Scan(&b.ID, &b.Name, &b.Message, &b.AccountID, &recipients, &b.Sent, &b.Failed, &b.Skipped,
+		&skipReasons, &b.Total, &b.Status, &b.DelayMs, &b.MediaFile, &data, &audience,
+		&b.ScheduledAt, &b.ResumeAt, &b.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(recipients), &b.Recipients); err != nil {
+		return nil, fmt.Errorf("invalid recipients for broadcast %s: %w", b.ID, err)
+	}
+	if err := json.Unmarshal([]byte(skipReasons), &b.SkipReasons); err != nil {
+		return nil, fmt.Errorf("invalid skip reasons for broadcast %s: %w", b.ID, err)
+	}
+	if err := json.Unmarshal([]byte(data), &b.Data); err != nil {
+		return nil, fmt.Errorf("invalid data for broadcast %s: %w", b.ID, err)
+	}
+	if audience != nil {
+		if err := json.Unmarshal([]byte(*audience), &b.Audience); err != nil {
+			return nil, fmt.Errorf("invalid audience for broadcast %s: %w", b.ID, err)
+		}
+	}
+	return &b, nil
+}
+
+func scanRecipient(row rowScanner) (*BroadcastRecipient, error) {
+	var r BroadcastRecipient
+	err := row.Scan(&r.ID, &r.BroadcastID, &r.Position, &r.Phone, &r.Status, &r.Error,
+		&r.WAMessageID, &r.SentAt, &r.Receipt, &r.ReceiptAt)
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func scanKeyword(row rowScanner) (*Keyword, error) {
+	var k Keyword
+	err := row.Scan(&k.ID, &k.AccountID, &k.Keyword, &k.Response, &k.MatchMode, &k.Priority, &k.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &k, nil
+}
+
+func scanJob(row rowScanner) (*Job, error) {
+	var j Job
+	err := row.Scan(&j.ID, &j.Phone, &j.Message, &j.MsgType, &j.Lane, &j.MediaID, &j.MediaFile, &j.AccountID, &j.Strategy, &j.Chat, &j.Status,
+		&j.Attempts, &j.MaxAttempts, &j.NextRunAt, &j.LastError, &j.WAMessageID, &j.CreatedAt, &j.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+// queryUser returns the first user matching the query, or nil
+func (s *sqliteStore) queryUser(query string, args ...interface{}) (*User, error) {
+	user, err := scanUser(s.db.QueryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return user, err
+}
+
+// queryUsers returns all users matching the query
+func (s *sqliteStore) queryUsers(query string, args ...interface{}) ([]User, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make([]User, 0)
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+// queryMessages returns all messages matching the query
+func (s *sqliteStore) queryMessages(query string, args ...interface{}) ([]Message, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := make([]Message, 0)
+	for rows.Next() {
+		m, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, *m)
+	}
+	return messages, rows.Err()
+}
+
+// GetUserByPhone retrieves a user by phone number
+func (s *sqliteStore) GetUserByPhone(phone string) (*User, error) {
+	return s.queryUser("SELECT "+userColumns+" FROM users WHERE phone = ? LIMIT 1", phone)
+}
+
+// GetUserByPhoneAndAccount retrieves a user by phone and account
+func (s *sqliteStore) GetUserByPhoneAndAccount(phone, accountID string) (*User, error) {
+	return s.queryUser("SELECT "+userColumns+" FROM users WHERE phone = ? AND account_id = ? LIMIT 1", phone, accountID)
+}
+
+// GetUserByID retrieves a user by ID
+func (s *sqliteStore) GetUserByID(id string) (*User, error) {
+	return s.queryUser("SELECT "+userColumns+" FROM users WHERE id = ?", id)
+}
+
+// CreateUser creates a new user
+func (s *sqliteStore) CreateUser(phone string, name *string) (*User, error) {
+	return s.insertUser(phone, name, nil)
+}
+
+// CreateUserWithAccount creates a new user linked to an account
+func (s *sqliteStore) CreateUserWithAccount(phone string, name *string, accountID string) (*User, error) {
+	return s.insertUser(phone, name, &accountID)
+}
+
+func (s *sqliteStore) insertUser(phone string, name, accountID *string) (*User, error) {
+	id := uuid.New().String()
+	now := nowString()
+	_, err := s.db.Exec(`INSERT INTO users (id, phone, name, account_id, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)`, id, phone, name, accountID, now, now)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetUserByID(id)
+}
+
+// UpdateUser updates a user
+func (s *sqliteStore) UpdateUser(id string, updates map[string]interface{}) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	sets := make([]string, 0, len(updates)+1)
+	args := make([]interface{}, 0, len(updates)+2)
+	for column, value := range updates {
+		if !userUpdatableColumns[column] {
+			return fmt.Errorf("unknown user column %q", column)
+		}
+		// Callers use the PostgREST "now()" shorthand for timestamps
+		if value == "now()" {
+			value = nowString()
+		}
+		switch v := value.(type) {
+		case map[string]string, []string:
+			b, err := json.Marshal(v)
+			if err != nil {
+				return err
+			}
+			value = string(b)
+		}
+		sets = append(sets, column+" = ?")
+		args = append(args, value)
+	}
+	sets = append(sets, "updated_at = ?")
+	args = append(args, nowString(), id)
+
+	_, err := s.db.Exec("UPDATE users SET "+strings.Join(sets, ", ")+" WHERE id = ?", args...)
+	return err
+}
+
+// GetUsers retrieves all users
+func (s *sqliteStore) GetUsers() ([]User, error) {
+	return s.queryUsers("SELECT " + userColumns + " FROM users ORDER BY created_at DESC")
+}
+
+// GetUsersByPhone retrieves every account's user for a phone, most recently active first
+func (s *sqliteStore) GetUsersByPhone(phone string) ([]User, error) {
+	return s.queryUsers("SELECT "+userColumns+` FROM users WHERE phone = ?
+		ORDER BY last_user_message_at IS NULL, last_user_message_at DESC`, phone)
+}
+
+// GetUsersByAccount retrieves users for a specific account
+func (s *sqliteStore) GetUsersByAccount(accountID string) ([]User, error) {
+	return s.queryUsers("SELECT "+userColumns+" FROM users WHERE account_id = ? ORDER BY created_at DESC", accountID)
+}
+
+// LogMessage logs a message to the database
+func (s *sqliteStore) LogMessage(userID, direction, msgType, content string, waMessageID *string) error {
+	return s.CreateMessage(&Message{
+		UserID:      userID,
+		Direction:   direction,
+		MessageType: msgType,
+		Content:     &content,
+		WAMessageID: waMessageID,
+	})
+}
+
+// LogMessageWithAccount logs a message with account_id
+func (s *sqliteStore) LogMessageWithAccount(userID, accountID, direction, msgType, content string, waMessageID *string) error {
+	return s.CreateMessage(&Message{
+		UserID:      userID,
+		AccountID:   &accountID,
+		Direction:   direction,
+		MessageType: msgType,
+		Content:     &content,
+		WAMessageID: waMessageID,
+	})
+}
+
+// CreateMessage logs a fully described message
+func (s *sqliteStore) CreateMessage(m *Message) error {
+	m.ID = uuid.New().String()
+	m.CreatedAt = nowString()
+	if m.Status == "" {
+		m.Status = "sent"
+	}
+	_, err := s.db.Exec(`INSERT INTO messages (`+messageColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.ID, m.UserID, m.AccountID, m.Direction, m.MessageType,
+		m.Content, m.Status, m.WAMessageID, m.MediaType, m.MediaID, m.CreatedAt)
+	return err
+}
+
+// UpdateMessageStatus moves outgoing messages forward to a delivery status
+func (s *sqliteStore) UpdateMessageStatus(waMessageIDs []string, status string) error {
+	from, ok := statusPredecessors[status]
+	if !ok {
+		return fmt.Errorf("unknown message status %q", status)
+	}
+	if len(waMessageIDs) == 0 {
+		return nil
+	}
+
+	args := []interface{}{status}
+	for _, id := range waMessageIDs {
+		args = append(args, id)
+	}
+	for _, st := range from {
+		args = append(args, st)
+	}
+	_, err := s.db.Exec(`UPDATE messages SET status = ?
+		WHERE wa_message_id IN (`+placeholders(len(waMessageIDs))+`)
+		AND direction = 'outgoing'
+		AND status IN (`+placeholders(len(from))+`)`, args...)
+	return err
+}
+
+// GetMessages retrieves messages with pagination
+func (s *sqliteStore) GetMessages(limit, offset int) ([]Message, error) {
+	return s.queryMessages("SELECT "+messageColumns+" FROM messages ORDER BY created_at DESC LIMIT ? OFFSET ?", limit, offset)
+}
+
+// GetMessagesByAccount retrieves messages for a specific account
+func (s *sqliteStore) GetMessagesByAccount(accountID string, limit, offset int) ([]Message, error) {
+	return s.queryMessages("SELECT "+messageColumns+" FROM messages WHERE account_id = ? ORDER BY created_at DESC LIMIT ? OFFSET ?",
+		accountID, limit, offset)
+}
+
+// GetMessagesByUser retrieves messages for a specific user
+func (s *sqliteStore) GetMessagesByUser(userID string, limit, offset int) ([]Message, error) {
+	return s.queryMessages("SELECT "+messageColumns+" FROM messages WHERE user_id = ? ORDER BY created_at ASC LIMIT ? OFFSET ?",
+		userID, limit, offset)
+}
+
+// CountOutgoingByType counts the messages sent to a phone since a time, by message type
+func (s *sqliteStore) CountOutgoingByType(phone, since string) (map[string]int, error) {
+	rows, err := s.db.Query(`SELECT m.message_type, COUNT(*) FROM messages m
+		JOIN users u ON u.id = m.user_id
+		WHERE u.phone = ? AND m.direction = 'outgoing' AND m.status != 'failed' AND m.created_at >= ?
+		GROUP BY m.message_type`, phone, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var msgType string
+		var n int
+		if err := rows.Scan(&msgType, &n); err != nil {
+			return nil, err
+		}
+		counts[msgType] = n
+	}
+	return counts, rows.Err()
+}
+
+// ========== MEDIA ==========
+
+// CreateMediaFile records a stored media file
+func (s *sqliteStore) CreateMediaFile(m *MediaFile) error {
+	prepareMediaFile(m)
+	_, err := s.db.Exec(`INSERT INTO media_files (`+mediaFileColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		m.ID, m.AccountID, m.MediaType, m.MimeType, m.FileName,
+		m.Size, m.Caption, m.StorageKey, m.CreatedAt)
+	return err
+}
+
+// GetMediaFile returns a media file by ID
+func (s *sqliteStore) GetMediaFile(id string) (*MediaFile, error) {
+	var m MediaFile
+	err := s.db.QueryRow("SELECT "+mediaFileColumns+" FROM media_files WHERE id = ?", id).
+		Scan(&m.ID, &m.AccountID, &m.MediaType, &m.MimeType, &m.FileName,
+			&m.Size, &m.Caption, &m.StorageKey, &m.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ========== TEMPLATES ==========
+
+// GetTemplates returns all templates
+func (s *sqliteStore) GetTemplates() ([]Template, error) {
+	rows, err := s.db.Query("SELECT id, name, content, created_at FROM templates ORDER BY created_at ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := make([]Template, 0)
+	for rows.Next() {
+		var t Template
+		if err := rows.Scan(&t.ID, &t.Name, &t.Content, &t.CreatedAt); err != nil {
+			return nil, err
+		}
+		templates = append(templates, t)
+	}
+	return templates, rows.Err()
+}
+
+// AddTemplate adds a new template
+func (s *sqliteStore) AddTemplate(name, content string) (Template, error) {
+	t := Template{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Content:   content,
+		CreatedAt: nowString(),
+	}
+	_, err := s.db.Exec("INSERT INTO templates (id, name, content, created_at) VALUES (?, ?, ?, ?)",
+		t.ID, t.Name, t.Content, t.CreatedAt)
+	if err != nil {
+		return Template{}, err
+	}
+	return t, nil
+}
+
+// DeleteTemplate deletes a template
+func (s *sqliteStore) DeleteTemplate(id string) error {
+	_, err := s.db.Exec("DELETE FROM templates WHERE id = ?", id)
+	return err
+}
+
+// ========== SCHEDULED ==========
+
+// queryScheduled returns all scheduled messages matching the query
+func (s *sqliteStore) queryScheduled(query string, args ...interface{}) ([]ScheduledMessage, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	scheduled := make([]ScheduledMessage, 0)
+	for rows.Next() {
+		var sm ScheduledMessage
+		if err := rows.Scan(&sm.ID, &sm.Phone, &sm.Message, &sm.ScheduledAt, &sm.AccountID, &sm.Status, &sm.JobID, &sm.CreatedAt); err != nil {
+			return nil, err
+		}
+		scheduled = append(scheduled, sm)
+	}
+	return scheduled, rows.Err()
+}
+
+// GetScheduled returns all scheduled messages
+func (s *sqliteStore) GetScheduled() ([]ScheduledMessage, error) {
+	return s.queryScheduled("SELECT " + scheduledColumns + " FROM scheduled_messages ORDER BY scheduled_at ASC")
+}
+
+// AddScheduled adds a new scheduled message
+func (s *sqliteStore) AddScheduled(phone, message, scheduledAt, accountID string) (ScheduledMessage, error) {
+	sm := ScheduledMessage{
+		ID:          uuid.New().String(),
+		Phone:       phone,
+		Message:     message,
+		ScheduledAt: scheduledAt,
+		AccountID:   accountID,
+		Status:      "pending",
+		CreatedAt:   nowString(),
+	}
+	_, err := s.db.Exec(`INSERT INTO scheduled_messages (id, phone, message, scheduled_at, account_id, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`, sm.ID, sm.Phone, sm.Message, sm.ScheduledAt, sm.AccountID, sm.Status, sm.CreatedAt)
+	if err != nil {
+		return ScheduledMessage{}, err
+	}
+	return sm, nil
+}
+
+// DeleteScheduled deletes a scheduled message
+func (s *sqliteStore) DeleteScheduled(id string) error {
+	_, err := s.db.Exec("DELETE FROM scheduled_messages WHERE id = ?", id)
+	return err
+}
+
+// UpdateScheduledStatus updates the status of a scheduled message
+func (s *sqliteStore) UpdateScheduledStatus(id, status string) error {
+	_, err := s.db.Exec("UPDATE scheduled_messages SET status = ? WHERE id = ?", status, id)
+	return err
+}
+
+// SetScheduledJob marks a scheduled message as queued by the given job
+func (s *sqliteStore) SetScheduledJob(id, jobID string) error {
+	_, err := s.db.Exec("UPDATE scheduled_messages SET status = 'queued', job_id = ? WHERE id = ?", jobID, id)
+	return err
+}
+
+// GetQueuedScheduled returns scheduled messages whose job has not finished yet
+func (s *sqliteStore) GetQueuedScheduled() ([]ScheduledMessage, error) {
+	return s.queryScheduled("SELECT " + scheduledColumns +
+		" FROM scheduled_messages WHERE status = 'queued' ORDER BY scheduled_at ASC")
+}
+
+// GetPendingScheduled returns scheduled messages that are due
+func (s *sqliteStore) GetPendingScheduled() ([]ScheduledMessage, error) {
+	pending, err := s.queryScheduled("SELECT " + scheduledColumns +
+		" FROM scheduled_messages WHERE status = 'pending' ORDER BY scheduled_at ASC")
+	if err != nil {
+		return nil, err
+	}
+
+	// scheduled_at keeps the caller's offset, so compare parsed times rather than strings
+	now := time.Now()
+	due := make([]ScheduledMessage, 0, len(pending))
+	for _, sm := range pending {
+		scheduledTime, err := time.Parse(time.RFC3339, sm.ScheduledAt)
+		if err != nil {
+			continue
+		}
+		if !scheduledTime.After(now) {
+			due = append(due, sm)
+		}
+	}
+	return due, nil
+}
+
+// ========== BROADCAST ==========
+
+// GetBroadcasts returns all broadcasts
+func (s *sqliteStore) GetBroadcasts() ([]*Broadcast, error) {
+	rows, err := s.db.Query("SELECT " + broadcastColumns + " FROM broadcasts ORDER BY created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	broadcasts := make([]*Broadcast, 0)
+	for rows.Next() {
+		b, err := scanBroadcast(rows)
+		if err != nil {
+			return nil, err
+		}
+		broadcasts = append(broadcasts, b)
+	}
+	return broadcasts, rows.Err()
+}
+
+// GetBroadcast returns a broadcast by ID
+func (s *sqliteStore) GetBroadcast(id string) (*Broadcast, error) {
+	b, err := scanBroadcast(s.db.QueryRow("SELECT "+broadcastColumns+" FROM broadcasts WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return b, err
+}
+
+// CreateBroadcast creates a new broadcast
+func (s *sqliteStore) CreateBroadcast(b *Broadcast) (*Broadcast, error) {
+	prepareBroadcast(b)
+	recipientsJSON, err := json.Marshal(b.Recipients)
+	if err != nil {
+		return nil, err
+	}
+	dataJSON, err := json.Marshal(b.Data)
+	if err != nil {
+		return nil, err
+	}
+	var audience *string
+	if b.Audience != nil {
+		audienceJSON, err := json.Marshal(b.Audience)
+		if err != nil {
+			return nil, err
+		}
+		a := string(audienceJSON)
+		audience = &a
+	}
+	_, err = s.db.Exec(`INSERT INTO broadcasts (`+broadcastColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		b.ID, b.Name, b.Message, b.AccountID, string(recipientsJSON), b.Sent, b.Failed, b.Skipped, "{}",
+		b.Total, b.Status, b.DelayMs, b.MediaFile, string(dataJSON), audience,
+		b.ScheduledAt, b.ResumeAt, b.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// UpdateBroadcast saves the progress of a broadcast
+func (s *sqliteStore) UpdateBroadcast(id string, p BroadcastProgress) error {
+	reasons := p.SkipReasons
+	if reasons == nil {
+		reasons = map[string]int{}
+	}
+	reasonsJSON, err := json.Marshal(reasons)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`UPDATE broadcasts SET sent = ?, failed = ?, skipped = ?, skip_reasons = ?, status = ?,
+		resume_at = ? WHERE id = ?`,
+		p.Sent, p.Failed, p.Skipped, string(reasonsJSON), p.Status, p.ResumeAt, id)
+	return err
+}
+
+// GetDueBroadcasts returns the broadcasts the scheduler should start or resume
+func (s *sqliteStore) GetDueBroadcasts() ([]*Broadcast, error) {
+	now := nowString()
+	rows, err := s.db.Query("SELECT "+broadcastColumns+` FROM broadcasts
+		WHERE (status = 'pending' AND scheduled_at <= ?) OR (status = 'paused' AND resume_at <= ?)
+		ORDER BY created_at ASC`, now, now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	broadcasts := make([]*Broadcast, 0)
+	for rows.Next() {
+		b, err := scanBroadcast(rows)
+		if err != nil {
+			return nil, err
+		}
+		broadcasts = append(broadcasts, b)
+	}
+	return broadcasts, rows.Err()
+}
+
+// SetBroadcastRecipients replaces the recipients of a broadcast
+func (s *sqliteStore) SetBroadcastRecipients(id string, recipients []string) error {
+	recipientsJSON, err := json.Marshal(recipients)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec("UPDATE broadcasts SET recipients = ?, total = ? WHERE id = ?",
+		string(recipientsJSON), len(recipients), id)
+	return err
+}
+
+// DeleteBroadcast deletes a broadcast
+func (s *sqliteStore) DeleteBroadcast(id string) error {
+	_, err := s.db.Exec("DELETE FROM broadcasts WHERE id = ?", id)
+	return err
+}
+
+// ========== BROADCAST RECIPIENTS ==========
+
+// AddBroadcastRecipients logs recipients as pending after the existing ones
+func (s *sqliteStore) AddBroadcastRecipients(broadcastID string, phones []string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var next int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(position) + 1, 0) FROM broadcast_recipients WHERE broadcast_id = ?",
+		broadcastID).Scan(&next); err != nil {
+		return err
+	}
+	stmt, err := tx.Prepare(`INSERT OR IGNORE INTO broadcast_recipients (id, broadcast_id, position, phone)
+		VALUES (?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	for i, phone := range uniquePhones(phones) {
+		if _, err := stmt.Exec(uuid.New().String(), broadcastID, next+i, phone); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetBroadcastRecipients returns the delivery log of a broadcast in send order
+func (s *sqliteStore) GetBroadcastRecipients(broadcastID string, f RecipientFilter) ([]BroadcastRecipient, error) {
+	where := []string{"broadcast_id = ?"}
+	args := []interface{}{broadcastID}
+	if f.Status != "" {
+		where = append(where, "status = ?")
+		args = append(args, f.Status)
+	}
+	if f.Receipt != "" {
+		where = append(where, "receipt = ?")
+		args = append(args, f.Receipt)
+	}
+	if f.Phone != "" {
+		where = append(where, "phone = ?")
+		args = append(args, f.Phone)
+	}
+	limit := f.Limit
+	if limit <= 0 {
+		limit = -1 // No limit
+	}
+	args = append(args, limit, f.Offset)
+
+	rows, err := s.db.Query("SELECT "+recipientColumns+" FROM broadcast_recipients WHERE "+
+		strings.Join(where, " AND ")+" ORDER BY position LIMIT ? OFFSET ?", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	recipients := make([]BroadcastRecipient, 0)
+	for rows.Next() {
+		r, err := scanRecipient(rows)
+		if err != nil {
+			return nil, err
+		}
+		recipients = append(recipients, *r)
+	}
+	return recipients, rows.Err()
+}
+
+// UpdateBroadcastRecipient saves the outcome of sending to a recipient
+func (s *sqliteStore) UpdateBroadcastRecipient(r *BroadcastRecipient) error {
+	_, err := s.db.Exec(`UPDATE broadcast_recipients SET status = ?, error = ?, wa_message_id = ?,
+		sent_at = ?, receipt = ?, receipt_at = ? WHERE id = ?`,
+		r.Status, r.Error, r.WAMessageID, r.SentAt, r.Receipt, r.ReceiptAt, r.ID)
+	return err
+}
+
+// RequeueFailedRecipients moves the failed recipients of a broadcast back to pending
+func (s *sqliteStore) RequeueFailedRecipients(broadcastID string) (int, error) {
+	res, err := s.db.Exec(`UPDATE broadcast_recipients SET status = 'pending', error = '', wa_message_id = '',
+		sent_at = NULL, receipt = '', receipt_at = NULL WHERE broadcast_id = ? AND status = 'failed'`, broadcastID)
+	if err != nil {
+		return 0, err
+	}
+	n, err := res.RowsAffected()
+	return int(n), err
+}
+
+// UpdateRecipientReceipts moves sent broadcast recipients forward to a delivery status
+func (s *sqliteStore) UpdateRecipientReceipts(waMessageIDs []string, receipt string) error {
+	from, ok := statusPredecessors[receipt]
+	if !ok {
+		return fmt.Errorf("unknown message status %q", receipt)
+	}
+	if len(waMessageIDs) == 0 {
+		return nil
+	}
+
+	args := []interface{}{receipt, nowString()}
+	for _, id := range waMessageIDs {
+		args = append(args, id)
+	}
+	for _, st := range from {
+		args = append(args, st)
+	}
+	_, err := s.db.Exec(`UPDATE broadcast_recipients SET receipt = ?, receipt_at = ?
+		WHERE wa_message_id IN (`+placeholders(len(waMessageIDs))+`)
+		AND receipt IN (`+placeholders(len(from))+`)`, args...)
+	return err
+}
+
+// ========== KEYWORDS ==========
+
+// GetKeywords returns all keyword rules
+func (s *sqliteStore) GetKeywords() ([]Keyword, error) {
+	rows, err := s.db.Query("SELECT " + keywordColumns + " FROM keywords ORDER BY keyword ASC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keywords := make([]Keyword, 0)
+	for rows.Next() {
+		k, err := scanKeyword(rows)
+		if err != nil {
+			return nil, err
+		}
+		keywords = append(keywords, *k)
+	}
+	return keywords, rows.Err()
+}
+
+// UpsertKeyword creates or replaces a keyword rule
+func (s *sqliteStore) UpsertKeyword(k Keyword) (Keyword, error) {
+	_, err := s.db.Exec(`INSERT INTO keywords (`+keywordColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (account_id, keyword) DO UPDATE SET
+			response = excluded.response,
+			match_mode = excluded.match_mode,
+			priority = excluded.priority`,
+		uuid.New().String(), k.AccountID, k.Keyword, k.Response, k.MatchMode, k.Priority, nowString())
+	if err != nil {
+		return Keyword{}, err
+	}
+
+	saved, err := scanKeyword(s.db.QueryRow("SELECT "+keywordColumns+" FROM keywords WHERE account_id = ? AND keyword = ?",
+		k.AccountID, k.Keyword))
+	if err != nil {
+		return Keyword{}, err
+	}
+	return *saved, nil
+}
+
+// DeleteKeyword deletes a keyword rule
+func (s *sqliteStore) DeleteKeyword(accountID, keyword string) error {
+	_, err := s.db.Exec("DELETE FROM keywords WHERE account_id = ? AND keyword = ?", accountID, keyword)
+	return err
+}
+
+// ========== SETTINGS ==========
+
+// GetSetting returns the settings stored under a key
+func (s *sqliteStore) GetSetting(key string) (*Setting, error) {
+	var st Setting
+	err := s.db.QueryRow("SELECT key, value, updated_at FROM settings WHERE key = ?", key).
+		Scan(&st.Key, &st.Value, &st.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+// SaveSetting creates or replaces the settings stored under a key
+func (s *sqliteStore) SaveSetting(key, value string) error {
+	_, err := s.db.Exec(`INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		key, value, nowString())
+	return err
+}
+
+// ========== JOBS ==========
+
+// queryJobs returns all jobs matching the query
+func (s *sqliteStore) queryJobs(query string, args ...interface{}) ([]Job, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := make([]Job, 0)
+	for rows.Next() {
+		j, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, *j)
+	}
+	return jobs, rows.Err()
+}
+
+// CreateJob adds a job to the send queue
+func (s *sqliteStore) CreateJob(j *Job) error {
+	prepareJob(j)
+	_, err := s.db.Exec(`INSERT INTO jobs (`+jobColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		j.ID, j.Phone, j.Message, j.MsgType, j.Lane, j.MediaID, j.MediaFile, j.AccountID, j.Strategy, j.Chat, j.Status,
+		j.Attempts, j.MaxAttempts, j.NextRunAt, j.LastError, j.WAMessageID, j.CreatedAt, j.UpdatedAt)
+	return err
+}
+
+// GetJob returns a job by ID
+func (s *sqliteStore) GetJob(id string) (*Job, error) {
+	j, err := scanJob(s.db.QueryRow("SELECT "+jobColumns+" FROM jobs WHERE id = ?", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return j, err
+}
+
+// GetJobs returns jobs in the given statuses, newest first
+func (s *sqliteStore) GetJobs(statuses []string, limit int) ([]Job, error) {
+	if len(statuses) == 0 {
+		return s.queryJobs("SELECT "+jobColumns+" FROM jobs ORDER BY created_at DESC LIMIT ?", limit)
+	}
+
+	args := make([]interface{}, 0, len(statuses)+1)
+	for _, st := range statuses {
+		args = append(args, st)
+	}
+	args = append(args, limit)
+	return s.queryJobs("SELECT "+jobColumns+" FROM jobs WHERE status IN ("+placeholders(len(statuses))+`)
+		ORDER BY created_at DESC LIMIT ?`, args...)
+}
+
+// GetDueJobs returns queued jobs of a lane whose next run time has passed, oldest
+// first: the oldest perAccount jobs of each account, so a backlog on one account
+// never hides the others, and the oldest limit jobs without an account
+func (s *sqliteStore) GetDueJobs(lane string, perAccount, limit int) ([]Job, error) {
+	return s.queryJobs("SELECT "+jobColumns+` FROM (
+			SELECT *, rowid AS seq,
+				ROW_NUMBER() OVER (PARTITION BY account_id ORDER BY next_run_at, rowid) AS position
+			FROM jobs
+			WHERE lane = ? AND status = 'queued' AND next_run_at <= ?
+		)
+		WHERE (account_id <> '' AND position <= ?) OR (account_id = '' AND position <= ?)
+		ORDER BY next_run_at ASC, seq ASC`, lane, nowString(), perAccount, limit)
+}
+
+// UpdateJob saves the state of a job
+func (s *sqliteStore) UpdateJob(j *Job) error {
+	j.UpdatedAt = nowString()
+	_, err := s.db.Exec(`UPDATE jobs SET account_id = ?, status = ?, attempts = ?, max_attempts = ?,
+		next_run_at = ?, last_error = ?, wa_message_id = ?, updated_at = ?
+		WHERE id = ?`,
+		j.AccountID, j.Status, j.Attempts, j.MaxAttempts,
+		j.NextRunAt, j.LastError, j.WAMessageID, j.UpdatedAt, j.ID)
+	return err
+}
+
+// DeleteJob removes a job from the queue
+func (s *sqliteStore) DeleteJob(id string) error {
+	_, err := s.db.Exec("DELETE FROM jobs WHERE id = ?", id)
+	return err
+}
+
+// ========== FLOWS ==========
+
+// GetFlows returns all conversational flows
+func (s *sqliteStore) GetFlows() ([]Flow, error) {
+	rows, err := s.db.Query(`SELECT id, account_id, name, trigger, start_node, timeout_seconds, nodes, created_at, updated_at
+		FROM flows ORDER BY created_at ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	flows := make([]Flow, 0)
+	for rows.Next() {
+		var f Flow
+		var nodes string
+		err := rows.Scan(&f.ID, &f.AccountID, &f.Name, &f.Trigger, &f.StartNode, &f.TimeoutSeconds,
+			&nodes, &f.CreatedAt, &f.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(nodes), &f.Nodes); err != nil {
+			return nil, fmt.Errorf("invalid nodes for flow %s: %w", f.ID, err)
+		}
+		flows = append(flows, f)
+	}
+	return flows, rows.Err()
+}
+
+// SaveFlow creates or replaces a flow
+func (s *sqliteStore) SaveFlow(f Flow) (Flow, error) {
+	prepareFlow(&f)
+	nodes, err := json.Marshal(f.Nodes)
+	if err != nil {
+		return Flow{}, err
+	}
+	_, err = s.db.Exec(`INSERT INTO flows (id, account_id, name, trigger, start_node, timeout_seconds, nodes, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET
+			account_id = excluded.account_id,
+			name = excluded.name,
+			trigger = excluded.trigger,
+			start_node = excluded.start_node,
+			timeout_seconds = excluded.timeout_seconds,
+			nodes = excluded.nodes,
+			updated_at = excluded.updated_at`,
+		f.ID, f.AccountID, f.Name, f.Trigger, f.StartNode, f.TimeoutSeconds, string(nodes), f.CreatedAt, f.UpdatedAt)
+	if err != nil {
+		return Flow{}, err
+	}
+	return f, nil
+}
+
+// DeleteFlow deletes a flow
+func (s *sqliteStore) DeleteFlow(id string) error {
+	_, err := s.db.Exec("DELETE FROM flows WHERE id = ?", id)
+	return err
+}
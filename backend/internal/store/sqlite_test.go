@@ -0,0 +1,82 @@
+package store
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestSQLite opens a fresh SQLite store for one test
+func newTestSQLite(t *testing.T) Store {
+	t.Helper()
+	s, err := NewSQLite(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// inLocalZone runs the test with a local timezone away from UTC
+func inLocalZone(t *testing.T) {
+	t.Helper()
+	local := time.Local
+	time.Local = time.FixedZone("WIB", 7*60*60)
+	t.Cleanup(func() { time.Local = local })
+}
+
+func TestCreatedAtIsStoredInUTC(t *testing.T) {
+	inLocalZone(t)
+	s := newTestSQLite(t)
+
+	if _, err := s.AddTemplate("Greeting", "Halo {{name}}"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddScheduled("62811", "Halo", time.Now().Add(time.Hour).UTC().Format(time.RFC3339), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateBroadcast(&Broadcast{Name: "Promo", Message: "Halo", Recipients: []string{"62811"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Read back, as the scheduler and the daily windows compare them as text
+	templates, err := s.GetTemplates()
+	if err != nil || len(templates) != 1 {
+		t.Fatalf("GetTemplates() = %v, %v", templates, err)
+	}
+	scheduled, err := s.GetScheduled()
+	if err != nil || len(scheduled) != 1 {
+		t.Fatalf("GetScheduled() = %v, %v", scheduled, err)
+	}
+	broadcasts, err := s.GetBroadcasts()
+	if err != nil || len(broadcasts) != 1 {
+		t.Fatalf("GetBroadcasts() = %v, %v", broadcasts, err)
+	}
+
+	for name, createdAt := range map[string]string{
+		"template":          templates[0].CreatedAt,
+		"scheduled message": scheduled[0].CreatedAt,
+		"broadcast":         broadcasts[0].CreatedAt,
+	} {
+		if !strings.HasSuffix(createdAt, "Z") {
+			t.Errorf("%s created_at = %q, want UTC", name, createdAt)
+		}
+	}
+}
+
+func TestMediaFileCreatedAtIsStoredInUTC(t *testing.T) {
+	inLocalZone(t)
+	s := newTestSQLite(t)
+
+	m := &MediaFile{AccountID: "acc-a", MediaType: "image", MimeType: "image/jpeg", StorageKey: "acc-a/photo.jpg"}
+	if err := s.CreateMediaFile(m); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := s.GetMediaFile(m.ID)
+	if err != nil || stored == nil {
+		t.Fatalf("GetMediaFile() = %v, %v", stored, err)
+	}
+	if !strings.HasSuffix(stored.CreatedAt, "Z") {
+		t.Fatalf("created_at = %q, want UTC", stored.CreatedAt)
+	}
+}
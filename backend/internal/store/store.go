@@ -0,0 +1,140 @@
+package store
+
+import (
+	"fmt"
+
+	"esther-whatsapp/internal/config"
+)
+
+// Store is the persistence layer used by the bot.
+// Lookups return (nil, nil) when the requested record does not exist.
+type Store interface {
+	// Users
+	GetUserByPhone(phone string) (*User, error)
+	GetUserByPhoneAndAccount(phone, accountID string) (*User, error)
+	GetUsersByPhone(phone string) ([]User, error) // Most recently active first
+	GetUserByID(id string) (*User, error)
+	CreateUser(phone string, name *string) (*User, error)
+	CreateUserWithAccount(phone string, name *string, accountID string) (*User, error)
+	UpdateUser(id string, updates map[string]interface{}) error
+	GetUsers() ([]User, error)
+	GetUsersByAccount(accountID string) ([]User, error)
+
+	// Messages
+	LogMessage(userID, direction, msgType, content string, waMessageID *string) error
+	LogMessageWithAccount(userID, accountID, direction, msgType, content string, waMessageID *string) error
+	CreateMessage(m *Message) error
+	UpdateMessageStatus(waMessageIDs []string, status string) error
+	GetMessages(limit, offset int) ([]Message, error)
+	GetMessagesByAccount(accountID string, limit, offset int) ([]Message, error)
+	GetMessagesByUser(userID string, limit, offset int) ([]Message, error)
+	CountOutgoingByType(phone, since string) (map[string]int, error) // Messages sent to a phone on any account, failed sends excluded
+
+	// Media files
+	CreateMediaFile(m *MediaFile) error
+	GetMediaFile(id string) (*MediaFile, error)
+
+	// Templates
+	GetTemplates() ([]Template, error)
+	AddTemplate(name, content string) (Template, error)
+	DeleteTemplate(id string) error
+
+	// Scheduled messages
+	GetScheduled() ([]ScheduledMessage, error)
+	AddScheduled(phone, message, scheduledAt, accountID string) (ScheduledMessage, error)
+	DeleteScheduled(id string) error
+	UpdateScheduledStatus(id, status string) error
+	SetScheduledJob(id, jobID string) error
+	GetQueuedScheduled() ([]ScheduledMessage, error)
+	GetPendingScheduled() ([]ScheduledMessage, error)
+
+	// Broadcasts
+	GetBroadcasts() ([]*Broadcast, error)
+	GetBroadcast(id string) (*Broadcast, error)
+	CreateBroadcast(b *Broadcast) (*Broadcast, error)
+	UpdateBroadcast(id string, p BroadcastProgress) error
+	SetBroadcastRecipients(id string, recipients []string) error // Also resets the total
+	GetDueBroadcasts() ([]*Broadcast, error)                     // Pending past scheduled_at, or paused past resume_at
+	DeleteBroadcast(id string) error
+
+	// Broadcast delivery log, one row per recipient in send order
+	AddBroadcastRecipients(broadcastID string, phones []string) error // As pending, duplicates dropped
+	GetBroadcastRecipients(broadcastID string, f RecipientFilter) ([]BroadcastRecipient, error)
+	UpdateBroadcastRecipient(r *BroadcastRecipient) error
+	RequeueFailedRecipients(broadcastID string) (int, error) // Failed back to pending, returns how many
+	UpdateRecipientReceipts(waMessageIDs []string, receipt string) error
+
+	// Keywords (an empty accountID means the rule applies to every account)
+	GetKeywords() ([]Keyword, error)
+	UpsertKeyword(k Keyword) (Keyword, error)
+	DeleteKeyword(accountID, keyword string) error
+
+	// Send queue (times are UTC RFC3339)
+	CreateJob(j *Job) error
+	GetJob(id string) (*Job, error)
+	GetJobs(statuses []string, limit int) ([]Job, error)          // Newest first, all statuses when empty
+	GetDueJobs(lane string, perAccount, limit int) ([]Job, error) // Due queued jobs of a lane: the oldest perAccount of each account and the oldest limit unpinned ones
+	UpdateJob(j *Job) error
+	DeleteJob(id string) error
+
+	// Runtime settings
+	GetSetting(key string) (*Setting, error)
+	SaveSetting(key, value string) error
+
+	// Conversational flows
+	GetFlows() ([]Flow, error)
+	SaveFlow(f Flow) (Flow, error)
+	DeleteFlow(id string) error
+}
+
+// DB is the active store, set by Init
+var DB Store
+
+// Init opens the store selected by STORE_DRIVER
+func Init() error {
+	switch config.AppConfig.StoreDriver {
+	case "supabase":
+		DB = NewSupabase(config.AppConfig.SupabaseURL, config.AppConfig.SupabaseKey)
+	case "sqlite":
+		s, err := NewSQLite(config.AppConfig.SQLitePath)
+		if err != nil {
+			return err
+		}
+		DB = s
+	default:
+		return fmt.Errorf("unknown store driver %q", config.AppConfig.StoreDriver)
+	}
+	return nil
+}
+
+// User represents a WhatsApp user
+type User struct {
+	ID                string            `json:"id"`
+	Phone             string            `json:"phone"`
+	Name              *string           `json:"name"`
+	Notes             *string           `json:"notes"`
+	AccountID         *string           `json:"account_id"`
+	OptIn             bool              `json:"opt_in"`
+	Blocked           bool              `json:"blocked"`
+	LastUserMessageAt *string           `json:"last_user_message_at"`
+	LastSystemSentAt  *string           `json:"last_system_sent_at"`
+	Fields            map[string]string `json:"fields"` // Custom values for message placeholders
+	Tags              []string          `json:"tags"`   // Segments for broadcast audiences
+	CreatedAt         string            `json:"created_at"`
+	UpdatedAt         string            `json:"updated_at"`
+}
+
+// Message represents a message log
+type Message struct {
+	ID          string  `json:"id"`
+	UserID      string  `json:"user_id"`
+	AccountID   *string `json:"account_id"`
+	Direction   string  `json:"direction"`    // incoming | outgoing
+	MessageType string  `json:"message_type"` // reply | system | manual
+	Content     *string `json:"content"`
+	Status      string  `json:"status"` // sent | delivered | read | failed
+	WAMessageID *string `json:"wa_message_id"`
+	MediaType   *string `json:"media_type"` // image | video | audio | document | sticker | location, nil for text
+	MediaID     *string `json:"media_id"`   // Stored file, see GET /api/media/:id
+	CreatedAt   string  `json:"created_at"`
+}
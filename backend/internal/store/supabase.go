@@ -1,58 +1,38 @@
 package store
 
 import (
-	"esther-whatsapp/internal/config"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/supabase-community/postgrest-go"
 )
 
-var Client *postgrest.Client
+// supabaseStore stores data in Supabase through its PostgREST API
+type supabaseStore struct {
+	client *postgrest.Client
+}
 
-func InitSupabase() error {
+// NewSupabase creates a store backed by a Supabase project
+func NewSupabase(url, key string) Store {
 	// Use postgrest-go directly for database operations
-	Client = postgrest.NewClient(
-		config.AppConfig.SupabaseURL+"/rest/v1",
+	client := postgrest.NewClient(
+		url+"/rest/v1",
 		"",
 		map[string]string{
-			"apikey":        config.AppConfig.SupabaseKey,
-			"Authorization": "Bearer " + config.AppConfig.SupabaseKey,
+			"apikey":        key,
+			"Authorization": "Bearer " + key,
 		},
 	)
-	return nil
-}
-
-// User represents a WhatsApp user
-type User struct {
-	ID                string  `json:"id"`
-	Phone             string  `json:"phone"`
-	Name              *string `json:"name"`
-	Notes             *string `json:"notes"`
-	AccountID         *string `json:"account_id"`
-	OptIn             bool    `json:"opt_in"`
-	Blocked           bool    `json:"blocked"`
-	LastUserMessageAt *string `json:"last_user_message_at"`
-	LastSystemSentAt  *string `json:"last_system_sent_at"`
-	CreatedAt         string  `json:"created_at"`
-	UpdatedAt         string  `json:"updated_at"`
-}
-
-// Message represents a message log
-type Message struct {
-	ID          string  `json:"id"`
-	UserID      string  `json:"user_id"`
-	AccountID   *string `json:"account_id"`
-	Direction   string  `json:"direction"`    // incoming | outgoing
-	MessageType string  `json:"message_type"` // reply | system | manual
-	Content     *string `json:"content"`
-	Status      string  `json:"status"` // sent | delivered | read | failed
-	WAMessageID *string `json:"wa_message_id"`
-	CreatedAt   string  `json:"created_at"`
+	return &supabaseStore{client: client}
 }
 
 // GetUserByPhone retrieves a user by phone number
-func GetUserByPhone(phone string) (*User, error) {
+func (s *supabaseStore) GetUserByPhone(phone string) (*User, error) {
 	var users []User
-	_, err := Client.From("users").Select("*", "", false).Eq("phone", phone).ExecuteTo(&users)
+	_, err := s.client.From("users").Select("*", "", false).Eq("phone", phone).ExecuteTo(&users)
 	if err != nil {
 		return nil, err
 	}
@@ -63,9 +43,9 @@ func GetUserByPhone(phone string) (*User, error) {
 }
 
 // GetUserByPhoneAndAccount retrieves a user by phone and account
-func GetUserByPhoneAndAccount(phone, accountID string) (*User, error) {
+func (s *supabaseStore) GetUserByPhoneAndAccount(phone, accountID string) (*User, error) {
 	var users []User
-	_, err := Client.From("users").
+	_, err := s.client.From("users").
 		Select("*", "", false).
 		Eq("phone", phone).
 		Eq("account_id", accountID).
@@ -79,10 +59,21 @@ func GetUserByPhoneAndAccount(phone, accountID string) (*User, error) {
 	return &users[0], nil
 }
 
+// GetUsersByPhone retrieves every account's user for a phone, most recently active first
+func (s *supabaseStore) GetUsersByPhone(phone string) ([]User, error) {
+	var users []User
+	_, err := s.client.From("users").
+		Select("*", "", false).
+		Eq("phone", phone).
+		Order("last_user_message_at", &postgrest.OrderOpts{Ascending: false, NullsFirst: false}).
+		ExecuteTo(&users)
+	return users, err
+}
+
 // GetUserByID retrieves a user by ID
-func GetUserByID(id string) (*User, error) {
+func (s *supabaseStore) GetUserByID(id string) (*User, error) {
 	var users []User
-	_, err := Client.From("users").Select("*", "", false).Eq("id", id).ExecuteTo(&users)
+	_, err := s.client.From("users").Select("*", "", false).Eq("id", id).ExecuteTo(&users)
 	if err != nil {
 		return nil, err
 	}
@@ -93,13 +84,13 @@ func GetUserByID(id string) (*User, error) {
 }
 
 // CreateUser creates a new user
-func CreateUser(phone string, name *string) (*User, error) {
+func (s *supabaseStore) CreateUser(phone string, name *string) (*User, error) {
 	user := map[string]interface{}{
 		"phone": phone,
 		"name":  name,
 	}
 	var result []User
-	_, err := Client.From("users").Insert(user, false, "", "", "").ExecuteTo(&result)
+	_, err := s.client.From("users").Insert(user, false, "", "", "").ExecuteTo(&result)
 	if err != nil {
 		return nil, err
 	}
@@ -110,14 +101,14 @@ func CreateUser(phone string, name *string) (*User, error) {
 }
 
 // CreateUserWithAccount creates a new user linked to an account
-func CreateUserWithAccount(phone string, name *string, accountID string) (*User, error) {
+func (s *supabaseStore) CreateUserWithAccount(phone string, name *string, accountID string) (*User, error) {
 	user := map[string]interface{}{
 		"phone":      phone,
 		"name":       name,
 		"account_id": accountID,
 	}
 	var result []User
-	_, err := Client.From("users").Insert(user, false, "", "", "").ExecuteTo(&result)
+	_, err := s.client.From("users").Insert(user, false, "", "", "").ExecuteTo(&result)
 	if err != nil {
 		return nil, err
 	}
@@ -128,14 +119,14 @@ func CreateUserWithAccount(phone string, name *string, accountID string) (*User,
 }
 
 // UpdateUser updates a user
-func UpdateUser(id string, updates map[string]interface{}) error {
+func (s *supabaseStore) UpdateUser(id string, updates map[string]interface{}) error {
 	var result []User
-	_, err := Client.From("users").Update(updates, "", "").Eq("id", id).ExecuteTo(&result)
+	_, err := s.client.From("users").Update(updates, "", "").Eq("id", id).ExecuteTo(&result)
 	return err
 }
 
 // LogMessage logs a message to the database
-func LogMessage(userID, direction, msgType, content string, waMessageID *string) error {
+func (s *supabaseStore) LogMessage(userID, direction, msgType, content string, waMessageID *string) error {
 	msg := map[string]interface{}{
 		"user_id":       userID,
 		"direction":     direction,
@@ -144,12 +135,12 @@ func LogMessage(userID, direction, msgType, content string, waMessageID *string)
 		"wa_message_id": waMessageID,
 	}
 	var result []Message
-	_, err := Client.From("messages").Insert(msg, false, "", "", "").ExecuteTo(&result)
+	_, err := s.client.From("messages").Insert(msg, false, "", "", "").ExecuteTo(&result)
 	return err
 }
 
 // LogMessageWithAccount logs a message with account_id
-func LogMessageWithAccount(userID, accountID, direction, msgType, content string, waMessageID *string) error {
+func (s *supabaseStore) LogMessageWithAccount(userID, accountID, direction, msgType, content string, waMessageID *string) error {
 	msg := map[string]interface{}{
 		"user_id":       userID,
 		"account_id":    accountID,
@@ -159,14 +150,55 @@ func LogMessageWithAccount(userID, accountID, direction, msgType, content string
 		"wa_message_id": waMessageID,
 	}
 	var result []Message
-	_, err := Client.From("messages").Insert(msg, false, "", "", "").ExecuteTo(&result)
+	_, err := s.client.From("messages").Insert(msg, false, "", "", "").ExecuteTo(&result)
+	return err
+}
+
+// CreateMessage logs a fully described message
+func (s *supabaseStore) CreateMessage(m *Message) error {
+	msg := map[string]interface{}{
+		"user_id":       m.UserID,
+		"account_id":    m.AccountID,
+		"direction":     m.Direction,
+		"message_type":  m.MessageType,
+		"content":       m.Content,
+		"wa_message_id": m.WAMessageID,
+		"media_type":    m.MediaType,
+		"media_id":      m.MediaID,
+	}
+	if m.Status != "" {
+		msg["status"] = m.Status
+	}
+	var result []Message
+	_, err := s.client.From("messages").Insert(msg, false, "", "", "").ExecuteTo(&result)
+	if err == nil && len(result) > 0 {
+		*m = result[0]
+	}
+	return err
+}
+
+// UpdateMessageStatus moves outgoing messages forward to a delivery status
+func (s *supabaseStore) UpdateMessageStatus(waMessageIDs []string, status string) error {
+	from, ok := statusPredecessors[status]
+	if !ok {
+		return fmt.Errorf("unknown message status %q", status)
+	}
+	if len(waMessageIDs) == 0 {
+		return nil
+	}
+	_, _, err := s.client.From("messages").
+		Update(map[string]interface{}{"status": status}, "minimal", "").
+		In("wa_message_id", waMessageIDs).
+		Eq("direction", "outgoing").
+		In("status", from).
+		Execute()
 	return err
 }
 
 // GetMessages retrieves messages with pagination
-func GetMessages(limit, offset int) ([]Message, error) {
+func (s *supabaseStore) GetMessages(limit, offset int) ([]Message, error) {
 	var messages []Message
-	_, err := Client.From("messages").
+	_, err := s.client.From("messages").
 		Select("*", "", false).
 		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
 		Range(offset, offset+limit-1, "").
@@ -175,9 +207,9 @@ func GetMessages(limit, offset int) ([]Message, error) {
 }
 
 // GetMessagesByAccount retrieves messages for a specific account
-func GetMessagesByAccount(accountID string, limit, offset int) ([]Message, error) {
+func (s *supabaseStore) GetMessagesByAccount(accountID string, limit, offset int) ([]Message, error) {
 	var messages []Message
-	_, err := Client.From("messages").
+	_, err := s.client.From("messages").
 		Select("*", "", false).
 		Eq("account_id", accountID).
 		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
@@ -187,9 +219,9 @@ func GetMessagesByAccount(accountID string, limit, offset int) ([]Message, error
 }
 
 // GetMessagesByUser retrieves messages for a specific user
-func GetMessagesByUser(userID string, limit, offset int) ([]Message, error) {
+func (s *supabaseStore) GetMessagesByUser(userID string, limit, offset int) ([]Message, error) {
 	var messages []Message
-	_, err := Client.From("messages").
+	_, err := s.client.From("messages").
 		Select("*", "", false).
 		Eq("user_id", userID).
 		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
@@ -198,10 +230,33 @@ func GetMessagesByUser(userID string, limit, offset int) ([]Message, error) {
 	return messages, err
 }
 
+// CountOutgoingByType counts the messages sent to a phone since a time, by message type
+func (s *supabaseStore) CountOutgoingByType(phone, since string) (map[string]int, error) {
+	var rows []struct {
+		MessageType string `json:"message_type"`
+	}
+	_, err := s.client.From("messages").
+		Select("message_type,users!inner(phone)", "", false).
+		Eq("users.phone", phone).
+		Eq("direction", "outgoing").
+		Neq("status", "failed").
+		Gte("created_at", since).
+		ExecuteTo(&rows)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+	for _, r := range rows {
+		counts[r.MessageType]++
+	}
+	return counts, nil
+}
+
 // GetUsers retrieves all users
-func GetUsers() ([]User, error) {
+func (s *supabaseStore) GetUsers() ([]User, error) {
 	var users []User
-	_, err := Client.From("users").
+	_, err := s.client.From("users").
 		Select("*", "", false).
 		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
 		ExecuteTo(&users)
@@ -209,12 +264,551 @@ func GetUsers() ([]User, error) {
 }
 
 // GetUsersByAccount retrieves users for a specific account
-func GetUsersByAccount(accountID string) ([]User, error) {
+func (s *supabaseStore) GetUsersByAccount(accountID string) ([]User, error) {
 	var users []User
-	_, err := Client.From("users").
+	_, err := s.client.From("users").
 		Select("*", "", false).
 		Eq("account_id", accountID).
 		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
 		ExecuteTo(&users)
 	return users, err
 }
+
+// ========== MEDIA ==========
+
+// CreateMediaFile records a stored media file
+func (s *supabaseStore) CreateMediaFile(m *MediaFile) error {
+	prepareMediaFile(m)
+	var result []MediaFile
+	_, err := s.client.From("media_files").Insert(m, false, "", "", "").ExecuteTo(&result)
+	return err
+}
+
+// GetMediaFile returns a media file by ID
+func (s *supabaseStore) GetMediaFile(id string) (*MediaFile, error) {
+	var files []MediaFile
+	_, err := s.client.From("media_files").Select("*", "", false).Eq("id", id).ExecuteTo(&files)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	return &files[0], nil
+}
+
+// ========== TEMPLATES ==========
+
+// GetTemplates returns all templates
+func (s *supabaseStore) GetTemplates() ([]Template, error) {
+	templates := make([]Template, 0)
+	_, err := s.client.From("templates").
+		Select("*", "", false).
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+		ExecuteTo(&templates)
+	return templates, err
+}
+
+// AddTemplate adds a new template
+func (s *supabaseStore) AddTemplate(name, content string) (Template, error) {
+	t := Template{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Content:   content,
+		CreatedAt: nowString(),
+	}
+	var result []Template
+	_, err := s.client.From("templates").Insert(t, false, "", "", "").ExecuteTo(&result)
+	if err != nil {
+		return Template{}, err
+	}
+	if len(result) > 0 {
+		return result[0], nil
+	}
+	return t, nil
+}
+
+// DeleteTemplate deletes a template
+func (s *supabaseStore) DeleteTemplate(id string) error {
+	_, _, err := s.client.From("templates").Delete("minimal", "").Eq("id", id).Execute()
+	return err
+}
+
+// ========== SCHEDULED ==========
+
+// GetScheduled returns all scheduled messages
+func (s *supabaseStore) GetScheduled() ([]ScheduledMessage, error) {
+	scheduled := make([]ScheduledMessage, 0)
+	_, err := s.client.From("scheduled_messages").
+		Select("*", "", false).
+		Order("scheduled_at", &postgrest.OrderOpts{Ascending: true}).
+		ExecuteTo(&scheduled)
+	return scheduled, err
+}
+
+// AddScheduled adds a new scheduled message
+func (s *supabaseStore) AddScheduled(phone, message, scheduledAt, accountID string) (ScheduledMessage, error) {
+	sm := ScheduledMessage{
+		ID:          uuid.New().String(),
+		Phone:       phone,
+		Message:     message,
+		ScheduledAt: scheduledAt,
+		AccountID:   accountID,
+		Status:      "pending",
+		CreatedAt:   nowString(),
+	}
+	var result []ScheduledMessage
+	_, err := s.client.From("scheduled_messages").Insert(sm, false, "", "", "").ExecuteTo(&result)
+	if err != nil {
+		return ScheduledMessage{}, err
+	}
+	if len(result) > 0 {
+		return result[0], nil
+	}
+	return sm, nil
+}
+
+// DeleteScheduled deletes a scheduled message
+func (s *supabaseStore) DeleteScheduled(id string) error {
+	_, _, err := s.client.From("scheduled_messages").Delete("minimal", "").Eq("id", id).Execute()
+	return err
+}
+
+// UpdateScheduledStatus updates the status of a scheduled message
+func (s *supabaseStore) UpdateScheduledStatus(id, status string) error {
+	_, _, err := s.client.From("scheduled_messages").
+		Update(map[string]interface{}{"status": status}, "minimal", "").
+		Eq("id", id).
+		Execute()
+	return err
+}
+
+// SetScheduledJob marks a scheduled message as queued by the given job
+func (s *supabaseStore) SetScheduledJob(id, jobID string) error {
+	_, _, err := s.client.From("scheduled_messages").
+		Update(map[string]interface{}{"status": "queued", "job_id": jobID}, "minimal", "").
+		Eq("id", id).
+		Execute()
+	return err
+}
+
+// GetQueuedScheduled returns scheduled messages whose job has not finished yet
+func (s *supabaseStore) GetQueuedScheduled() ([]ScheduledMessage, error) {
+	scheduled := make([]ScheduledMessage, 0)
+	_, err := s.client.From("scheduled_messages").
+		Select("*", "", false).
+		Eq("status", "queued").
+		Order("scheduled_at", &postgrest.OrderOpts{Ascending: true}).
+		ExecuteTo(&scheduled)
+	return scheduled, err
+}
+
+// GetPendingScheduled returns scheduled messages that are due
+func (s *supabaseStore) GetPendingScheduled() ([]ScheduledMessage, error) {
+	scheduled := make([]ScheduledMessage, 0)
+	_, err := s.client.From("scheduled_messages").
+		Select("*", "", false).
+		Eq("status", "pending").
+		Lte("scheduled_at", time.Now().UTC().Format(time.RFC3339)).
+		Order("scheduled_at", &postgrest.OrderOpts{Ascending: true}).
+		ExecuteTo(&scheduled)
+	return scheduled, err
+}
+
+// ========== BROADCAST ==========
+
+// GetBroadcasts returns all broadcasts
+func (s *supabaseStore) GetBroadcasts() ([]*Broadcast, error) {
+	broadcasts := make([]*Broadcast, 0)
+	_, err := s.client.From("broadcasts").
+		Select("*", "", false).
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		ExecuteTo(&broadcasts)
+	return broadcasts, err
+}
+
+// GetBroadcast returns a broadcast by ID
+func (s *supabaseStore) GetBroadcast(id string) (*Broadcast, error) {
+	var broadcasts []*Broadcast
+	_, err := s.client.From("broadcasts").Select("*", "", false).Eq("id", id).ExecuteTo(&broadcasts)
+	if err != nil {
+		return nil, err
+	}
+	if len(broadcasts) == 0 {
+		return nil, nil
+	}
+	return broadcasts[0], nil
+}
+
+// CreateBroadcast creates a new broadcast
+func (s *supabaseStore) CreateBroadcast(b *Broadcast) (*Broadcast, error) {
+	prepareBroadcast(b)
+	var result []*Broadcast
+	_, err := s.client.From("broadcasts").Insert(b, false, "", "", "").ExecuteTo(&result)
+	if err != nil {
+		return nil, err
+	}
+	if len(result) > 0 {
+		return result[0], nil
+	}
+	return b, nil
+}
+
+// UpdateBroadcast saves the progress of a broadcast
+func (s *supabaseStore) UpdateBroadcast(id string, p BroadcastProgress) error {
+	reasons := p.SkipReasons
+	if reasons == nil {
+		reasons = map[string]int{}
+	}
+	updates := map[string]interface{}{
+		"sent":         p.Sent,
+		"failed":       p.Failed,
+		"skipped":      p.Skipped,
+		"skip_reasons": reasons,
+		"status":       p.Status,
+		"resume_at":    p.ResumeAt,
+	}
+	_, _, err := s.client.From("broadcasts").Update(updates, "minimal", "").Eq("id", id).Execute()
+	return err
+}
+
+// GetDueBroadcasts returns the broadcasts the scheduler should start or resume
+func (s *supabaseStore) GetDueBroadcasts() ([]*Broadcast, error) {
+	now := nowString()
+	scheduled := make([]*Broadcast, 0)
+	_, err := s.client.From("broadcasts").
+		Select("*", "", false).
+		Eq("status", "pending").
+		Lte("scheduled_at", now).
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+		ExecuteTo(&scheduled)
+	if err != nil {
+		return nil, err
+	}
+
+	waiting := make([]*Broadcast, 0)
+	_, err = s.client.From("broadcasts").
+		Select("*", "", false).
+		Eq("status", "paused").
+		Lte("resume_at", now).
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+		ExecuteTo(&waiting)
+	if err != nil {
+		return nil, err
+	}
+	return append(scheduled, waiting...), nil
+}
+
+// SetBroadcastRecipients replaces the recipients of a broadcast
+func (s *supabaseStore) SetBroadcastRecipients(id string, recipients []string) error {
+	updates := map[string]interface{}{
+		"recipients": recipients,
+		"total":      len(recipients),
+	}
+	_, _, err := s.client.From("broadcasts").Update(updates, "minimal", "").Eq("id", id).Execute()
+	return err
+}
+
+// DeleteBroadcast deletes a broadcast
+func (s *supabaseStore) DeleteBroadcast(id string) error {
+	_, _, err := s.client.From("broadcasts").Delete("minimal", "").Eq("id", id).Execute()
+	return err
+}
+
+// ========== BROADCAST RECIPIENTS ==========
+
+// AddBroadcastRecipients logs recipients as pending after the existing ones
+func (s *supabaseStore) AddBroadcastRecipients(broadcastID string, phones []string) error {
+	var last []BroadcastRecipient
+	_, err := s.client.From("broadcast_recipients").
+		Select("position", "", false).
+		Eq("broadcast_id", broadcastID).
+		Order("position", &postgrest.OrderOpts{Ascending: false}).
+		Limit(1, "").
+		ExecuteTo(&last)
+	if err != nil {
+		return err
+	}
+	next := 0
+	if len(last) > 0 {
+		next = last[0].Position + 1
+	}
+
+	phones = uniquePhones(phones)
+	if len(phones) == 0 {
+		return nil
+	}
+	rows := make([]map[string]interface{}, len(phones))
+	for i, phone := range phones {
+		rows[i] = map[string]interface{}{
+			"broadcast_id": broadcastID,
+			"position":     next + i,
+			"phone":        phone,
+		}
+	}
+	_, _, err = s.client.From("broadcast_recipients").Insert(rows, false, "", "minimal", "").Execute()
+	return err
+}
+
+// GetBroadcastRecipients returns the delivery log of a broadcast in send order
+func (s *supabaseStore) GetBroadcastRecipients(broadcastID string, f RecipientFilter) ([]BroadcastRecipient, error) {
+	recipients := make([]BroadcastRecipient, 0)
+	query := s.client.From("broadcast_recipients").Select("*", "", false).Eq("broadcast_id", broadcastID)
+	if f.Status != "" {
+		query = query.Eq("status", f.Status)
+	}
+	if f.Receipt != "" {
+		query = query.Eq("receipt", f.Receipt)
+	}
+	if f.Phone != "" {
+		query = query.Eq("phone", f.Phone)
+	}
+	query = query.Order("position", &postgrest.OrderOpts{Ascending: true})
+	if f.Limit > 0 {
+		query = query.Range(f.Offset, f.Offset+f.Limit-1, "")
+	}
+	_, err := query.ExecuteTo(&recipients)
+	return recipients, err
+}
+
+// UpdateBroadcastRecipient saves the outcome of sending to a recipient
+func (s *supabaseStore) UpdateBroadcastRecipient(r *BroadcastRecipient) error {
+	updates := map[string]interface{}{
+		"status":        r.Status,
+		"error":         r.Error,
+		"wa_message_id": r.WAMessageID,
+		"sent_at":       r.SentAt,
+		"receipt":       r.Receipt,
+		"receipt_at":    r.ReceiptAt,
+	}
+	_, _, err := s.client.From("broadcast_recipients").Update(updates, "minimal", "").Eq("id", r.ID).Execute()
+	return err
+}
+
+// RequeueFailedRecipients moves the failed recipients of a broadcast back to pending
+func (s *supabaseStore) RequeueFailedRecipients(broadcastID string) (int, error) {
+	updates := map[string]interface{}{
+		"status":        "pending",
+		"error":         "",
+		"wa_message_id": "",
+		"sent_at":       nil,
+		"receipt":       "",
+		"receipt_at":    nil,
+	}
+	var requeued []BroadcastRecipient
+	_, err := s.client.From("broadcast_recipients").
+		Update(updates, "representation", "").
+		Eq("broadcast_id", broadcastID).
+		Eq("status", "failed").
+		ExecuteTo(&requeued)
+	return len(requeued), err
+}
+
+// UpdateRecipientReceipts moves sent broadcast recipients forward to a delivery status
+func (s *supabaseStore) UpdateRecipientReceipts(waMessageIDs []string, receipt string) error {
+	from, ok := statusPredecessors[receipt]
+	if !ok {
+		return fmt.Errorf("unknown message status %q", receipt)
+	}
+	if len(waMessageIDs) == 0 {
+		return nil
+	}
+	_, _, err := s.client.From("broadcast_recipients").
+		Update(map[string]interface{}{"receipt": receipt, "receipt_at": nowString()}, "minimal", "").
+		In("wa_message_id", waMessageIDs).
+		In("receipt", from).
+		Execute()
+	return err
+}
+
+// ========== KEYWORDS ==========
+
+// GetKeywords returns all keyword rules
+func (s *supabaseStore) GetKeywords() ([]Keyword, error) {
+	keywords := make([]Keyword, 0)
+	_, err := s.client.From("keywords").
+		Select("*", "", false).
+		Order("keyword", &postgrest.OrderOpts{Ascending: true}).
+		ExecuteTo(&keywords)
+	return keywords, err
+}
+
+// UpsertKeyword creates or replaces the response for a keyword
+func (s *supabaseStore) UpsertKeyword(k Keyword) (Keyword, error) {
+	row := map[string]interface{}{
+		"account_id": k.AccountID,
+		"keyword":    k.Keyword,
+		"response":   k.Response,
+		"match_mode": k.MatchMode,
+		"priority":   k.Priority,
+	}
+	var result []Keyword
+	_, err := s.client.From("keywords").
+		Insert(row, true, "account_id,keyword", "", "").
+		ExecuteTo(&result)
+	if err != nil {
+		return Keyword{}, err
+	}
+	if len(result) == 0 {
+		return Keyword{}, fmt.Errorf("keyword %q was not saved", k.Keyword)
+	}
+	return result[0], nil
+}
+
+// DeleteKeyword deletes a keyword rule
+func (s *supabaseStore) DeleteKeyword(accountID, keyword string) error {
+	_, _, err := s.client.From("keywords").
+		Delete("minimal", "").
+		Eq("account_id", accountID).
+		Eq("keyword", keyword).
+		Execute()
+	return err
+}
+
+// ========== JOBS ==========
+
+// CreateJob adds a job to the send queue
+func (s *supabaseStore) CreateJob(j *Job) error {
+	prepareJob(j)
+	var result []Job
+	_, err := s.client.From("jobs").Insert(j, false, "", "", "").ExecuteTo(&result)
+	return err
+}
+
+// GetJob returns a job by ID
+func (s *supabaseStore) GetJob(id string) (*Job, error) {
+	var jobs []Job
+	_, err := s.client.From("jobs").Select("*", "", false).Eq("id", id).ExecuteTo(&jobs)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobs) == 0 {
+		return nil, nil
+	}
+	return &jobs[0], nil
+}
+
+// GetJobs returns jobs in the given statuses, newest first
+func (s *supabaseStore) GetJobs(statuses []string, limit int) ([]Job, error) {
+	jobs := make([]Job, 0)
+	query := s.client.From("jobs").Select("*", "", false)
+	if len(statuses) > 0 {
+		query = query.In("status", statuses)
+	}
+	_, err := query.
+		Order("created_at", &postgrest.OrderOpts{Ascending: false}).
+		Limit(limit, "").
+		ExecuteTo(&jobs)
+	return jobs, err
+}
+
+// GetDueJobs returns queued jobs of a lane whose next run time has passed, oldest
+// first: the oldest perAccount jobs of each account (the next_pinned_jobs view),
+// so a backlog on one account never hides the others, and the oldest limit jobs
+// without an account
+func (s *supabaseStore) GetDueJobs(lane string, perAccount, limit int) ([]Job, error) {
+	jobs := make([]Job, 0)
+	_, err := s.client.From("next_pinned_jobs").
+		Select("*", "", false).
+		Eq("lane", lane).
+		Lte("position", strconv.Itoa(perAccount)).
+		ExecuteTo(&jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	var unpinned []Job
+	_, err = s.client.From("jobs").
+		Select("*", "", false).
+		Eq("lane", lane).
+		Eq("status", "queued").
+		Eq("account_id", "").
+		Lte("next_run_at", nowString()).
+		Order("next_run_at", &postgrest.OrderOpts{Ascending: true}).
+		Limit(limit, "").
+		ExecuteTo(&unpinned)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs = append(jobs, unpinned...)
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].NextRunAt < jobs[j].NextRunAt })
+	return jobs, nil
+}
+
+// UpdateJob saves the state of a job
+func (s *supabaseStore) UpdateJob(j *Job) error {
+	j.UpdatedAt = nowString()
+	updates := map[string]interface{}{
+		"account_id":    j.AccountID,
+		"status":        j.Status,
+		"attempts":      j.Attempts,
+		"max_attempts":  j.MaxAttempts,
+		"next_run_at":   j.NextRunAt,
+		"last_error":    j.LastError,
+		"wa_message_id": j.WAMessageID,
+		"updated_at":    j.UpdatedAt,
+	}
+	_, _, err := s.client.From("jobs").Update(updates, "minimal", "").Eq("id", j.ID).Execute()
+	return err
+}
+
+// DeleteJob removes a job from the queue
+func (s *supabaseStore) DeleteJob(id string) error {
+	_, _, err := s.client.From("jobs").Delete("minimal", "").Eq("id", id).Execute()
+	return err
+}
+
+// ========== SETTINGS ==========
+
+// GetSetting returns the settings stored under a key
+func (s *supabaseStore) GetSetting(key string) (*Setting, error) {
+	var settings []Setting
+	_, err := s.client.From("settings").Select("*", "", false).Eq("key", key).ExecuteTo(&settings)
+	if err != nil {
+		return nil, err
+	}
+	if len(settings) == 0 {
+		return nil, nil
+	}
+	return &settings[0], nil
+}
+
+// SaveSetting creates or replaces the settings stored under a key
+func (s *supabaseStore) SaveSetting(key, value string) error {
+	row := Setting{Key: key, Value: value, UpdatedAt: nowString()}
+	_, _, err := s.client.From("settings").Insert(row, true, "key", "minimal", "").Execute()
+	return err
+}
+
+// ========== FLOWS ==========
+
+// GetFlows returns all conversational flows
+func (s *supabaseStore) GetFlows() ([]Flow, error) {
+	flows := make([]Flow, 0)
+	_, err := s.client.From("flows").
+		Select("*", "", false).
+		Order("created_at", &postgrest.OrderOpts{Ascending: true}).
+		ExecuteTo(&flows)
+	return flows, err
+}
+
+// SaveFlow creates or replaces a flow
+func (s *supabaseStore) SaveFlow(f Flow) (Flow, error) {
+	prepareFlow(&f)
+	var result []Flow
+	_, err := s.client.From("flows").Insert(f, true, "id", "", "").ExecuteTo(&result)
+	if err != nil {
+		return Flow{}, err
+	}
+	if len(result) > 0 {
+		return result[0], nil
+	}
+	return f, nil
+}
+
+// DeleteFlow deletes a flow
+func (s *supabaseStore) DeleteFlow(id string) error {
+	_, _, err := s.client.From("flows").Delete("minimal", "").Eq("id", id).Execute()
+	return err
+}
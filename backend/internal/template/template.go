@@ -0,0 +1,149 @@
+package template
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"esther-whatsapp/internal/store"
+)
+
+var (
+	// ErrUnknownPlaceholder is returned when a message uses a placeholder no recipient has a value for
+	ErrUnknownPlaceholder = errors.New("unknown placeholder")
+	// ErrMissingValue is returned when a recipient has no value, and the placeholder no fallback
+	ErrMissingValue = errors.New("missing placeholder value")
+)
+
+// placeholderPattern matches {{key}} and {{key|fallback}}
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*(?:\|([^}]*))?\}\}`)
+
+// Builtins are the placeholders every recipient has, filled from the users table
+var Builtins = []string{"name", "phone", "notes"}
+
+// Placeholder is one {{key}} of a message
+type Placeholder struct {
+	Key         string
+	Fallback    string
+	HasFallback bool
+}
+
+// Placeholders returns the placeholders of a message in order of appearance.
+// Keys are case-insensitive and returned in lowercase.
+func Placeholders(text string) []Placeholder {
+	matches := placeholderPattern.FindAllStringSubmatchIndex(text, -1)
+	placeholders := make([]Placeholder, 0, len(matches))
+	for _, m := range matches {
+		p := Placeholder{Key: strings.ToLower(text[m[2]:m[3]])}
+		if m[4] >= 0 {
+			p.Fallback = strings.TrimSpace(text[m[4]:m[5]])
+			p.HasFallback = true
+		}
+		placeholders = append(placeholders, p)
+	}
+	return placeholders
+}
+
+// Validate checks that every placeholder of a message is a builtin or one of
+// the known custom fields
+func Validate(text string, known map[string]bool) error {
+	var unknown []string
+	seen := make(map[string]bool)
+	for _, p := range Placeholders(text) {
+		if seen[p.Key] || isBuiltin(p.Key) || known[p.Key] {
+			continue
+		}
+		seen[p.Key] = true
+		unknown = append(unknown, "{{"+p.Key+"}}")
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%w: %s", ErrUnknownPlaceholder, strings.Join(unknown, ", "))
+	}
+	return nil
+}
+
+// Render replaces the placeholders of a message with the recipient's values.
+// An empty or missing value uses the placeholder's fallback; without one the
+// message cannot be rendered.
+func Render(text string, values map[string]string) (string, error) {
+	var missing []string
+	rendered := placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		p := Placeholders(match)[0]
+		if v := values[p.Key]; v != "" {
+			return v
+		}
+		if !p.HasFallback {
+			missing = append(missing, "{{"+p.Key+"}}")
+		}
+		return p.Fallback
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("%w: %s", ErrMissingValue, strings.Join(missing, ", "))
+	}
+	return rendered, nil
+}
+
+// Values collects a recipient's placeholder values: the builtins and custom
+// fields of their user, overridden by the supplied data row. user and data may be nil.
+func Values(phone string, user *store.User, data map[string]string) map[string]string {
+	values := map[string]string{"phone": phone}
+	if user != nil {
+		if user.Name != nil {
+			values["name"] = *user.Name
+		}
+		if user.Notes != nil {
+			values["notes"] = *user.Notes
+		}
+		for key, v := range user.Fields {
+			values[strings.ToLower(key)] = v
+		}
+	}
+	for key, v := range data {
+		values[strings.ToLower(key)] = v
+	}
+	return values
+}
+
+// FieldKeys returns the custom field keys of the given users and data rows,
+// the placeholders Validate accepts besides the builtins
+func FieldKeys(users []*store.User, data map[string]map[string]string) map[string]bool {
+	known := make(map[string]bool)
+	for _, user := range users {
+		if user == nil {
+			continue
+		}
+		for key := range user.Fields {
+			known[strings.ToLower(key)] = true
+		}
+	}
+	for _, row := range data {
+		for key := range row {
+			known[strings.ToLower(key)] = true
+		}
+	}
+	return known
+}
+
+// RecipientUser returns the user a message to phone is personalised for: the
+// account's own user when accountID is set, else any user with that phone
+func RecipientUser(phone, accountID string) (*store.User, error) {
+	if accountID != "" {
+		user, err := store.DB.GetUserByPhoneAndAccount(phone, accountID)
+		if err != nil || user != nil {
+			return user, err
+		}
+	}
+	return store.DB.GetUserByPhone(phone)
+}
+
+func isBuiltin(key string) bool {
+	for _, b := range Builtins {
+		if b == key {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package template
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"esther-whatsapp/internal/store"
+)
+
+func TestPlaceholders(t *testing.T) {
+	got := Placeholders("Halo {{ Name }}, kode {{code|-}} untuk {{phone|}}. {{1bad}} {{name")
+	want := []Placeholder{
+		{Key: "name"},
+		{Key: "code", Fallback: "-", HasFallback: true},
+		{Key: "phone", Fallback: "", HasFallback: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Placeholders() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRender(t *testing.T) {
+	values := map[string]string{"name": "Budi", "code": "", "city": "Bandung"}
+	tests := []struct {
+		name    string
+		text    string
+		want    string
+		missing bool
+	}{
+		{"no placeholders", "Halo semua", "Halo semua", false},
+		{"value", "Halo {{name}}", "Halo Budi", false},
+		{"key is case-insensitive", "Halo {{NAME}} dari {{City}}", "Halo Budi dari Bandung", false},
+		{"repeated", "{{name}} {{name}}", "Budi Budi", false},
+		{"fallback unused", "Halo {{name|Kak}}", "Halo Budi", false},
+		{"fallback for missing value", "Halo {{nickname|Kak}}", "Halo Kak", false},
+		{"fallback for empty value", "Kode {{code|-}}", "Kode -", false},
+		{"empty fallback", "Halo{{nickname|}}!", "Halo!", false},
+		{"missing value", "Halo {{nickname}}", "", true},
+		{"empty value", "Kode {{code}}", "", true},
+	}
+	for _, tt := range tests {
+		got, err := Render(tt.text, values)
+		if tt.missing {
+			if !errors.Is(err, ErrMissingValue) {
+				t.Errorf("%s: Render() = %q, %v, want ErrMissingValue", tt.name, got, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s: Render() = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	known := map[string]bool{"plan": true}
+	tests := []struct {
+		text  string
+		valid bool
+	}{
+		{"Halo {{name}}, {{phone}}, {{notes}}", true},
+		{"Paket {{PLAN}}", true},
+		{"Paket {{plan}} sampai {{expiry}}", false},
+		{"Kode {{code|-}}", false}, // A fallback does not make a key known
+	}
+	for _, tt := range tests {
+		err := Validate(tt.text, known)
+		if tt.valid && err != nil {
+			t.Errorf("Validate(%q) = %v, want nil", tt.text, err)
+		}
+		if !tt.valid && !errors.Is(err, ErrUnknownPlaceholder) {
+			t.Errorf("Validate(%q) = %v, want ErrUnknownPlaceholder", tt.text, err)
+		}
+	}
+}
+
+func TestValues(t *testing.T) {
+	name, notes := "Budi", "VIP"
+	user := &store.User{Name: &name, Notes: &notes, Fields: map[string]string{"Plan": "Gold", "city": "Bandung"}}
+	data := map[string]string{"CITY": "Jakarta", "code": "X1"}
+
+	got := Values("628123", user, data)
+	want := map[string]string{
+		"phone": "628123",
+		"name":  "Budi",
+		"notes": "VIP",
+		"plan":  "Gold",
+		"city":  "Jakarta", // The data row wins over the user's fields
+		"code":  "X1",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Values() = %v, want %v", got, want)
+	}
+
+	if got := Values("628123", nil, nil); !reflect.DeepEqual(got, map[string]string{"phone": "628123"}) {
+		t.Fatalf("Values() without user = %v, want only the phone", got)
+	}
+}
+
+func TestFieldKeys(t *testing.T) {
+	users := []*store.User{
+		{Fields: map[string]string{"Plan": "Gold"}},
+		nil,
+		{Fields: map[string]string{"city": "Bandung"}},
+	}
+	data := map[string]map[string]string{"628123": {"CODE": "X1"}}
+
+	want := map[string]bool{"plan": true, "city": true, "code": true}
+	if got := FieldKeys(users, data); !reflect.DeepEqual(got, want) {
+		t.Fatalf("FieldKeys() = %v, want %v", got, want)
+	}
+}
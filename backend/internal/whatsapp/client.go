@@ -71,16 +71,19 @@ func GetNewQRChannel() (<-chan whatsmeow.QRChannelItem, error) {
 	return qrChan, nil
 }
 
-// sendTextMessage sends a text message using a specific client
-func sendTextMessage(client *whatsmeow.Client, to types.JID, text string) error {
+// sendTextMessage sends a text message using a specific client and returns its WhatsApp message ID
+func sendTextMessage(client *whatsmeow.Client, to types.JID, text string) (string, error) {
 	if client == nil {
-		return fmt.Errorf("client is nil")
+		return "", fmt.Errorf("client is nil")
 	}
 
 	msg := &waE2E.Message{
 		Conversation: proto.String(text),
 	}
 
-	_, err := client.SendMessage(context.Background(), to, msg)
-	return err
+	resp, err := client.SendMessage(context.Background(), to, msg)
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
 }
@@ -1,54 +1,35 @@
 package whatsapp
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"strings"
 
 	"esther-whatsapp/internal/config"
+	"esther-whatsapp/internal/flow"
 	"esther-whatsapp/internal/store"
 
 	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
 )
 
-// Keywords and their responses
-var keywordResponses = map[string]string{
-	"info":    "Halo 👋\nSelamat datang! Ini adalah bot informasi Esther.\n\nKetik:\n• *jadwal* - Lihat jadwal\n• *bantuan* - Hubungi CS",
-	"jadwal":  "📅 Jadwal operasional:\nSenin - Sabtu: 08.00 - 20.00 WIB",
-	"bantuan": "🙋 Tim CS kami akan segera menghubungi Anda.\nTerima kasih telah menunggu.",
-	"stop":    "✅ Anda telah berhenti berlangganan notifikasi.\nKetik *start* untuk berlangganan kembali.",
-	"start":   "✅ Anda telah berlangganan notifikasi.\nKetik *stop* untuk berhenti.",
-}
-
-// AddKeyword adds a new keyword response
-func AddKeyword(keyword, response string) {
-	keywordResponses[strings.ToLower(keyword)] = response
-}
-
-// RemoveKeyword removes a keyword
-func RemoveKeyword(keyword string) {
-	delete(keywordResponses, strings.ToLower(keyword))
-}
-
-// GetKeywords returns all keywords
-func GetKeywords() map[string]string {
-	return keywordResponses
-}
-
 // handleAccountEvent handles events for a specific account
 func handleAccountEvent(account *Account, evt interface{}) {
 	switch v := evt.(type) {
 	case *events.Message:
 		handleAccountMessage(account, v)
+	case *events.Receipt:
+		handleReceipt(account, v)
 	case *events.Connected:
 		log.Printf("✅ Account %s (%s) connected!", account.ID, account.Name)
-		account.IsConnected = true
+		Manager.setConnected(account, true)
 	case *events.Disconnected:
 		log.Printf("❌ Account %s (%s) disconnected", account.ID, account.Name)
-		account.IsConnected = false
+		Manager.setConnected(account, false)
 	case *events.LoggedOut:
 		log.Printf("⚠️ Account %s (%s) logged out", account.ID, account.Name)
-		account.IsLoggedIn = false
+		Manager.setLoggedOut(account)
 	}
 }
 
@@ -58,29 +39,29 @@ func handleAccountMessage(account *Account, msg *events.Message) {
 	senderJID := msg.Info.Sender
 	phone := senderJID.User
 
-	// Get message text
-	var text string
-	if msg.Message.GetConversation() != "" {
-		text = msg.Message.GetConversation()
-	} else if msg.Message.GetExtendedTextMessage() != nil {
-		text = msg.Message.GetExtendedTextMessage().GetText()
-	}
+	// Get message text and attachment
+	text := messageText(msg.Message)
+	incoming := extractMedia(msg.Message)
 
-	if text == "" {
-		return // Ignore non-text messages
+	if text == "" && incoming == nil {
+		return // Ignore reactions, protocol messages, etc.
 	}
 
-	log.Printf("📨 [%s] Incoming from %s: %s", account.Name, phone, text)
+	if incoming != nil {
+		log.Printf("📎 [%s] Incoming %s from %s", account.Name, incoming.kind, phone)
+	} else {
+		log.Printf("📨 [%s] Incoming from %s: %s", account.Name, phone, text)
+	}
 
 	// Get or create user (linked to account)
-	user, err := store.GetUserByPhoneAndAccount(phone, account.ID)
+	user, err := store.DB.GetUserByPhoneAndAccount(phone, account.ID)
 	if err != nil {
 		log.Printf("Error getting user: %v", err)
 	}
 
 	if user == nil {
 		// Create new user linked to this account
-		user, err = store.CreateUserWithAccount(phone, nil, account.ID)
+		user, err = store.DB.CreateUserWithAccount(phone, nil, account.ID)
 		if err != nil {
 			log.Printf("Error creating user: %v", err)
 			return
@@ -89,7 +70,7 @@ func handleAccountMessage(account *Account, msg *events.Message) {
 
 	// Update last_user_message_at
 	if user != nil {
-		store.UpdateUser(user.ID, map[string]interface{}{
+		store.DB.UpdateUser(user.ID, map[string]interface{}{
 			"last_user_message_at": "now()",
 		})
 	}
@@ -97,24 +78,33 @@ func handleAccountMessage(account *Account, msg *events.Message) {
 	// Log incoming message with account_id
 	if user != nil {
 		waID := msg.Info.ID
-		store.LogMessageWithAccount(user.ID, account.ID, "incoming", "user", text, &waID)
+		if incoming != nil {
+			logIncomingMedia(account, user, incoming, waID)
+		} else {
+			store.DB.LogMessageWithAccount(user.ID, account.ID, "incoming", "user", text, &waID)
+		}
+	}
+
+	// Only text messages trigger replies
+	if text == "" {
+		return
 	}
 
+	// Settings of this account, falling back to the global ones
+	settings := config.SettingsFor(account.ID)
+
 	// Check if auto-reply is enabled
-	if !config.Settings.IsAutoReplyEnabled() {
+	if !settings.IsAutoReplyEnabled() {
 		log.Println("⏸️ Auto-reply is disabled, skipping response")
 		return
 	}
 
 	// Check if we should send away message (outside operating hours)
-	if config.Settings.ShouldSendAwayMessage() {
-		awayMsg := config.Settings.GetAwayMessage()
+	if settings.ShouldSendAwayMessage() {
+		awayMsg := settings.GetAwayMessage()
 		log.Printf("🌙 Outside operating hours, sending away message to %s", phone)
-		err := sendTextMessage(account.client, msg.Info.Chat, awayMsg)
-		if err != nil {
+		if err := replyTo(account, phone, msg.Info.Chat, "away", awayMsg); err != nil {
 			log.Printf("Error sending away message: %v", err)
-		} else if user != nil {
-			store.LogMessageWithAccount(user.ID, account.ID, "outgoing", "away", awayMsg, nil)
 		}
 		return // Don't process keywords when outside operating hours
 	}
@@ -124,26 +114,144 @@ func handleAccountMessage(account *Account, msg *events.Message) {
 
 	// Handle stop/start special commands
 	if keyword == "stop" && user != nil {
-		store.UpdateUser(user.ID, map[string]interface{}{
+		store.DB.UpdateUser(user.ID, map[string]interface{}{
 			"opt_in": false,
 		})
 	} else if keyword == "start" && user != nil {
-		store.UpdateUser(user.ID, map[string]interface{}{
+		store.DB.UpdateUser(user.ID, map[string]interface{}{
 			"opt_in": true,
 		})
 	}
 
+	// Conversational flows take precedence over single keyword replies
+	if reply, handled := flow.Default.Handle(account.ID, phone, text); handled {
+		if err := replyTo(account, phone, msg.Info.Chat, "reply", reply); err != nil {
+			log.Printf("Error sending flow reply: %v", err)
+		}
+		return
+	}
+
 	// Send response if keyword matches
-	if response, ok := keywordResponses[keyword]; ok {
-		err := sendTextMessage(account.client, msg.Info.Chat, response)
-		if err != nil {
+	if response, ok := lookupKeyword(account.ID, keyword); ok {
+		if err := replyTo(account, phone, msg.Info.Chat, "reply", response); err != nil {
 			log.Printf("Error sending response: %v", err)
-		} else if user != nil {
-			store.LogMessageWithAccount(user.ID, account.ID, "outgoing", "reply", response, nil)
 		}
 	}
 }
 
+// logIncomingMedia stores a received attachment and logs it in the user's history.
+// The message is logged even if the download fails, so it is never lost silently.
+func logIncomingMedia(account *Account, user *store.User, in *incomingMedia, waID string) {
+	kind := in.kind
+	content := in.caption
+	entry := &store.Message{
+		UserID:      user.ID,
+		AccountID:   &account.ID,
+		Direction:   "incoming",
+		MessageType: "user",
+		Content:     &content,
+		WAMessageID: &waID,
+		MediaType:   &kind,
+	}
+
+	file, err := storeIncomingMedia(account, in)
+	if err != nil {
+		log.Printf("❌ Failed to store incoming %s: %v", in.kind, err)
+	} else if file != nil {
+		entry.MediaID = &file.ID
+	}
+
+	if err := store.DB.CreateMessage(entry); err != nil {
+		log.Printf("Error logging message: %v", err)
+	}
+}
+
+// EnqueueReply queues a reply into a chat on the queue's reply lane, sent and
+// logged by a queue worker. The queue sets it, so the event handler never
+// waits for a send.
+var EnqueueReply func(accountID, phone, chat, msgType, text string) error
+
+// replyTo answers a user from the account they wrote to, in the chat they
+// wrote in: a group, or the linked-ID address they wrote from
+func replyTo(account *Account, phone string, chat types.JID, msgType, text string) error {
+	if EnqueueReply == nil {
+		return errors.New("reply queue is not running")
+	}
+	return EnqueueReply(account.ID, phone, chat.String(), msgType, text)
+}
+
+// findOrCreateUser returns the user of a phone on an account, creating it if needed
+func findOrCreateUser(phone, accountID string) (*store.User, error) {
+	user, err := store.DB.GetUserByPhoneAndAccount(phone, accountID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		user, err = store.DB.CreateUserWithAccount(phone, nil, accountID)
+		if err != nil {
+			return nil, err
+		}
+		if user == nil {
+			return nil, fmt.Errorf("user %s was not created", phone)
+		}
+	}
+	return user, nil
+}
+
+// logOutgoing records a message sent to a user; a send error marks it as failed
+func logOutgoing(user *store.User, accountID, msgType, text string, media *Media, waID string, sendErr error) {
+	entry := &store.Message{
+		UserID:      user.ID,
+		AccountID:   &accountID,
+		Direction:   "outgoing",
+		MessageType: msgType,
+		Content:     &text,
+		Status:      "sent",
+	}
+	if waID != "" {
+		entry.WAMessageID = &waID
+	}
+	if sendErr != nil && waID == "" {
+		entry.Status = "failed"
+	}
+	if media != nil {
+		kind := media.Kind()
+		entry.MediaType = &kind
+		if text == "" {
+			entry.Content = &media.FileName
+		}
+	}
+	if err := store.DB.CreateMessage(entry); err != nil {
+		log.Printf("Error logging message: %v", err)
+	}
+}
+
+// handleReceipt moves outgoing messages and broadcast recipients to delivered, read or failed
+func handleReceipt(account *Account, receipt *events.Receipt) {
+	var status string
+	switch receipt.Type {
+	case types.ReceiptTypeDelivered:
+		status = "delivered"
+	case types.ReceiptTypeRead, types.ReceiptTypePlayed:
+		status = "read"
+	case types.ReceiptTypeServerError:
+		status = "failed"
+	default:
+		return // Receipts from our own devices, retries, etc.
+	}
+
+	ids := make([]string, len(receipt.MessageIDs))
+	for i, id := range receipt.MessageIDs {
+		ids[i] = string(id)
+	}
+	if err := store.DB.UpdateMessageStatus(ids, status); err != nil {
+		log.Printf("Error updating message status for account %s: %v", account.ID, err)
+	}
+	if err := store.DB.UpdateRecipientReceipts(ids, status); err != nil {
+		log.Printf("Error updating broadcast receipts for account %s: %v", account.ID, err)
+	}
+}
+
 // ParseJID parses a phone number into a JID
 func ParseJID(phone string) types.JID {
 	return types.NewJID(phone, types.DefaultUserServer)
@@ -166,13 +274,8 @@ func Disconnect() {
 }
 
 func IsConnected() bool {
-	accounts := Manager.ListAccounts()
-	for _, acc := range accounts {
-		if acc.IsConnected {
-			return true
-		}
-	}
-	return false
+	Manager.ListAccounts() // Refresh the status from the clients
+	return Manager.AnyConnected()
 }
 
 func IsLoggedIn() bool {
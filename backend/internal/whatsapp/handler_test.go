@@ -0,0 +1,92 @@
+package whatsapp
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"esther-whatsapp/internal/store"
+
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+func TestRepliesAreQueued(t *testing.T) {
+	account := &Account{ID: "acc-a"}
+	chat := types.NewJID("207613512", types.HiddenUserServer) // Sender addressed by linked ID
+	previous := EnqueueReply
+	defer func() { EnqueueReply = previous }()
+
+	EnqueueReply = nil
+	if err := replyTo(account, "62811", chat, "reply", "Halo"); err == nil {
+		t.Fatal("replyTo() without a queue succeeded, want an error")
+	}
+
+	var got []string
+	EnqueueReply = func(accountID, phone, chat, msgType, text string) error {
+		got = []string{accountID, phone, chat, msgType, text}
+		return nil
+	}
+	if err := replyTo(account, "62811", chat, "away", "Tutup"); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"acc-a", "62811", "207613512@lid", "away", "Tutup"}
+	for i := range want {
+		if len(got) != len(want) || got[i] != want[i] {
+			t.Fatalf("queued %v, want %v", got, want)
+		}
+	}
+}
+
+// sentMessage logs an outgoing message with a WhatsApp ID for a new user
+func sentMessage(t *testing.T, waID string) *store.User {
+	t.Helper()
+	user, err := store.DB.CreateUserWithAccount(fmt.Sprintf("62%d", time.Now().UnixNano()%1e10), nil, "acc-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accountID := "acc-a"
+	m := &store.Message{UserID: user.ID, AccountID: &accountID, Direction: "outgoing", MessageType: "manual", Status: "sent", WAMessageID: &waID}
+	if err := store.DB.CreateMessage(m); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func statusOf(t *testing.T, user *store.User) string {
+	t.Helper()
+	messages, err := store.DB.GetMessagesByUser(user.ID, 1, 0)
+	if err != nil || len(messages) != 1 {
+		t.Fatalf("GetMessagesByUser() = %v, %v", messages, err)
+	}
+	return messages[0].Status
+}
+
+func TestReceiptsMoveMessagesForwardOnly(t *testing.T) {
+	account := &Account{ID: "acc-a"}
+	receipt := func(waID string, typ types.ReceiptType) {
+		handleReceipt(account, &events.Receipt{MessageIDs: []types.MessageID{waID}, Type: typ})
+	}
+	prefix := fmt.Sprint(time.Now().UnixNano())
+
+	delivered := sentMessage(t, prefix+"-1")
+	receipt(prefix+"-1", types.ReceiptTypeDelivered)
+	if got := statusOf(t, delivered); got != "delivered" {
+		t.Fatalf("after a delivery receipt: %s, want delivered", got)
+	}
+
+	// Read arrives before delivered: the late delivery does not move it back
+	read := sentMessage(t, prefix+"-2")
+	receipt(prefix+"-2", types.ReceiptTypeRead)
+	receipt(prefix+"-2", types.ReceiptTypeDelivered)
+	if got := statusOf(t, read); got != "read" {
+		t.Fatalf("after read then delivered: %s, want read", got)
+	}
+
+	// Receipts of other kinds change nothing
+	other := sentMessage(t, prefix+"-3")
+	receipt(prefix+"-3", types.ReceiptTypeSender)
+	if got := statusOf(t, other); got != "sent" {
+		t.Fatalf("after a sender receipt: %s, want sent", got)
+	}
+}
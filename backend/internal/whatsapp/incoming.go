@@ -0,0 +1,86 @@
+package whatsapp
+
+import (
+	"context"
+	"fmt"
+	"mime"
+
+	"esther-whatsapp/internal/store"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+)
+
+// incomingMedia is the media part of a received message
+type incomingMedia struct {
+	kind     string
+	mimeType string
+	fileName string
+	caption  string
+	file     whatsmeow.DownloadableMessage // nil when there is nothing to download
+}
+
+// messageText returns the plain text of a message, empty for anything else
+func messageText(msg *waE2E.Message) string {
+	if msg.GetConversation() != "" {
+		return msg.GetConversation()
+	}
+	if msg.GetExtendedTextMessage() != nil {
+		return msg.GetExtendedTextMessage().GetText()
+	}
+	return ""
+}
+
+// extractMedia returns the media carried by a message, or nil for text messages
+func extractMedia(msg *waE2E.Message) *incomingMedia {
+	switch {
+	case msg.GetImageMessage() != nil:
+		m := msg.GetImageMessage()
+		return &incomingMedia{kind: MediaImage, mimeType: m.GetMimetype(), caption: m.GetCaption(), file: m}
+	case msg.GetVideoMessage() != nil:
+		m := msg.GetVideoMessage()
+		return &incomingMedia{kind: MediaVideo, mimeType: m.GetMimetype(), caption: m.GetCaption(), file: m}
+	case msg.GetAudioMessage() != nil:
+		m := msg.GetAudioMessage()
+		return &incomingMedia{kind: MediaAudio, mimeType: m.GetMimetype(), file: m}
+	case msg.GetDocumentMessage() != nil:
+		m := msg.GetDocumentMessage()
+		return &incomingMedia{kind: MediaDocument, mimeType: m.GetMimetype(), fileName: m.GetFileName(), caption: m.GetCaption(), file: m}
+	case msg.GetStickerMessage() != nil:
+		m := msg.GetStickerMessage()
+		return &incomingMedia{kind: MediaSticker, mimeType: m.GetMimetype(), file: m}
+	case msg.GetLocationMessage() != nil:
+		m := msg.GetLocationMessage()
+		caption := fmt.Sprintf("📍 %.6f,%.6f", m.GetDegreesLatitude(), m.GetDegreesLongitude())
+		if m.GetName() != "" {
+			caption += " " + m.GetName()
+		}
+		if m.GetAddress() != "" {
+			caption += " - " + m.GetAddress()
+		}
+		return &incomingMedia{kind: MediaLocation, caption: caption}
+	}
+	return nil
+}
+
+// storeIncomingMedia downloads a received file and saves it to media storage
+func storeIncomingMedia(account *Account, in *incomingMedia) (*store.MediaFile, error) {
+	if in.file == nil {
+		return nil, nil
+	}
+
+	data, err := account.client.Download(context.Background(), in.file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", in.kind, err)
+	}
+
+	name := in.fileName
+	if name == "" {
+		name = in.kind
+		if exts, _ := mime.ExtensionsByType(in.mimeType); len(exts) > 0 {
+			name += exts[0]
+		}
+	}
+
+	return saveMediaFile(account.ID, in.kind, in.mimeType, name, in.caption, data)
+}
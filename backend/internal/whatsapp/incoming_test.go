@@ -0,0 +1,72 @@
+package whatsapp
+
+import (
+	"testing"
+
+	"esther-whatsapp/internal/media"
+
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestExtractMedia(t *testing.T) {
+	tests := []struct {
+		name    string
+		msg     *waE2E.Message
+		kind    string
+		caption string
+		file    string
+	}{
+		{"image", &waE2E.Message{ImageMessage: &waE2E.ImageMessage{Mimetype: proto.String("image/jpeg"), Caption: proto.String("Bukti")}}, MediaImage, "Bukti", ""},
+		{"voice note", &waE2E.Message{AudioMessage: &waE2E.AudioMessage{Mimetype: proto.String("audio/ogg")}}, MediaAudio, "", ""},
+		{"document", &waE2E.Message{DocumentMessage: &waE2E.DocumentMessage{Mimetype: proto.String("application/pdf"), FileName: proto.String("invoice.pdf")}}, MediaDocument, "", "invoice.pdf"},
+		{"location", &waE2E.Message{LocationMessage: &waE2E.LocationMessage{
+			DegreesLatitude: proto.Float64(-6.2), DegreesLongitude: proto.Float64(106.816666), Name: proto.String("Monas"),
+		}}, MediaLocation, "📍 -6.200000,106.816666 Monas", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := extractMedia(tt.msg)
+			if in == nil || in.kind != tt.kind || in.caption != tt.caption || in.fileName != tt.file {
+				t.Fatalf("extractMedia() = %+v, want %s %q %q", in, tt.kind, tt.caption, tt.file)
+			}
+			if (in.file == nil) != (tt.kind == MediaLocation) {
+				t.Fatalf("extractMedia() file = %v, want a download for everything but locations", in.file)
+			}
+		})
+	}
+
+	if in := extractMedia(&waE2E.Message{Conversation: proto.String("halo")}); in != nil {
+		t.Fatalf("extractMedia() of a text = %+v, want nil", in)
+	}
+}
+
+func TestStoredMediaRoundTrip(t *testing.T) {
+	storage, err := media.NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous := media.Default
+	media.Default = storage
+	defer func() { media.Default = previous }()
+
+	m, err := NewMedia([]byte("%PDF-1.4"), "price.pdf", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := SaveMedia("acc-a", m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.MediaType != MediaDocument || file.Size != 8 || file.AccountID != "acc-a" {
+		t.Fatalf("SaveMedia() = %+v", file)
+	}
+
+	loaded, err := LoadStoredMedia(file.ID, "Daftar harga")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(loaded.Data) != "%PDF-1.4" || loaded.FileName != "price.pdf" || loaded.Caption != "Daftar harga" {
+		t.Fatalf("LoadStoredMedia() = %+v", loaded)
+	}
+}
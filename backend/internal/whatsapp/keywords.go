@@ -0,0 +1,300 @@
+package whatsapp
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"esther-whatsapp/internal/store"
+)
+
+// Keyword match modes
+const (
+	MatchExact    = "exact"
+	MatchPrefix   = "prefix"
+	MatchContains = "contains"
+	MatchRegex    = "regex"
+	MatchFuzzy    = "fuzzy"
+)
+
+// matchModeRank orders rules of equal priority from most to least specific
+var matchModeRank = map[string]int{
+	MatchExact:    0,
+	MatchPrefix:   1,
+	MatchContains: 2,
+	MatchRegex:    3,
+	MatchFuzzy:    4,
+}
+
+// ErrInvalidKeyword is returned when a keyword rule cannot be used
+var ErrInvalidKeyword = errors.New("invalid keyword")
+
+// keywordRule is a stored keyword with its compiled pattern
+type keywordRule struct {
+	kw store.Keyword
+	re *regexp.Regexp
+}
+
+// keywordBook caches keyword rules from the store, indexed by account ID.
+// Global rules live under the empty account ID.
+type keywordBook struct {
+	byAccount map[string]map[string]*keywordRule
+	mu        sync.RWMutex
+}
+
+var keywords = &keywordBook{
+	byAccount: make(map[string]map[string]*keywordRule),
+}
+
+// compileKeyword validates a rule and compiles its regex if needed
+func compileKeyword(k store.Keyword) (*keywordRule, error) {
+	if _, ok := matchModeRank[k.MatchMode]; !ok {
+		return nil, fmt.Errorf("%w: unknown match mode %q", ErrInvalidKeyword, k.MatchMode)
+	}
+	if k.Keyword == "" {
+		return nil, fmt.Errorf("%w: keyword is empty", ErrInvalidKeyword)
+	}
+
+	rule := &keywordRule{kw: k}
+	if k.MatchMode == MatchRegex {
+		re, err := regexp.Compile("(?i)" + k.Keyword)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidKeyword, err)
+		}
+		rule.re = re
+	}
+	return rule, nil
+}
+
+// normalizeKeyword lower-cases plain keywords; regex patterns are kept verbatim
+func normalizeKeyword(keyword, mode string) string {
+	keyword = strings.TrimSpace(keyword)
+	if mode == MatchRegex {
+		return keyword
+	}
+	return strings.ToLower(keyword)
+}
+
+// LoadKeywords loads all keyword rules from the store into memory
+func LoadKeywords() error {
+	stored, err := store.DB.GetKeywords()
+	if err != nil {
+		return err
+	}
+
+	byAccount := make(map[string]map[string]*keywordRule)
+	for _, k := range stored {
+		if k.MatchMode == "" {
+			k.MatchMode = MatchExact
+		}
+		rule, err := compileKeyword(k)
+		if err != nil {
+			return fmt.Errorf("keyword %q: %w", k.Keyword, err)
+		}
+		if byAccount[k.AccountID] == nil {
+			byAccount[k.AccountID] = make(map[string]*keywordRule)
+		}
+		byAccount[k.AccountID][k.Keyword] = rule
+	}
+
+	keywords.mu.Lock()
+	keywords.byAccount = byAccount
+	keywords.mu.Unlock()
+	return nil
+}
+
+// AddKeyword adds or replaces a keyword rule.
+// An empty AccountID makes the keyword apply to every account.
+func AddKeyword(k store.Keyword) (store.Keyword, error) {
+	if k.MatchMode == "" {
+		k.MatchMode = MatchExact
+	}
+	k.Keyword = normalizeKeyword(k.Keyword, k.MatchMode)
+
+	if _, err := compileKeyword(k); err != nil {
+		return store.Keyword{}, err
+	}
+
+	saved, err := store.DB.UpsertKeyword(k)
+	if err != nil {
+		return store.Keyword{}, err
+	}
+	rule, err := compileKeyword(saved)
+	if err != nil {
+		return store.Keyword{}, err
+	}
+
+	keywords.mu.Lock()
+	defer keywords.mu.Unlock()
+	if keywords.byAccount[saved.AccountID] == nil {
+		keywords.byAccount[saved.AccountID] = make(map[string]*keywordRule)
+	}
+	keywords.byAccount[saved.AccountID][saved.Keyword] = rule
+	return saved, nil
+}
+
+// RemoveKeyword removes a keyword from the given account (or the global set)
+func RemoveKeyword(accountID, keyword string) error {
+	keywords.mu.RLock()
+	_, exact := keywords.byAccount[accountID][keyword]
+	keywords.mu.RUnlock()
+
+	// Plain keywords are stored lower-cased, regex patterns verbatim
+	if !exact {
+		keyword = strings.ToLower(strings.TrimSpace(keyword))
+	}
+
+	if err := store.DB.DeleteKeyword(accountID, keyword); err != nil {
+		return err
+	}
+
+	keywords.mu.Lock()
+	defer keywords.mu.Unlock()
+	delete(keywords.byAccount[accountID], keyword)
+	return nil
+}
+
+// GetKeywords returns the keywords visible to an account in evaluation order:
+// its own rules plus the global ones it does not override.
+// An empty accountID returns every rule.
+func GetKeywords(accountID string) []store.Keyword {
+	keywords.mu.RLock()
+	defer keywords.mu.RUnlock()
+
+	var rules []*keywordRule
+	if accountID == "" {
+		for _, byWord := range keywords.byAccount {
+			for _, rule := range byWord {
+				rules = append(rules, rule)
+			}
+		}
+		sortRules(rules)
+	} else {
+		rules = keywords.rulesFor(accountID)
+	}
+
+	result := make([]store.Keyword, 0, len(rules))
+	for _, rule := range rules {
+		result = append(result, rule.kw)
+	}
+	return result
+}
+
+// rulesFor returns the rules that apply to an account, sorted for evaluation.
+// Caller must hold keywords.mu.
+func (b *keywordBook) rulesFor(accountID string) []*keywordRule {
+	rules := make([]*keywordRule, 0, len(b.byAccount[accountID])+len(b.byAccount[""]))
+	for _, rule := range b.byAccount[accountID] {
+		rules = append(rules, rule)
+	}
+	if accountID != "" {
+		for word, rule := range b.byAccount[""] {
+			if _, overridden := b.byAccount[accountID][word]; !overridden {
+				rules = append(rules, rule)
+			}
+		}
+	}
+	sortRules(rules)
+	return rules
+}
+
+// sortRules orders rules by priority (highest first), then account rules
+// before global ones, then match specificity, then keyword
+func sortRules(rules []*keywordRule) {
+	sort.Slice(rules, func(i, j int) bool {
+		a, b := rules[i], rules[j]
+		if a.kw.Priority != b.kw.Priority {
+			return a.kw.Priority > b.kw.Priority
+		}
+		if (a.kw.AccountID == "") != (b.kw.AccountID == "") {
+			return a.kw.AccountID != ""
+		}
+		if matchModeRank[a.kw.MatchMode] != matchModeRank[b.kw.MatchMode] {
+			return matchModeRank[a.kw.MatchMode] < matchModeRank[b.kw.MatchMode]
+		}
+		if a.kw.Keyword != b.kw.Keyword {
+			return a.kw.Keyword < b.kw.Keyword
+		}
+		return a.kw.AccountID < b.kw.AccountID
+	})
+}
+
+// lookupKeyword returns the response of the first rule matching a
+// normalized (trimmed, lower-cased) message
+func lookupKeyword(accountID, text string) (string, bool) {
+	keywords.mu.RLock()
+	defer keywords.mu.RUnlock()
+
+	for _, rule := range keywords.rulesFor(accountID) {
+		if rule.matches(text) {
+			return rule.kw.Response, true
+		}
+	}
+	return "", false
+}
+
+// matches reports whether the message satisfies the rule
+func (r *keywordRule) matches(text string) bool {
+	switch r.kw.MatchMode {
+	case MatchExact:
+		return text == r.kw.Keyword
+	case MatchPrefix:
+		return strings.HasPrefix(text, r.kw.Keyword)
+	case MatchContains:
+		return strings.Contains(text, r.kw.Keyword)
+	case MatchRegex:
+		return r.re != nil && r.re.MatchString(text)
+	case MatchFuzzy:
+		return fuzzyMatch(text, r.kw.Keyword)
+	}
+	return false
+}
+
+// fuzzyMatch tolerates small typos, comparing the keyword against the whole
+// message and against each word of it
+func fuzzyMatch(text, keyword string) bool {
+	maxDistance := 0
+	switch n := len([]rune(keyword)); {
+	case n > 5:
+		maxDistance = 2
+	case n > 3:
+		maxDistance = 1
+	}
+
+	if levenshtein(text, keyword) <= maxDistance {
+		return true
+	}
+	for _, word := range strings.Fields(text) {
+		word = strings.Trim(word, ".,!?;:")
+		if levenshtein(word, keyword) <= maxDistance {
+			return true
+		}
+	}
+	return false
+}
+
+// levenshtein returns the edit distance between two strings
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,187 @@
+package whatsapp
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"esther-whatsapp/internal/config"
+	"esther-whatsapp/internal/store"
+)
+
+// TestMain keeps keywords and users in a throwaway SQLite store
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "whatsapp-test")
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.AppConfig = &config.Config{StoreDriver: "sqlite", SQLitePath: filepath.Join(dir, "test.db"), SessionDir: dir}
+	store.DB, err = store.NewSQLite(config.AppConfig.SQLitePath)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestLevenshtein(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"abc", "", 3},
+		{"harga", "harga", 0},
+		{"harga", "hrga", 1},
+		{"harga", "hargaa", 1},
+		{"harga", "hatga", 1},
+		{"kitten", "sitting", 3},
+		{"café", "cafe", 1}, // Runes, not bytes
+	}
+	for _, tt := range tests {
+		if got := levenshtein(tt.a, tt.b); got != tt.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		text, keyword string
+		want          bool
+	}{
+		{"info", "info", true},
+		{"inf", "info", true},
+		{"ok", "oke", false}, // Keywords of 3 runes or less must match exactly
+		{"halo", "hallo", true},
+		{"hlo", "hallo", false},
+		{"jadwal", "jadwal", true},
+		{"jadwl", "jadwal", true},
+		{"jdwl", "jadwal", true},
+		{"jdw", "jadwal", false},
+		{"minta jadwl dong", "jadwal", true}, // Any word of the message
+		{"jadwl!", "jadwal", true},           // Punctuation around words is ignored
+		{"berapa harga nya", "alamat", false},
+	}
+	for _, tt := range tests {
+		if got := fuzzyMatch(tt.text, tt.keyword); got != tt.want {
+			t.Errorf("fuzzyMatch(%q, %q) = %v, want %v", tt.text, tt.keyword, got, tt.want)
+		}
+	}
+}
+
+func TestKeywordRuleMatches(t *testing.T) {
+	tests := []struct {
+		mode, keyword, text string
+		want                bool
+	}{
+		{MatchExact, "info", "info", true},
+		{MatchExact, "info", "info dong", false},
+		{MatchPrefix, "harga", "harga paket", true},
+		{MatchPrefix, "harga", "paket harga", false},
+		{MatchContains, "harga", "paket harga", true},
+		{MatchContains, "harga", "paket", false},
+		{MatchRegex, `^order\s+\d+$`, "order 42", true},
+		{MatchRegex, `^order\s+\d+$`, "ORDER 42", true}, // Case-insensitive
+		{MatchRegex, `^order\s+\d+$`, "order abc", false},
+		{MatchFuzzy, "jadwal", "jadwl", true},
+	}
+	for _, tt := range tests {
+		rule, err := compileKeyword(store.Keyword{Keyword: tt.keyword, MatchMode: tt.mode})
+		if err != nil {
+			t.Fatalf("compileKeyword(%q, %s): %v", tt.keyword, tt.mode, err)
+		}
+		if got := rule.matches(tt.text); got != tt.want {
+			t.Errorf("%s %q matches %q = %v, want %v", tt.mode, tt.keyword, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCompileKeywordInvalid(t *testing.T) {
+	tests := []store.Keyword{
+		{Keyword: "info", MatchMode: "glob"},
+		{Keyword: "", MatchMode: MatchExact},
+		{Keyword: "(", MatchMode: MatchRegex},
+	}
+	for _, k := range tests {
+		if _, err := compileKeyword(k); err == nil {
+			t.Errorf("compileKeyword(%q, %s) succeeded, want an error", k.Keyword, k.MatchMode)
+		}
+	}
+}
+
+func TestSortRules(t *testing.T) {
+	rule := func(keyword, mode, accountID string, priority int) *keywordRule {
+		return &keywordRule{kw: store.Keyword{Keyword: keyword, MatchMode: mode, AccountID: accountID, Priority: priority}}
+	}
+	rules := []*keywordRule{
+		rule("b", MatchFuzzy, "", 0),
+		rule("a", MatchContains, "", 0),
+		rule("c", MatchExact, "", 0),
+		rule("d", MatchFuzzy, "acc", 0),
+		rule("e", MatchFuzzy, "", 5),
+	}
+	sortRules(rules)
+
+	want := []string{"e", "d", "c", "a", "b"}
+	for i, r := range rules {
+		if r.kw.Keyword != want[i] {
+			t.Fatalf("rule %d is %q, want order %v", i, r.kw.Keyword, want)
+		}
+	}
+}
+
+func TestAccountKeywordsArePersisted(t *testing.T) {
+	for _, k := range []store.Keyword{
+		{Keyword: "Harga", Response: "Mulai Rp100.000"},
+		{AccountID: "acc-a", Keyword: "harga", Response: "Mulai Rp90.000"},
+	} {
+		if _, err := AddKeyword(k); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer RemoveKeyword("", "harga")
+
+	// Read back from the store, as on startup
+	keywords.mu.Lock()
+	keywords.byAccount = make(map[string]map[string]*keywordRule)
+	keywords.mu.Unlock()
+	if err := LoadKeywords(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		accountID string
+		want      string
+	}{
+		{"acc-a", "Mulai Rp90.000"}, // Its own rule overrides the global one
+		{"acc-b", "Mulai Rp100.000"},
+		{"", "Mulai Rp100.000"},
+	}
+	for _, tt := range tests {
+		if got, ok := lookupKeyword(tt.accountID, "harga"); !ok || got != tt.want {
+			t.Errorf("lookupKeyword(%q) = %q, %v, want %q", tt.accountID, got, ok, tt.want)
+		}
+	}
+
+	// Removing the account's rule falls back to the global one
+	if err := RemoveKeyword("acc-a", "HARGA"); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := lookupKeyword("acc-a", "harga"); got != "Mulai Rp100.000" {
+		t.Fatalf("lookupKeyword(acc-a) after removal = %q, want the global reply", got)
+	}
+	stored, err := store.DB.GetKeywords()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range stored {
+		if k.AccountID == "acc-a" && k.Keyword == "harga" {
+			t.Fatal("removed keyword is still stored")
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package whatsapp
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"esther-whatsapp/internal/config"
+)
+
+// ErrRateLimited is returned when an account would have to wait too long for
+// its next send. It is temporary: the queue retries the job later.
+var ErrRateLimited = errors.New("account rate limit reached")
+
+// sendWait is how long a send may block waiting for the account's limits
+const sendWait = time.Minute
+
+// Rate limit windows
+var limitWindows = []struct {
+	name   string
+	period time.Duration
+}{
+	{"per_minute", time.Minute},
+	{"per_hour", time.Hour},
+	{"per_day", 24 * time.Hour},
+}
+
+// bucket is a token bucket refilled evenly over its period. Tokens go negative
+// while sends are waiting for their turn.
+type bucket struct {
+	limit  int
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens earned since the last call. The limit is read on
+// every call, so changed settings apply right away.
+func (b *bucket) refill(limit int, period time.Duration, now time.Time) {
+	if b.last.IsZero() {
+		b.tokens = float64(limit)
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * float64(limit) / period.Seconds()
+	}
+	b.tokens = math.Min(b.tokens, float64(limit))
+	b.limit = limit
+	b.last = now
+}
+
+// wait returns how long until the bucket has a token to spend
+func (b *bucket) wait(period time.Duration) time.Duration {
+	if b.tokens >= 1 {
+		return 0
+	}
+	missing := 1 - b.tokens
+	return time.Duration(missing * period.Seconds() / float64(b.limit) * float64(time.Second))
+}
+
+// RateLimiter throttles outgoing messages per account with the per minute,
+// hour and day limits from the account's settings. A limit of 0 disables that window.
+type RateLimiter struct {
+	buckets map[string][]*bucket // key: account ID
+	mu      sync.Mutex
+}
+
+// Limiter is shared by every send path
+var Limiter = &RateLimiter{
+	buckets: make(map[string][]*bucket),
+}
+
+// Wait takes a send slot for the account, sleeping until one is free. When
+// that would take longer than maxWait it returns ErrRateLimited instead.
+func (l *RateLimiter) Wait(accountID string, maxWait time.Duration) error {
+	wait, err := l.reserve(accountID, maxWait)
+	if err != nil {
+		return err
+	}
+	if wait > 0 {
+		log.Printf("🚦 Account %s is at its rate limit, waiting %v", accountID, wait.Round(time.Second))
+		time.Sleep(wait)
+	}
+	return nil
+}
+
+// reserve spends a token from every window, or none if the wait is too long
+func (l *RateLimiter) reserve(accountID string, maxWait time.Duration) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limits := accountLimits(accountID)
+	buckets := l.accountBuckets(accountID)
+	now := time.Now()
+
+	var wait time.Duration
+	for i, w := range limitWindows {
+		if limits[i] <= 0 {
+			continue
+		}
+		buckets[i].refill(limits[i], w.period, now)
+		wait = max(wait, buckets[i].wait(w.period))
+	}
+	if wait > maxWait {
+		return 0, fmt.Errorf("%w: next send in %v", ErrRateLimited, wait.Round(time.Second))
+	}
+
+	for i := range limitWindows {
+		if limits[i] > 0 {
+			buckets[i].tokens--
+		}
+	}
+	return wait, nil
+}
+
+// RateWindow is the state of one limit window; Limit 0 means unlimited
+type RateWindow struct {
+	Limit     int `json:"limit"`
+	Remaining int `json:"remaining"`
+}
+
+// Status reports the account's remaining sends in each window
+func (l *RateLimiter) Status(accountID string) map[string]RateWindow {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	limits := accountLimits(accountID)
+	buckets := l.accountBuckets(accountID)
+	now := time.Now()
+
+	status := make(map[string]RateWindow, len(limitWindows))
+	for i, w := range limitWindows {
+		window := RateWindow{Limit: limits[i]}
+		if limits[i] > 0 {
+			buckets[i].refill(limits[i], w.period, now)
+			window.Remaining = max(int(buckets[i].tokens), 0)
+		}
+		status[w.name] = window
+	}
+	return status
+}
+
+// accountBuckets returns the account's buckets, creating them on first use.
+// Caller must hold l.mu.
+func (l *RateLimiter) accountBuckets(accountID string) []*bucket {
+	buckets, ok := l.buckets[accountID]
+	if !ok {
+		buckets = make([]*bucket, len(limitWindows))
+		for i := range buckets {
+			buckets[i] = &bucket{}
+		}
+		l.buckets[accountID] = buckets
+	}
+	return buckets
+}
+
+// accountLimits returns the account's limits in limitWindows order
+func accountLimits(accountID string) []int {
+	perMinute, perHour, perDay := config.SettingsFor(accountID).GetAccountLimits()
+	return []int{perMinute, perHour, perDay}
+}
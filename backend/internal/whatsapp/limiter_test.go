@@ -0,0 +1,101 @@
+package whatsapp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"esther-whatsapp/internal/config"
+)
+
+func TestBucketRefill(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		tokens  float64
+		elapsed time.Duration
+		limit   int
+		want    float64
+	}{
+		{"empty bucket refills evenly", 0, 30 * time.Second, 10, 5},
+		{"negative tokens pay back first", -2, 30 * time.Second, 10, 3},
+		{"capped at the limit", 8, 10 * time.Minute, 10, 10},
+		{"lowered limit caps right away", 10, 0, 4, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &bucket{limit: 10, tokens: tt.tokens, last: start}
+			b.refill(tt.limit, time.Minute, start.Add(tt.elapsed))
+			if b.tokens != tt.want {
+				t.Fatalf("tokens = %v, want %v", b.tokens, tt.want)
+			}
+		})
+	}
+}
+
+func TestBucketFirstRefillIsFull(t *testing.T) {
+	b := &bucket{}
+	b.refill(7, time.Hour, time.Now())
+	if b.tokens != 7 {
+		t.Fatalf("tokens = %v, want 7", b.tokens)
+	}
+}
+
+func TestBucketWait(t *testing.T) {
+	tests := []struct {
+		tokens float64
+		want   time.Duration
+	}{
+		{1, 0},
+		{3.5, 0},
+		{0.5, 3 * time.Second},
+		{0, 6 * time.Second},
+		{-1, 12 * time.Second},
+	}
+	for _, tt := range tests {
+		b := &bucket{limit: 10, tokens: tt.tokens}
+		if got := b.wait(time.Minute); got != tt.want {
+			t.Errorf("wait() with %v tokens = %v, want %v", tt.tokens, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	perMinute, perHour, perDay := config.Settings.GetAccountLimits()
+	t.Cleanup(func() { config.Settings.SetAccountLimits(perMinute, perHour, perDay) })
+	config.Settings.SetAccountLimits(2, 0, 0)
+
+	l := &RateLimiter{buckets: make(map[string][]*bucket)}
+	for i := 0; i < 2; i++ {
+		if wait, err := l.reserve("acc", 0); err != nil || wait != 0 {
+			t.Fatalf("reserve %d = %v, %v, want a free slot", i+1, wait, err)
+		}
+	}
+	if _, err := l.reserve("acc", 0); !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("reserve 3 = %v, want ErrRateLimited", err)
+	}
+
+	// A refused reservation spends nothing: the next slot is half a minute away
+	wait, err := l.reserve("acc", time.Minute)
+	if err != nil || wait <= 25*time.Second || wait > 30*time.Second {
+		t.Fatalf("reserve 4 = %v, %v, want a wait of about 30s", wait, err)
+	}
+
+	// Accounts have their own buckets
+	if _, err := l.reserve("other", 0); err != nil {
+		t.Fatalf("reserve on another account = %v, want a free slot", err)
+	}
+}
+
+func TestRateLimiterUnlimited(t *testing.T) {
+	perMinute, perHour, perDay := config.Settings.GetAccountLimits()
+	t.Cleanup(func() { config.Settings.SetAccountLimits(perMinute, perHour, perDay) })
+	config.Settings.SetAccountLimits(0, 0, 0)
+
+	l := &RateLimiter{buckets: make(map[string][]*bucket)}
+	for i := 0; i < 100; i++ {
+		if _, err := l.reserve("acc", 0); err != nil {
+			t.Fatalf("reserve %d = %v, want no limit", i+1, err)
+		}
+	}
+}
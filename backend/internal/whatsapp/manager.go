@@ -2,25 +2,63 @@ package whatsapp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"sort"
 	"sync"
+	"time"
+
+	"esther-whatsapp/internal/store"
 
 	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
 	"go.mau.fi/whatsmeow"
 	"go.mau.fi/whatsmeow/store/sqlstore"
+	"go.mau.fi/whatsmeow/types"
 	waLog "go.mau.fi/whatsmeow/util/log"
 )
 
+// Errors returned when no account can send a message
+var (
+	ErrAccountNotFound     = errors.New("account not found")
+	ErrAccountNotConnected = errors.New("account not connected")
+	ErrNoConnectedAccount  = errors.New("no connected account available")
+)
+
+// ErrInvalidChat is returned for a queued reply whose chat JID cannot be parsed
+var ErrInvalidChat = errors.New("invalid chat")
+
+// IsPermanent reports whether a send error will not go away by retrying
+func IsPermanent(err error) bool {
+	return errors.Is(err, ErrAccountNotFound) ||
+		errors.Is(err, ErrInvalidMedia) ||
+		errors.Is(err, ErrInvalidChat) ||
+		errors.Is(err, whatsmeow.ErrUnknownServer) ||
+		errors.Is(err, whatsmeow.ErrRecipientADJID) ||
+		errors.Is(err, whatsmeow.ErrBroadcastListUnsupported)
+}
+
+// Account selection strategies for messages without an explicit account
+const (
+	// SelectLastConversation picks the connected account the user last wrote to,
+	// falling back to any connected account
+	SelectLastConversation = "last_conversation"
+	// SelectAnyConnected picks the first connected account
+	SelectAnyConnected = "any_connected"
+)
+
 // Account represents a WhatsApp account
 type Account struct {
-	ID          string `json:"id"`
-	Name        string `json:"name"`
-	Phone       string `json:"phone"`
-	IsConnected bool   `json:"is_connected"`
-	IsLoggedIn  bool   `json:"is_logged_in"`
-	CreatedAt   string `json:"created_at"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Phone       string   `json:"phone"`
+	IsConnected bool     `json:"is_connected"`
+	IsLoggedIn  bool     `json:"is_logged_in"`
+	CreatedAt   string   `json:"created_at"`
+	SessionFile string   `json:"session_file"`
+	Labels      []string `json:"labels"`
 
 	client    *whatsmeow.Client
 	qrChannel <-chan whatsmeow.QRChannelItem
@@ -37,21 +75,28 @@ var Manager = &AccountManager{
 }
 
 // AddAccount creates a new WhatsApp account
-func (m *AccountManager) AddAccount(name string) (*Account, error) {
+func (m *AccountManager) AddAccount(name string, labels []string) (*Account, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	id := uuid.New().String()[:8] // Short ID for readability
 
+	if labels == nil {
+		labels = []string{}
+	}
+
 	account := &Account{
 		ID:          id,
 		Name:        name,
 		IsConnected: false,
 		IsLoggedIn:  false,
+		CreatedAt:   time.Now().Format(time.RFC3339),
+		SessionFile: sessionPrefix + id + sessionSuffix,
+		Labels:      labels,
 	}
 
 	// Create WhatsApp client for this account
-	client, err := m.createClient(id)
+	client, err := m.createClient(account.SessionFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create client: %w", err)
 	}
@@ -61,19 +106,102 @@ func (m *AccountManager) AddAccount(name string) (*Account, error) {
 	m.registerHandler(account)
 
 	m.accounts[id] = account
+	if err := m.saveLocked(); err != nil {
+		delete(m.accounts, id)
+		client.Disconnect()
+		return nil, err
+	}
 	log.Printf("✅ Account added: %s (%s)", name, id)
 
 	return account, nil
 }
 
-// createClient creates a WhatsApp client for an account
-func (m *AccountManager) createClient(accountID string) (*whatsmeow.Client, error) {
+// LoadAccounts restores all accounts from the registry file, rebuilding it from
+// the session files on disk when it does not exist yet.
+// It must be called before ConnectAllAccounts so paired sessions come back online.
+func (m *AccountManager) LoadAccounts() error {
+	records, found, err := loadRegistry()
+	if err != nil {
+		return err
+	}
+	if !found {
+		records, err = discoverSessions()
+		if err != nil {
+			return err
+		}
+		if len(records) > 0 {
+			log.Printf("📂 No account registry, rebuilding it from %d session file(s)", len(records))
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, rec := range records {
+		if _, exists := m.accounts[rec.ID]; exists {
+			continue
+		}
+
+		if _, err := os.Stat(sessionPath(rec.SessionFile)); err != nil {
+			log.Printf("⚠️ Session file for account %s (%s) not found, QR scan required", rec.ID, rec.Name)
+		}
+
+		client, err := m.createClient(rec.SessionFile)
+		if err != nil {
+			log.Printf("❌ Failed to restore account %s: %v", rec.ID, err)
+			continue
+		}
+
+		labels := rec.Labels
+		if labels == nil {
+			labels = []string{}
+		}
+
+		account := &Account{
+			ID:          rec.ID,
+			Name:        rec.Name,
+			CreatedAt:   rec.CreatedAt,
+			SessionFile: rec.SessionFile,
+			Labels:      labels,
+			client:      client,
+		}
+		m.registerHandler(account)
+		m.accounts[rec.ID] = account
+		log.Printf("📂 Account restored: %s (%s)", rec.Name, rec.ID)
+	}
+
+	if !found && len(records) > 0 {
+		return m.saveLocked()
+	}
+	return nil
+}
+
+// saveLocked persists the account registry. Caller must hold m.mu.
+func (m *AccountManager) saveLocked() error {
+	records := make([]accountRecord, 0, len(m.accounts))
+	for _, account := range m.accounts {
+		records = append(records, accountRecord{
+			ID:          account.ID,
+			Name:        account.Name,
+			CreatedAt:   account.CreatedAt,
+			SessionFile: account.SessionFile,
+			Labels:      account.Labels,
+		})
+	}
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].CreatedAt < records[j].CreatedAt
+	})
+	return saveRegistry(records)
+}
+
+// createClient creates a WhatsApp client backed by the given session file
+func (m *AccountManager) createClient(sessionFile string) (*whatsmeow.Client, error) {
 	ctx := context.Background()
 
 	dbLog := waLog.Stdout("Database", "ERROR", true)
-	sessionFile := fmt.Sprintf("file:wa_session_%s.db?_foreign_keys=on", accountID)
+	dsn := fmt.Sprintf("file:%s?_foreign_keys=on", sessionPath(sessionFile))
 
-	container, err := sqlstore.New(ctx, "sqlite3", sessionFile, dbLog)
+	container, err := sqlstore.New(ctx, "sqlite3", dsn, dbLog)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create session store: %w", err)
 	}
@@ -107,7 +235,7 @@ func (m *AccountManager) RemoveAccount(id string) error {
 
 	account, exists := m.accounts[id]
 	if !exists {
-		return fmt.Errorf("account not found")
+		return ErrAccountNotFound
 	}
 
 	// Disconnect if connected
@@ -116,6 +244,9 @@ func (m *AccountManager) RemoveAccount(id string) error {
 	}
 
 	delete(m.accounts, id)
+	if err := m.saveLocked(); err != nil {
+		return err
+	}
 	log.Printf("🗑️ Account removed: %s", id)
 
 	return nil
@@ -130,11 +261,48 @@ func (m *AccountManager) GetAccount(id string) (*Account, bool) {
 	return account, exists
 }
 
-// ListAccounts returns all accounts
-func (m *AccountManager) ListAccounts() []*Account {
+// IsAccountConnected reports whether an account is connected, and whether it exists
+func (m *AccountManager) IsAccountConnected(id string) (connected, exists bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	account, exists := m.accounts[id]
+	return exists && account.IsConnected, exists
+}
+
+// AnyConnected reports whether at least one account is connected
+func (m *AccountManager) AnyConnected() bool {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	for _, account := range m.accounts {
+		if account.IsConnected {
+			return true
+		}
+	}
+	return false
+}
+
+// setConnected records the connection state reported by an account's events
+func (m *AccountManager) setConnected(account *Account, connected bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	account.IsConnected = connected
+}
+
+// setLoggedOut records that an account's session was logged out
+func (m *AccountManager) setLoggedOut(account *Account) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	account.IsLoggedIn = false
+}
+
+// ListAccounts returns all accounts
+func (m *AccountManager) ListAccounts() []*Account {
+	// Refreshing the status writes to the accounts
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	accounts := make([]*Account, 0, len(m.accounts))
 	for _, account := range m.accounts {
 		// Update status
@@ -155,7 +323,7 @@ func (m *AccountManager) ListAccounts() []*Account {
 func (m *AccountManager) ConnectAccount(id string) error {
 	account, exists := m.GetAccount(id)
 	if !exists {
-		return fmt.Errorf("account not found")
+		return ErrAccountNotFound
 	}
 
 	if account.client == nil {
@@ -173,7 +341,7 @@ func (m *AccountManager) ConnectAccount(id string) error {
 func (m *AccountManager) DisconnectAccount(id string) error {
 	account, exists := m.GetAccount(id)
 	if !exists {
-		return fmt.Errorf("account not found")
+		return ErrAccountNotFound
 	}
 
 	if account.client != nil {
@@ -187,7 +355,7 @@ func (m *AccountManager) DisconnectAccount(id string) error {
 func (m *AccountManager) GetQRChannel(id string) (<-chan whatsmeow.QRChannelItem, error) {
 	account, exists := m.GetAccount(id)
 	if !exists {
-		return nil, fmt.Errorf("account not found")
+		return nil, ErrAccountNotFound
 	}
 
 	if account.client == nil {
@@ -218,19 +386,156 @@ func (m *AccountManager) GetQRChannel(id string) (<-chan whatsmeow.QRChannelItem
 	return qrChan, nil
 }
 
-// SendMessage sends a message from a specific account
-func (m *AccountManager) SendMessage(accountID, phone, message string) error {
+// SendMessage sends a message from a specific account and returns its WhatsApp message ID
+func (m *AccountManager) SendMessage(accountID, phone, message string) (string, error) {
+	return m.sendText(accountID, ParseJID(phone), message)
+}
+
+func (m *AccountManager) sendText(accountID string, jid types.JID, message string) (string, error) {
+	account, err := m.connectedAccount(accountID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := Limiter.Wait(accountID, sendWait); err != nil {
+		return "", err
+	}
+
+	return sendTextMessage(account.client, jid, message)
+}
+
+// SendMedia sends a file from a specific account and returns its WhatsApp message ID
+func (m *AccountManager) SendMedia(accountID, phone string, media *Media) (string, error) {
+	return m.sendMedia(accountID, ParseJID(phone), media)
+}
+
+func (m *AccountManager) sendMedia(accountID string, jid types.JID, media *Media) (string, error) {
+	account, err := m.connectedAccount(accountID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := Limiter.Wait(accountID, sendWait); err != nil {
+		return "", err
+	}
+
+	waID, err := sendMediaMessage(account.client, jid, media)
+	if err != nil {
+		return "", err
+	}
+
+	// Voice notes cannot carry a caption, send it as a follow-up text. It is a
+	// message of its own, so it takes its own send slot.
+	if media.Kind() == MediaAudio && media.Caption != "" {
+		if err := Limiter.Wait(accountID, sendWait); err != nil {
+			return waID, fmt.Errorf("audio sent but caption failed: %w", err)
+		}
+		if _, err := sendTextMessage(account.client, jid, media.Caption); err != nil {
+			return waID, fmt.Errorf("audio sent but caption failed: %w", err)
+		}
+	}
+	return waID, nil
+}
+
+// Send sends either a file (with text as its caption) or a plain text message
+func (m *AccountManager) Send(accountID, phone, text string, media *Media) (string, error) {
+	return m.send(accountID, ParseJID(phone), text, media)
+}
+
+func (m *AccountManager) send(accountID string, jid types.JID, text string, media *Media) (string, error) {
+	if media == nil {
+		return m.sendText(accountID, jid, text)
+	}
+	if media.Caption == "" {
+		media.Caption = text
+	}
+	return m.sendMedia(accountID, jid, media)
+}
+
+// Deliver sends a message and records it in the messages log with its
+// WhatsApp message ID, or as failed when sending errors
+func (m *AccountManager) Deliver(accountID, phone, msgType, text string, media *Media) (string, error) {
+	return m.DeliverTo(accountID, phone, "", msgType, text, media)
+}
+
+// DeliverTo is Deliver into a given chat, such as the group or linked-ID
+// address a message came from. An empty chat is the phone's own chat.
+func (m *AccountManager) DeliverTo(accountID, phone, chat, msgType, text string, media *Media) (string, error) {
+	jid, err := chatJID(phone, chat)
+	if err != nil {
+		return "", err
+	}
+	waID, err := m.send(accountID, jid, text, media)
+
+	user, lookupErr := findOrCreateUser(phone, accountID)
+	if lookupErr != nil {
+		log.Printf("Error getting user: %v", lookupErr)
+	} else {
+		logOutgoing(user, accountID, msgType, text, media, waID, err)
+	}
+	return waID, err
+}
+
+// chatJID returns the JID to send to: the chat when set, otherwise the phone's own chat
+func chatJID(phone, chat string) (types.JID, error) {
+	if chat == "" {
+		return ParseJID(phone), nil
+	}
+	jid, err := types.ParseJID(chat)
+	if err != nil {
+		return types.JID{}, fmt.Errorf("%w: chat %q: %v", ErrInvalidChat, chat, err)
+	}
+	return jid, nil
+}
+
+// SelectAccount resolves which account should send to a phone. An explicit
+// accountID wins; otherwise the strategy decides (SelectLastConversation by default).
+func (m *AccountManager) SelectAccount(accountID, phone, strategy string) (string, error) {
+	if accountID != "" {
+		if _, err := m.connectedAccount(accountID); err != nil {
+			return "", err
+		}
+		return accountID, nil
+	}
+
+	if strategy == "" || strategy == SelectLastConversation {
+		users, err := store.DB.GetUsersByPhone(phone)
+		if err != nil {
+			return "", err
+		}
+		for _, user := range users {
+			if user.AccountID == nil {
+				continue
+			}
+			if _, err := m.connectedAccount(*user.AccountID); err == nil {
+				return *user.AccountID, nil
+			}
+		}
+	} else if strategy != SelectAnyConnected {
+		return "", fmt.Errorf("unknown account strategy %q", strategy)
+	}
+
+	accounts := m.ListAccounts()
+	sort.Slice(accounts, func(i, j int) bool { return accounts[i].CreatedAt < accounts[j].CreatedAt })
+	for _, account := range accounts {
+		if connected, _ := m.IsAccountConnected(account.ID); connected {
+			return account.ID, nil
+		}
+	}
+	return "", ErrNoConnectedAccount
+}
+
+// connectedAccount returns an account that is ready to send
+func (m *AccountManager) connectedAccount(accountID string) (*Account, error) {
 	account, exists := m.GetAccount(accountID)
 	if !exists {
-		return fmt.Errorf("account not found")
+		return nil, ErrAccountNotFound
 	}
 
 	if account.client == nil || !account.client.IsConnected() {
-		return fmt.Errorf("account not connected")
+		return nil, ErrAccountNotConnected
 	}
-
-	jid := ParseJID(phone)
-	return sendTextMessage(account.client, jid, message)
+	return account, nil
 }
 
 // ConnectAllAccounts connects all accounts that are logged in
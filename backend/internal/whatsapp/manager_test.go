@@ -0,0 +1,136 @@
+package whatsapp
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+// addTestAccount registers an account without a WhatsApp client until the test ends
+func addTestAccount(t *testing.T, id string) *Account {
+	t.Helper()
+	account := &Account{ID: id, Name: id, Labels: []string{}}
+	Manager.mu.Lock()
+	Manager.accounts[id] = account
+	Manager.mu.Unlock()
+	t.Cleanup(func() {
+		Manager.mu.Lock()
+		delete(Manager.accounts, id)
+		Manager.mu.Unlock()
+	})
+	return account
+}
+
+func TestConnectionEventsUpdateTheAccountState(t *testing.T) {
+	account := addTestAccount(t, "acc-ev")
+
+	if connected, exists := Manager.IsAccountConnected("acc-ev"); connected || !exists {
+		t.Fatalf("IsAccountConnected() = %v, %v, want a disconnected account", connected, exists)
+	}
+	if _, exists := Manager.IsAccountConnected("missing"); exists {
+		t.Fatal("IsAccountConnected(missing) reports an account")
+	}
+
+	handleAccountEvent(account, &events.Connected{})
+	if connected, _ := Manager.IsAccountConnected("acc-ev"); !connected || !Manager.AnyConnected() {
+		t.Fatal("account is not connected after a Connected event")
+	}
+
+	handleAccountEvent(account, &events.Disconnected{})
+	if connected, _ := Manager.IsAccountConnected("acc-ev"); connected || Manager.AnyConnected() {
+		t.Fatal("account is still connected after a Disconnected event")
+	}
+}
+
+// TestConnectionStateIsSynchronised is meant for go test -race: events flip
+// the state while checks read it
+func TestConnectionStateIsSynchronised(t *testing.T) {
+	account := addTestAccount(t, "acc-race")
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			handleAccountEvent(account, &events.Connected{})
+			handleAccountEvent(account, &events.Disconnected{})
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			Manager.IsAccountConnected("acc-race")
+			Manager.AnyConnected()
+		}
+	}()
+	wg.Wait()
+}
+
+func TestSelectAccountWithoutConnectedAccounts(t *testing.T) {
+	addTestAccount(t, "acc-offline") // Has no client, so it never sends
+
+	tests := []struct {
+		name      string
+		accountID string
+		strategy  string
+		want      error
+	}{
+		{"unknown pinned account", "acc-missing", "", ErrAccountNotFound},
+		{"offline pinned account", "acc-offline", "", ErrAccountNotConnected},
+		{"last conversation falls back to any account", "", SelectLastConversation, ErrNoConnectedAccount},
+		{"any connected account", "", SelectAnyConnected, ErrNoConnectedAccount},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Manager.SelectAccount(tt.accountID, "62811", tt.strategy); !errors.Is(err, tt.want) {
+				t.Fatalf("SelectAccount() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := Manager.SelectAccount("", "62811", "round_robin"); err == nil {
+		t.Fatal("SelectAccount() with an unknown strategy succeeded")
+	}
+}
+
+func TestIsPermanent(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{ErrAccountNotFound, true},
+		{fmt.Errorf("%w: file is empty", ErrInvalidMedia), true},
+		{ErrAccountNotConnected, false},
+		{ErrNoConnectedAccount, false},
+		{errors.New("connection reset"), false},
+	}
+	for _, tt := range tests {
+		if got := IsPermanent(tt.err); got != tt.want {
+			t.Errorf("IsPermanent(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestChatJID(t *testing.T) {
+	tests := []struct {
+		chat string
+		want string
+	}{
+		{"", "62811@s.whatsapp.net"},
+		{"120363025@g.us", "120363025@g.us"},
+		{"207613512@lid", "207613512@lid"},
+	}
+	for _, tt := range tests {
+		jid, err := chatJID("62811", tt.chat)
+		if err != nil || jid.String() != tt.want {
+			t.Errorf("chatJID(62811, %q) = %v, %v, want %s", tt.chat, jid, err, tt.want)
+		}
+	}
+
+	if _, err := chatJID("62811", "1.2.3@lid"); !errors.Is(err, ErrInvalidChat) || !IsPermanent(err) {
+		t.Fatalf("chatJID() with a malformed chat = %v, want a permanent ErrInvalidChat", err)
+	}
+}
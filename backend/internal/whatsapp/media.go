@@ -0,0 +1,193 @@
+package whatsapp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"esther-whatsapp/internal/config"
+
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/proto/waE2E"
+	"go.mau.fi/whatsmeow/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// Media kinds, matching the media_type column of the messages log
+const (
+	MediaImage    = "image"
+	MediaVideo    = "video"
+	MediaAudio    = "audio"
+	MediaDocument = "document"
+	MediaSticker  = "sticker"  // Incoming only
+	MediaLocation = "location" // Incoming only, nothing is downloaded
+)
+
+// ErrInvalidMedia is returned when a media file cannot be sent
+var ErrInvalidMedia = errors.New("invalid media")
+
+// Media is a file to be sent as a WhatsApp message
+type Media struct {
+	Data     []byte
+	MimeType string
+	FileName string
+	Caption  string
+}
+
+// NewMedia builds a media message, detecting the MIME type from the file
+// name and falling back to the content when the extension is unknown
+func NewMedia(data []byte, fileName, caption string) (*Media, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: file is empty", ErrInvalidMedia)
+	}
+
+	mimeType := mime.TypeByExtension(strings.ToLower(filepath.Ext(fileName)))
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	// Drop parameters such as "; charset=utf-8"
+	if mediaType, _, err := mime.ParseMediaType(mimeType); err == nil {
+		mimeType = mediaType
+	}
+
+	return &Media{
+		Data:     data,
+		MimeType: mimeType,
+		FileName: filepath.Base(fileName),
+		Caption:  caption,
+	}, nil
+}
+
+// LoadMediaFile reads a stored file from MEDIA_DIR
+func LoadMediaFile(name, caption string) (*Media, error) {
+	path, err := mediaPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("%w: file %q not found", ErrInvalidMedia, name)
+		}
+		return nil, err
+	}
+	return NewMedia(data, name, caption)
+}
+
+// mediaPath resolves a stored file name inside MEDIA_DIR without letting it escape
+func mediaPath(name string) (string, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", fmt.Errorf("%w: file name is empty", ErrInvalidMedia)
+	}
+	clean := filepath.Clean("/" + filepath.ToSlash(name))
+	return filepath.Join(config.AppConfig.MediaDir, filepath.FromSlash(clean)), nil
+}
+
+// Kind returns how the file is presented in WhatsApp
+func (m *Media) Kind() string {
+	switch {
+	case strings.HasPrefix(m.MimeType, "image/"):
+		return MediaImage
+	case strings.HasPrefix(m.MimeType, "video/"):
+		return MediaVideo
+	case strings.HasPrefix(m.MimeType, "audio/"):
+		return MediaAudio
+	default:
+		return MediaDocument
+	}
+}
+
+// sendMediaMessage uploads a file and sends it using a specific client.
+// It returns the WhatsApp message ID of the file message. Audio goes without
+// its caption, see AccountManager.SendMedia.
+func sendMediaMessage(client *whatsmeow.Client, to types.JID, media *Media) (string, error) {
+	if client == nil {
+		return "", fmt.Errorf("client is nil")
+	}
+
+	ctx := context.Background()
+	kind := media.Kind()
+
+	appInfo := whatsmeow.MediaDocument
+	switch kind {
+	case MediaImage:
+		appInfo = whatsmeow.MediaImage
+	case MediaVideo:
+		appInfo = whatsmeow.MediaVideo
+	case MediaAudio:
+		appInfo = whatsmeow.MediaAudio
+	}
+
+	uploaded, err := client.Upload(ctx, media.Data, appInfo)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload media: %w", err)
+	}
+
+	msg := &waE2E.Message{}
+	switch kind {
+	case MediaImage:
+		msg.ImageMessage = &waE2E.ImageMessage{
+			Caption:       optionalString(media.Caption),
+			Mimetype:      proto.String(media.MimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}
+	case MediaVideo:
+		msg.VideoMessage = &waE2E.VideoMessage{
+			Caption:       optionalString(media.Caption),
+			Mimetype:      proto.String(media.MimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}
+	case MediaAudio:
+		msg.AudioMessage = &waE2E.AudioMessage{
+			Mimetype:      proto.String(media.MimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}
+	default:
+		msg.DocumentMessage = &waE2E.DocumentMessage{
+			Caption:       optionalString(media.Caption),
+			Title:         proto.String(media.FileName),
+			FileName:      proto.String(media.FileName),
+			Mimetype:      proto.String(media.MimeType),
+			URL:           proto.String(uploaded.URL),
+			DirectPath:    proto.String(uploaded.DirectPath),
+			MediaKey:      uploaded.MediaKey,
+			FileEncSHA256: uploaded.FileEncSHA256,
+			FileSHA256:    uploaded.FileSHA256,
+			FileLength:    proto.Uint64(uploaded.FileLength),
+		}
+	}
+
+	resp, err := client.SendMessage(ctx, to, msg)
+	if err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return proto.String(s)
+}
@@ -0,0 +1,68 @@
+package whatsapp
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"esther-whatsapp/internal/config"
+)
+
+func TestNewMediaKind(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR")
+	tests := []struct {
+		fileName string
+		data     []byte
+		mimeType string
+		kind     string
+	}{
+		{"photo.JPG", []byte("jpeg"), "image/jpeg", MediaImage},
+		{"clip.mp4", []byte("mp4"), "video/mp4", MediaVideo},
+		{"voice.ogg", []byte("ogg"), "audio/ogg", MediaAudio},
+		{"price.pdf", []byte("pdf"), "application/pdf", MediaDocument},
+		{"notes.txt", []byte("halo"), "text/plain", MediaDocument}, // Charset dropped
+		{"upload", png, "image/png", MediaImage},                   // Detected from the content
+	}
+	for _, tt := range tests {
+		m, err := NewMedia(tt.data, "dir/"+tt.fileName, "caption")
+		if err != nil {
+			t.Fatalf("NewMedia(%s): %v", tt.fileName, err)
+		}
+		if m.MimeType != tt.mimeType || m.Kind() != tt.kind || m.FileName != tt.fileName {
+			t.Errorf("NewMedia(%s) = %s %s %q, want %s %s", tt.fileName, m.MimeType, m.Kind(), m.FileName, tt.mimeType, tt.kind)
+		}
+	}
+
+	if _, err := NewMedia(nil, "empty.jpg", ""); !errors.Is(err, ErrInvalidMedia) {
+		t.Fatalf("NewMedia() of an empty file = %v, want ErrInvalidMedia", err)
+	}
+}
+
+func TestLoadMediaFileStaysInMediaDir(t *testing.T) {
+	previous := config.AppConfig
+	root := t.TempDir()
+	config.AppConfig = &config.Config{MediaDir: filepath.Join(root, "media")}
+	defer func() { config.AppConfig = previous }()
+
+	if err := os.MkdirAll(config.AppConfig.MediaDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for path, content := range map[string]string{
+		filepath.Join(config.AppConfig.MediaDir, "promo.jpg"): "jpeg",
+		filepath.Join(root, "secret.txt"):                     "secret",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if m, err := LoadMediaFile("promo.jpg", "Diskon"); err != nil || m.Caption != "Diskon" || m.Kind() != MediaImage {
+		t.Fatalf("LoadMediaFile(promo.jpg) = %+v, %v", m, err)
+	}
+	for _, name := range []string{"../secret.txt", "missing.jpg", " "} {
+		if _, err := LoadMediaFile(name, ""); !errors.Is(err, ErrInvalidMedia) {
+			t.Errorf("LoadMediaFile(%q) = %v, want ErrInvalidMedia", name, err)
+		}
+	}
+}
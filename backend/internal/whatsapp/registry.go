@@ -0,0 +1,114 @@
+package whatsapp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"esther-whatsapp/internal/config"
+)
+
+const registryFile = "accounts.json"
+
+// Session files are named wa_session_<account ID>.db
+const (
+	sessionPrefix = "wa_session_"
+	sessionSuffix = ".db"
+)
+
+// accountRecord is the persisted form of an Account in the registry file
+type accountRecord struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	CreatedAt   string   `json:"created_at"`
+	SessionFile string   `json:"session_file"`
+	Labels      []string `json:"labels"`
+}
+
+// sessionPath returns the full path of a session file inside the session directory
+func sessionPath(sessionFile string) string {
+	return filepath.Join(config.AppConfig.SessionDir, sessionFile)
+}
+
+// registryPath returns the path of the account registry file
+func registryPath() string {
+	return sessionPath(registryFile)
+}
+
+// loadRegistry reads all account records from disk. A missing registry file
+// is not an error: found is false and the caller may rebuild it.
+func loadRegistry() (records []accountRecord, found bool, err error) {
+	data, err := os.ReadFile(registryPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read account registry: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, true, fmt.Errorf("failed to parse account registry: %w", err)
+	}
+	return records, true, nil
+}
+
+// discoverSessions builds account records from the session files in the
+// session directory, for sessions paired before the registry existed. The ID
+// comes from the file name and doubles as the name.
+func discoverSessions() ([]accountRecord, error) {
+	entries, err := os.ReadDir(config.AppConfig.SessionDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read session dir: %w", err)
+	}
+
+	var records []accountRecord
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, sessionPrefix) || !strings.HasSuffix(name, sessionSuffix) {
+			continue
+		}
+		id := strings.TrimSuffix(strings.TrimPrefix(name, sessionPrefix), sessionSuffix)
+		if id == "" {
+			continue
+		}
+
+		createdAt := time.Now()
+		if info, err := entry.Info(); err == nil {
+			createdAt = info.ModTime()
+		}
+		records = append(records, accountRecord{
+			ID:          id,
+			Name:        id,
+			CreatedAt:   createdAt.Format(time.RFC3339),
+			SessionFile: name,
+			Labels:      []string{},
+		})
+	}
+	return records, nil
+}
+
+// saveRegistry writes all account records to disk atomically
+func saveRegistry(records []accountRecord) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode account registry: %w", err)
+	}
+
+	if err := os.MkdirAll(config.AppConfig.SessionDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create session dir: %w", err)
+	}
+
+	// Write to a temp file first so a crash never leaves a truncated registry
+	tmp := registryPath() + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write account registry: %w", err)
+	}
+	return os.Rename(tmp, registryPath())
+}
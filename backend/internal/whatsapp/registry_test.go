@@ -0,0 +1,106 @@
+package whatsapp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"esther-whatsapp/internal/config"
+)
+
+// useSessionDir points the session directory at a fresh temp dir until the test ends
+func useSessionDir(t *testing.T) string {
+	t.Helper()
+	previous := config.AppConfig
+	dir := t.TempDir()
+	config.AppConfig = &config.Config{SessionDir: dir}
+	t.Cleanup(func() { config.AppConfig = previous })
+	return dir
+}
+
+// restore loads the accounts of the session directory into a new manager
+func restore(t *testing.T) *AccountManager {
+	t.Helper()
+	m := &AccountManager{accounts: make(map[string]*Account)}
+	if err := m.LoadAccounts(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		for _, account := range m.accounts {
+			account.client.Disconnect()
+		}
+	})
+	return m
+}
+
+func TestRegistryRoundTrip(t *testing.T) {
+	useSessionDir(t)
+	if _, found, err := loadRegistry(); found || err != nil {
+		t.Fatalf("loadRegistry() without a file = %v, %v, want not found", found, err)
+	}
+
+	want := accountRecord{ID: "acc1", Name: "Sales", CreatedAt: "2026-01-01T00:00:00Z", SessionFile: "wa_session_acc1.db", Labels: []string{"cs"}}
+	if err := saveRegistry([]accountRecord{want}); err != nil {
+		t.Fatal(err)
+	}
+	records, found, err := loadRegistry()
+	if err != nil || !found || len(records) != 1 {
+		t.Fatalf("loadRegistry() = %v, %v, %v", records, found, err)
+	}
+	got := records[0]
+	if got.ID != want.ID || got.Name != want.Name || got.SessionFile != want.SessionFile || len(got.Labels) != 1 {
+		t.Fatalf("loadRegistry() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCorruptRegistryIsAnError(t *testing.T) {
+	dir := useSessionDir(t)
+	if err := os.WriteFile(filepath.Join(dir, registryFile), []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := loadRegistry(); err == nil {
+		t.Fatal("loadRegistry() of a corrupt file = nil, want an error")
+	}
+}
+
+func TestAccountsAreRestoredFromTheRegistry(t *testing.T) {
+	useSessionDir(t)
+	if err := saveRegistry([]accountRecord{
+		{ID: "acc1", Name: "Sales", SessionFile: "wa_session_acc1.db", Labels: []string{"cs"}},
+		{ID: "acc2", Name: "Support", SessionFile: "wa_session_acc2.db"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	m := restore(t)
+	if len(m.accounts) != 2 {
+		t.Fatalf("%d accounts restored, want 2", len(m.accounts))
+	}
+	acc1 := m.accounts["acc1"]
+	if acc1 == nil || acc1.Name != "Sales" || len(acc1.Labels) != 1 || acc1.client == nil {
+		t.Fatalf("acc1 = %+v, want Sales with its label and a client", acc1)
+	}
+	if acc2 := m.accounts["acc2"]; acc2 == nil || acc2.Labels == nil {
+		t.Fatalf("acc2 = %+v, want it with empty labels", acc2)
+	}
+}
+
+func TestRegistryIsRebuiltFromSessionFiles(t *testing.T) {
+	dir := useSessionDir(t)
+	for _, name := range []string{"wa_session_acc1.db", "wa_session_.db", "other.db", "wa_session_acc2.db-wal"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := restore(t)
+	if len(m.accounts) != 1 || m.accounts["acc1"] == nil || m.accounts["acc1"].Name != "acc1" {
+		t.Fatalf("restored %v, want acc1 named after its session", m.accounts)
+	}
+
+	// The rebuilt registry is saved for the next start
+	records, found, err := loadRegistry()
+	if err != nil || !found || len(records) != 1 || records[0].SessionFile != "wa_session_acc1.db" {
+		t.Fatalf("saved registry = %+v, %v, %v", records, found, err)
+	}
+}
@@ -14,21 +14,22 @@ import (
 	"google.golang.org/protobuf/proto"
 )
 
-// SendSafe sends a message with random delay for natural behavior
-func SendSafe(recipient types.JID, text string, msgType string) error {
+// SendSafe sends a message with random delay for natural behavior and returns its WhatsApp message ID
+func SendSafe(recipient types.JID, text string, msgType string) (string, error) {
 	if Client == nil {
-		return fmt.Errorf("client not initialized")
+		return "", fmt.Errorf("client not initialized")
 	}
 
 	if !Client.IsConnected() {
-		return fmt.Errorf("client not connected")
+		return "", fmt.Errorf("client not connected")
 	}
 
-	// Random delay between MinDelaySeconds and MaxDelaySeconds
-	minDelay := config.AppConfig.MinDelaySeconds
-	maxDelay := config.AppConfig.MaxDelaySeconds
-	delay := time.Duration(rand.Intn(maxDelay-minDelay+1)+minDelay) * time.Second
+	// The legacy client shares the limiter under an empty account ID
+	if err := Limiter.Wait("", sendWait); err != nil {
+		return "", err
+	}
 
+	delay := HumanDelay("")
 	log.Printf("⏳ Waiting %v before sending to %s", delay, recipient.User)
 	time.Sleep(delay)
 
@@ -41,15 +42,24 @@ func SendSafe(recipient types.JID, text string, msgType string) error {
 	resp, err := Client.SendMessage(context.Background(), recipient, msg)
 	if err != nil {
 		log.Printf("❌ Failed to send message: %v", err)
-		return err
+		return "", err
 	}
 
 	log.Printf("✅ Message sent to %s (ID: %s)", recipient.User, resp.ID)
-	return nil
+	return resp.ID, nil
+}
+
+// HumanDelay returns a random pause between min_delay_ms and max_delay_ms of
+// the account's settings, so automated messages do not go out at machine speed
+func HumanDelay(accountID string) time.Duration {
+	minDelay, maxDelay, _ := config.SettingsFor(accountID).GetRateLimits()
+	minDelay = max(minDelay, 0)
+	maxDelay = max(maxDelay, minDelay)
+	return time.Duration(rand.Intn(maxDelay-minDelay+1)+minDelay) * time.Millisecond
 }
 
 // SendToPhone sends a message to a phone number
-func SendToPhone(phone string, text string, msgType string) error {
+func SendToPhone(phone string, text string, msgType string) (string, error) {
 	// Format phone number to JID
 	jid := types.NewJID(phone, types.DefaultUserServer)
 	return SendSafe(jid, text, msgType)
@@ -0,0 +1,35 @@
+package whatsapp
+
+import (
+	"testing"
+	"time"
+
+	"esther-whatsapp/internal/config"
+)
+
+func TestHumanDelayFollowsTheAccountSettings(t *testing.T) {
+	minDelay, maxDelay, dailyLimit := config.Settings.GetRateLimits()
+	defer config.Settings.SetRateLimits(minDelay, maxDelay, dailyLimit)
+	config.Settings.SetRateLimits(1000, 1000, dailyLimit)
+
+	fixed := 2500
+	if err := config.SetAccountOverride("acc-slow", config.SettingsOverride{MinDelayMs: &fixed, MaxDelayMs: &fixed}); err != nil {
+		t.Fatal(err)
+	}
+	defer config.DeleteAccountOverride("acc-slow")
+
+	if got := HumanDelay("acc-a"); got != time.Second {
+		t.Errorf("HumanDelay() of an account without an override = %v, want the global 1s", got)
+	}
+	if got := HumanDelay("acc-slow"); got != 2500*time.Millisecond {
+		t.Errorf("HumanDelay() of an overridden account = %v, want its 2.5s", got)
+	}
+
+	// Changing the settings applies to the next send
+	config.Settings.SetRateLimits(1000, 1500, dailyLimit)
+	for i := 0; i < 20; i++ {
+		if got := HumanDelay("acc-a"); got < time.Second || got > 1500*time.Millisecond {
+			t.Fatalf("HumanDelay() = %v, want between 1s and 1.5s", got)
+		}
+	}
+}
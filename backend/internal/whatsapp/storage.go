@@ -0,0 +1,73 @@
+package whatsapp
+
+import (
+	"fmt"
+	"io"
+
+	"esther-whatsapp/internal/media"
+	"esther-whatsapp/internal/store"
+)
+
+// SaveMedia keeps an outgoing file in media storage so it can be sent later
+func SaveMedia(accountID string, m *Media) (*store.MediaFile, error) {
+	return saveMediaFile(accountID, m.Kind(), m.MimeType, m.FileName, m.Caption, m.Data)
+}
+
+// LoadStoredMedia reads a file back from media storage
+func LoadStoredMedia(id, caption string) (*Media, error) {
+	file, err := store.DB.GetMediaFile(id)
+	if err != nil {
+		return nil, err
+	}
+	if file == nil {
+		return nil, fmt.Errorf("%w: media %s not found", ErrInvalidMedia, id)
+	}
+	if media.Default == nil {
+		return nil, fmt.Errorf("media storage not initialized")
+	}
+
+	reader, err := media.Default.Open(file.StorageKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: media %s content not available", ErrInvalidMedia, id)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Media{
+		Data:     data,
+		MimeType: file.MimeType,
+		FileName: file.FileName,
+		Caption:  caption,
+	}, nil
+}
+
+// saveMediaFile writes data to media storage and records it in the store
+func saveMediaFile(accountID, kind, mimeType, name, caption string, data []byte) (*store.MediaFile, error) {
+	if media.Default == nil {
+		return nil, fmt.Errorf("media storage not initialized")
+	}
+
+	key, err := media.Default.Save(name, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to save %s: %w", kind, err)
+	}
+
+	file := &store.MediaFile{
+		AccountID:  accountID,
+		MediaType:  kind,
+		MimeType:   mimeType,
+		FileName:   name,
+		Size:       int64(len(data)),
+		Caption:    caption,
+		StorageKey: key,
+	}
+	if err := store.DB.CreateMediaFile(file); err != nil {
+		media.Default.Delete(key)
+		return nil, err
+	}
+	return file, nil
+}
@@ -27,6 +27,192 @@ CREATE TABLE IF NOT EXISTS messages (
     created_at TIMESTAMPTZ DEFAULT NOW()
 );
 
+-- Multi-account support: users and messages belong to a WhatsApp account
+ALTER TABLE users ADD COLUMN IF NOT EXISTS account_id VARCHAR(20);
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS account_id VARCHAR(20);
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_phone_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_phone_account ON users(phone, account_id);
+
+-- Away messages are logged as their own type
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_message_type_check;
+ALTER TABLE messages ADD CONSTRAINT messages_message_type_check
+    CHECK (message_type IN ('reply', 'system', 'manual', 'user', 'away', 'broadcast'));
+
+-- Media messages: image | video | audio | document | sticker | location, NULL for text
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS media_type VARCHAR(20);
+
+-- Media files table: received attachments, content kept in media storage
+CREATE TABLE IF NOT EXISTS media_files (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id VARCHAR(20) NOT NULL DEFAULT '',
+    media_type VARCHAR(20) NOT NULL,
+    mime_type VARCHAR(255) NOT NULL,
+    file_name VARCHAR(255) NOT NULL DEFAULT '',
+    size BIGINT NOT NULL DEFAULT 0,
+    caption TEXT NOT NULL DEFAULT '',
+    storage_key TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS media_id UUID REFERENCES media_files(id) ON DELETE SET NULL;
+
+-- Templates table: reusable message templates
+CREATE TABLE IF NOT EXISTS templates (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    name VARCHAR(255) NOT NULL,
+    content TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Scheduled messages table: messages queued for a future send time
+CREATE TABLE IF NOT EXISTS scheduled_messages (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    phone VARCHAR(20) NOT NULL,
+    message TEXT NOT NULL,
+    scheduled_at TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) DEFAULT 'pending' CHECK (status IN ('pending', 'sent', 'failed')),
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Sending account of a scheduled message, empty for the account the user last talked to
+ALTER TABLE scheduled_messages ADD COLUMN IF NOT EXISTS account_id VARCHAR(20) NOT NULL DEFAULT '';
+
+-- Queue job of a scheduled message: queued until the job is sent, failed or rejected
+ALTER TABLE scheduled_messages ADD COLUMN IF NOT EXISTS job_id VARCHAR(40) NOT NULL DEFAULT '';
+ALTER TABLE scheduled_messages DROP CONSTRAINT IF EXISTS scheduled_messages_status_check;
+ALTER TABLE scheduled_messages ADD CONSTRAINT scheduled_messages_status_check
+    CHECK (status IN ('pending', 'queued', 'sent', 'failed', 'rejected'));
+
+-- Broadcasts table: campaigns and their progress
+CREATE TABLE IF NOT EXISTS broadcasts (
+    id VARCHAR(20) PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    message TEXT NOT NULL,
+    account_id VARCHAR(20) NOT NULL,
+    recipients JSONB NOT NULL DEFAULT '[]',
+    sent INTEGER DEFAULT 0,
+    failed INTEGER DEFAULT 0,
+    total INTEGER DEFAULT 0,
+    status VARCHAR(20) DEFAULT 'pending',
+    delay_ms INTEGER DEFAULT 0,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Optional broadcast attachment, stored in MEDIA_DIR
+ALTER TABLE broadcasts ADD COLUMN IF NOT EXISTS media_file VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Message placeholder values: custom fields of a user, data rows of a broadcast keyed by phone
+ALTER TABLE users ADD COLUMN IF NOT EXISTS fields JSONB NOT NULL DEFAULT '{}';
+ALTER TABLE broadcasts ADD COLUMN IF NOT EXISTS data JSONB NOT NULL DEFAULT '{}';
+
+-- Broadcast audiences: user tags to segment on, filters resolved into recipients at start
+ALTER TABLE users ADD COLUMN IF NOT EXISTS tags JSONB NOT NULL DEFAULT '[]';
+ALTER TABLE broadcasts ADD COLUMN IF NOT EXISTS audience JSONB;
+
+-- Broadcast recipients refused by the anti-ban rules, counted apart from failures
+ALTER TABLE broadcasts ADD COLUMN IF NOT EXISTS skipped INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE broadcasts ADD COLUMN IF NOT EXISTS skip_reasons JSONB NOT NULL DEFAULT '{}';
+
+-- Broadcast recipients table: delivery log of every campaign recipient, in send order
+CREATE TABLE IF NOT EXISTS broadcast_recipients (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    broadcast_id VARCHAR(20) NOT NULL REFERENCES broadcasts(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    phone VARCHAR(20) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'sent', 'failed', 'skipped')),
+    error TEXT NOT NULL DEFAULT '',
+    wa_message_id VARCHAR(255) NOT NULL DEFAULT '',
+    sent_at TIMESTAMPTZ,
+    receipt VARCHAR(20) NOT NULL DEFAULT '',
+    receipt_at TIMESTAMPTZ,
+    UNIQUE (broadcast_id, phone)
+);
+
+-- Scheduled broadcast start, and the business-hours opening a paused run waits for
+ALTER TABLE broadcasts ADD COLUMN IF NOT EXISTS scheduled_at TIMESTAMPTZ;
+ALTER TABLE broadcasts ADD COLUMN IF NOT EXISTS resume_at TIMESTAMPTZ;
+
+-- Keywords table: auto-reply rules, global when account_id is empty
+CREATE TABLE IF NOT EXISTS keywords (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    account_id VARCHAR(20) NOT NULL DEFAULT '',
+    keyword VARCHAR(255) NOT NULL,
+    response TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    UNIQUE (account_id, keyword)
+);
+
+-- Keyword match mode and evaluation priority
+ALTER TABLE keywords ADD COLUMN IF NOT EXISTS match_mode VARCHAR(20) NOT NULL DEFAULT 'exact'
+    CHECK (match_mode IN ('exact', 'contains', 'prefix', 'regex', 'fuzzy'));
+ALTER TABLE keywords ADD COLUMN IF NOT EXISTS priority INTEGER NOT NULL DEFAULT 0;
+
+-- Default global keywords
+INSERT INTO keywords (account_id, keyword, response) VALUES
+    ('', 'info', E'Halo 👋\nSelamat datang! Ini adalah bot informasi Esther.\n\nKetik:\n• *jadwal* - Lihat jadwal\n• *bantuan* - Hubungi CS'),
+    ('', 'jadwal', E'📅 Jadwal operasional:\nSenin - Sabtu: 08.00 - 20.00 WIB'),
+    ('', 'bantuan', E'🙋 Tim CS kami akan segera menghubungi Anda.\nTerima kasih telah menunggu.'),
+    ('', 'stop', E'✅ Anda telah berhenti berlangganan notifikasi.\nKetik *start* untuk berlangganan kembali.'),
+    ('', 'start', E'✅ Anda telah berlangganan notifikasi.\nKetik *stop* untuk berhenti.')
+ON CONFLICT (account_id, keyword) DO NOTHING;
+
+-- Flows table: menu-driven conversations started by a trigger keyword
+CREATE TABLE IF NOT EXISTS flows (
+    id VARCHAR(20) PRIMARY KEY,
+    account_id VARCHAR(20) NOT NULL DEFAULT '',
+    name VARCHAR(255) NOT NULL,
+    trigger VARCHAR(255) NOT NULL,
+    start_node VARCHAR(100) NOT NULL,
+    timeout_seconds INTEGER NOT NULL DEFAULT 0,
+    nodes JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Jobs table: durable send queue with retries; failed and rejected jobs are dead-lettered
+CREATE TABLE IF NOT EXISTS jobs (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    phone VARCHAR(20) NOT NULL,
+    message TEXT NOT NULL,
+    msg_type VARCHAR(20) NOT NULL,
+    account_id VARCHAR(20) NOT NULL DEFAULT '',
+    strategy VARCHAR(30) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'queued'
+        CHECK (status IN ('queued', 'sending', 'sent', 'failed', 'rejected')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    max_attempts INTEGER NOT NULL DEFAULT 0,
+    next_run_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error TEXT NOT NULL DEFAULT '',
+    wa_message_id VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Job attachments: media storage ID or MEDIA_DIR file name
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS media_id VARCHAR(40) NOT NULL DEFAULT '';
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS media_file VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS lane VARCHAR(20) NOT NULL DEFAULT 'bulk'
+    CHECK (lane IN ('reply', 'manual', 'system', 'bulk'));
+UPDATE jobs SET lane = msg_type WHERE lane = 'bulk' AND msg_type IN ('reply', 'manual', 'system');
+
+-- Chat a reply goes back to (a group or linked-ID address), empty for the phone's own chat
+ALTER TABLE jobs ADD COLUMN IF NOT EXISTS chat VARCHAR(100) NOT NULL DEFAULT '';
+
+-- Due jobs of each account per lane numbered oldest first, read by the queue so
+-- a backlog on one account never hides the jobs of the others
+DROP VIEW IF EXISTS next_pinned_jobs;
+CREATE VIEW next_pinned_jobs AS
+SELECT *, ROW_NUMBER() OVER (PARTITION BY lane, account_id ORDER BY next_run_at, created_at) AS position
+FROM jobs
+WHERE status = 'queued' AND account_id <> '' AND next_run_at <= NOW();
+
+-- Settings table: runtime bot settings as JSON documents
+CREATE TABLE IF NOT EXISTS settings (
+    key VARCHAR(100) PRIMARY KEY,
+    value TEXT NOT NULL,
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
 -- Activity logs table: for audit trail
 CREATE TABLE IF NOT EXISTS activity_logs (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -38,7 +224,14 @@ CREATE TABLE IF NOT EXISTS activity_logs (
 -- Indexes for performance
 CREATE INDEX IF NOT EXISTS idx_messages_user_id ON messages(user_id);
 CREATE INDEX IF NOT EXISTS idx_messages_created_at ON messages(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_messages_wa_message_id ON messages(wa_message_id);
 CREATE INDEX IF NOT EXISTS idx_users_phone ON users(phone);
+CREATE INDEX IF NOT EXISTS idx_scheduled_messages_status ON scheduled_messages(status, scheduled_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_status_next_run ON jobs(status, next_run_at);
+CREATE INDEX IF NOT EXISTS idx_jobs_lane_status_next_run ON jobs(lane, status, next_run_at);
+CREATE INDEX IF NOT EXISTS idx_broadcasts_created_at ON broadcasts(created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_broadcast_recipients_status ON broadcast_recipients(broadcast_id, status, position);
+CREATE INDEX IF NOT EXISTS idx_broadcast_recipients_wa_message_id ON broadcast_recipients(wa_message_id);
 CREATE INDEX IF NOT EXISTS idx_activity_logs_created_at ON activity_logs(created_at DESC);
 
 -- Function to auto-update updated_at